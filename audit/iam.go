@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"fmt"
+
+	dstu2ct "github.com/google/fhir/go/proto/google/fhir/proto/dstu2/codes_go_proto"
+	dstu2dt "github.com/google/fhir/go/proto/google/fhir/proto/dstu2/datatypes_go_proto"
+	dstu2pb "github.com/google/fhir/go/proto/google/fhir/proto/dstu2/resources_go_proto"
+	"github.com/philips-software/go-hsdp-api/audit/helper/fhir/dstu2"
+	"github.com/philips-software/go-hsdp-api/iam"
+)
+
+// IAMAuditEvent converts an IAM audit record into a FHIR AuditEvent. Additional
+// options are applied after the record fields, e.g. to set the source identifier
+func IAMAuditEvent(record iam.AuditRecord, productKey, tenant string, options ...dstu2.OptionFunc) (*dstu2pb.AuditEvent, error) {
+	outcome := dstu2ct.AuditEventOutcomeCode_SUCCESS
+	outcomeDesc := "Success"
+	if !record.Success() {
+		outcome = dstu2ct.AuditEventOutcomeCode_SERIOUS_FAILURE
+		outcomeDesc = fmt.Sprintf("Failed with status %d", record.StatusCode)
+		if record.Err != nil {
+			outcomeDesc = record.Err.Error()
+		}
+	}
+	object := &dstu2pb.AuditEvent_Object{
+		Identifier: &dstu2dt.Identifier{
+			Value: &dstu2dt.String{Value: record.ResourceID},
+		},
+		Type: &dstu2dt.Coding{
+			System:  &dstu2dt.Uri{Value: "http://hl7.org/fhir/object-type"},
+			Code:    &dstu2dt.Code{Value: "2"},
+			Display: &dstu2dt.String{Value: "System Object"},
+		},
+		Name:        &dstu2dt.String{Value: record.ResourceType},
+		Description: &dstu2dt.String{Value: record.Method + " " + record.Path},
+	}
+	if record.Before != nil {
+		object.Detail = append(object.Detail, &dstu2pb.AuditEvent_Object_Detail{
+			Type:  &dstu2dt.String{Value: "before"},
+			Value: &dstu2dt.Base64Binary{Value: record.Before},
+		})
+	}
+	if record.After != nil {
+		object.Detail = append(object.Detail, &dstu2pb.AuditEvent_Object_Detail{
+			Type:  &dstu2dt.String{Value: "after"},
+			Value: &dstu2dt.Base64Binary{Value: record.After},
+		})
+	}
+	subtype := record.Operation
+	if record.Action != "" {
+		subtype = record.Action
+	}
+
+	opts := []dstu2.OptionFunc{
+		dstu2.AddSourceExtensionUriValue("applicationName", "iam"),
+		dstu2.WithEvent(&dstu2pb.AuditEvent_Event{
+			Action: &dstu2ct.AuditEventActionCode{
+				Value: iamAuditAction(record.Operation),
+			},
+			DateTime: dstu2.DateTime(record.Time),
+			Type: &dstu2dt.Coding{
+				System:  &dstu2dt.Uri{Value: "http://hl7.org/fhir/ValueSet/audit-event-type"},
+				Code:    &dstu2dt.Code{Value: "rest"},
+				Display: &dstu2dt.String{Value: "RESTful Operation"},
+			},
+			Subtype: []*dstu2dt.Coding{
+				{
+					System:  &dstu2dt.Uri{Value: "http://hl7.org/fhir/restful-interaction"},
+					Code:    &dstu2dt.Code{Value: subtype},
+					Display: &dstu2dt.String{Value: record.ResourceType + " " + subtype},
+				},
+			},
+			Outcome: &dstu2ct.AuditEventOutcomeCode{
+				Value: outcome,
+			},
+			OutcomeDesc: &dstu2dt.String{Value: outcomeDesc},
+		}),
+		dstu2.AddParticipant(&dstu2pb.AuditEvent_Participant{
+			UserId: &dstu2dt.Identifier{
+				Value: &dstu2dt.String{Value: record.Actor},
+			},
+			Requestor: &dstu2dt.Boolean{Value: true},
+		}),
+		dstu2.AddObject(object),
+	}
+	return dstu2.NewAuditEvent(productKey, tenant, append(opts, options...)...)
+}
+
+func iamAuditAction(operation string) dstu2ct.AuditEventActionCode_Value {
+	switch operation {
+	case iam.AuditOperationCreate:
+		return dstu2ct.AuditEventActionCode_C
+	case iam.AuditOperationUpdate:
+		return dstu2ct.AuditEventActionCode_U
+	case iam.AuditOperationDelete:
+		return dstu2ct.AuditEventActionCode_D
+	}
+	return dstu2ct.AuditEventActionCode_E
+}
+
+// IAMAuditHook returns an iam.AuditHook which publishes every record as an AuditEvent.
+// Publishing errors are passed to onError when it is not nil
+func (c *Client) IAMAuditHook(productKey, tenant string, onError func(error), options ...dstu2.OptionFunc) iam.AuditHook {
+	return func(record iam.AuditRecord) {
+		event, err := IAMAuditEvent(record, productKey, tenant, options...)
+		if err == nil {
+			_, _, err = c.CreateAuditEvent(event)
+		}
+		if err != nil && onError != nil {
+			onError(fmt.Errorf("audit.IAMAuditHook: %w", err))
+		}
+	}
+}
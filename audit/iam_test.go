@@ -0,0 +1,74 @@
+package audit_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	dstu2ct "github.com/google/fhir/go/proto/google/fhir/proto/dstu2/codes_go_proto"
+	"github.com/philips-software/go-hsdp-api/audit"
+	"github.com/philips-software/go-hsdp-api/iam"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIAMAuditEvent(t *testing.T) {
+	record := iam.AuditRecord{
+		Time:         time.Now(),
+		Method:       http.MethodPut,
+		Path:         "authorize/identity/Group/123",
+		Operation:    iam.AuditOperationUpdate,
+		ResourceType: "Group",
+		ResourceID:   "123",
+		Before:       []byte(`{"name":"old"}`),
+		After:        []byte(`{"name":"new"}`),
+		Actor:        "admin@example.com",
+		StatusCode:   http.StatusOK,
+	}
+	event, err := audit.IAMAuditEvent(record, "key", "tenant")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, dstu2ct.AuditEventActionCode_U, event.Event.Action.Value)
+	assert.Equal(t, dstu2ct.AuditEventOutcomeCode_SUCCESS, event.Event.Outcome.Value)
+	if assert.Len(t, event.Object, 1) {
+		assert.Equal(t, "123", event.Object[0].Identifier.Value.Value)
+		assert.Len(t, event.Object[0].Detail, 2)
+	}
+	if assert.Len(t, event.Participant, 1) {
+		assert.Equal(t, "admin@example.com", event.Participant[0].UserId.Value.Value)
+	}
+
+	record.StatusCode = http.StatusForbidden
+	record.Err = errors.New("forbidden")
+	event, err = audit.IAMAuditEvent(record, "key", "tenant")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, dstu2ct.AuditEventOutcomeCode_SERIOUS_FAILURE, event.Event.Outcome.Value)
+	assert.Equal(t, "forbidden", event.Event.OutcomeDesc.Value)
+}
+
+func TestIAMAuditHook(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	posted := 0
+	muxAudit.HandleFunc("/core/audit/AuditEvent", func(w http.ResponseWriter, r *http.Request) {
+		posted++
+		w.WriteHeader(http.StatusCreated)
+	})
+	var hookErr error
+	hook := auditClient.IAMAuditHook("key", "tenant", func(err error) {
+		hookErr = err
+	})
+	hook(iam.AuditRecord{
+		Time:         time.Now(),
+		Method:       http.MethodPost,
+		Operation:    iam.AuditOperationCreate,
+		ResourceType: "Group",
+		StatusCode:   http.StatusCreated,
+	})
+	assert.Nil(t, hookErr)
+	assert.Equal(t, 1, posted)
+}
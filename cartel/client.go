@@ -15,6 +15,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/philips-software/go-hsdp-api/internal"
 
@@ -37,6 +38,9 @@ type Config struct {
 	Host       string `cloud:"host" json:"host"`
 	Debug      bool   `cloud:"-" json:"debug,omitempty"`
 	DebugLog   string `cloud:"-" json:"debug_log,omitempty"`
+	// PollInterval is the initial interval between state checks of the Wait
+	// functions. Defaults to 5 seconds
+	PollInterval time.Duration `cloud:"-" json:"-"`
 }
 
 // Valid returns if all required config fields are present, false otherwise
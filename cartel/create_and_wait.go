@@ -0,0 +1,113 @@
+package cartel
+
+import (
+	"context"
+	"fmt"
+)
+
+// Steps of CreateAndWait
+const (
+	StepCreate         = "create"
+	StepWait           = "wait"
+	StepTags           = "tags"
+	StepSecurityGroups = "security_groups"
+	StepUserGroups     = "user_groups"
+	StepDetails        = "details"
+)
+
+// CreateAndWaitOptions holds the settings CreateAndWait applies once the instance is running
+type CreateAndWaitOptions struct {
+	Tags           map[string]string
+	SecurityGroups []string
+	UserGroups     []string
+	// KeepOnFailure leaves the instance in place when a step fails instead of destroying it
+	KeepOnFailure bool
+}
+
+// StepError reports the CreateAndWait step that failed
+type StepError struct {
+	Step string
+	Err  error
+	// RolledBack is true when the instance was destroyed
+	RolledBack bool
+	// RollbackErr is set when destroying the instance failed
+	RollbackErr error
+}
+
+func (e *StepError) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Step, e.Err)
+	if e.RollbackErr != nil {
+		msg += fmt.Sprintf(" (rollback failed: %v)", e.RollbackErr)
+	}
+	return msg
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// CreateAndWait creates an instance, waits until its deployment succeeded and then
+// adds the tags, security groups and user groups of options. When waiting or adding
+// fails the instance is destroyed, unless options.KeepOnFailure is set.
+// Errors are of type *StepError. On success the details of the instance are returned
+func (c *Client) CreateAndWait(ctx context.Context, tagName string, options CreateAndWaitOptions, opts ...RequestOptionFunc) (*InstanceDetails, error) {
+	created, _, err := c.Create(tagName, opts...)
+	if err == nil && !created.Success() {
+		err = fmt.Errorf("%w: %s", ErrOperationFailed, created.Description)
+	}
+	if err != nil {
+		// Nothing to roll back, the instance may even belong to someone else
+		return nil, &StepError{Step: StepCreate, Err: err}
+	}
+	fail := func(step string, err error) error {
+		stepErr := &StepError{Step: step, Err: err}
+		if options.KeepOnFailure {
+			return stepErr
+		}
+		destroyed, _, err := c.Destroy(tagName)
+		if err == nil && !destroyed.Success() {
+			err = fmt.Errorf("%w: %s not removed", ErrOperationFailed, tagName)
+		}
+		stepErr.RolledBack = err == nil
+		stepErr.RollbackErr = err
+		return stepErr
+	}
+
+	if _, err := c.WaitForState(ctx, tagName, StateSucceeded); err != nil {
+		return nil, fail(StepWait, err)
+	}
+	instances := []string{tagName}
+	if len(options.Tags) > 0 {
+		resp, _, err := c.AddTags(instances, options.Tags)
+		if err == nil && !resp.Success() {
+			err = fmt.Errorf("%w: %s", ErrOperationFailed, resp.Description)
+		}
+		if err != nil {
+			return nil, fail(StepTags, err)
+		}
+	}
+	if len(options.SecurityGroups) > 0 {
+		resp, _, err := c.AddSecurityGroups(instances, options.SecurityGroups)
+		if err == nil && !resp.Success() {
+			err = fmt.Errorf("%w: %s", ErrOperationFailed, resp.Description)
+		}
+		if err != nil {
+			return nil, fail(StepSecurityGroups, err)
+		}
+	}
+	if len(options.UserGroups) > 0 {
+		resp, _, err := c.AddUserGroups(instances, options.UserGroups)
+		if err == nil && !resp.Success() {
+			err = fmt.Errorf("%w: %s", ErrOperationFailed, resp.Description)
+		}
+		if err != nil {
+			return nil, fail(StepUserGroups, err)
+		}
+	}
+	details, _, err := c.GetDetails(tagName)
+	if err != nil {
+		// The instance is complete, only reading it back failed
+		return nil, &StepError{Step: StepDetails, Err: err}
+	}
+	return details, nil
+}
@@ -0,0 +1,109 @@
+package cartel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateAndWait(t *testing.T) {
+	teardown, err := setup(t, &Config{
+		NoTLS:        true,
+		SkipVerify:   true,
+		Token:        sharedToken,
+		Secret:       sharedSecret,
+		Host:         "foo",
+		PollInterval: time.Millisecond,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls []string
+	record := func(name string, handler http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, name)
+			handler(w, r)
+		}
+	}
+	deployState := "succeeded"
+	securityGroupStatus := http.StatusOK
+	muxCartel.HandleFunc("/v3/api/create", record("create", endpointMocker([]byte(sharedSecret),
+		`{"message":[{"instance_id":"i-1","ip_address":"192.168.2.106","name":"foo.dev"}],"result":"Success"}`)))
+	muxCartel.HandleFunc("/v3/api/deployment_status", func(w http.ResponseWriter, r *http.Request) {
+		endpointMocker([]byte(sharedSecret), `{"foo.dev":{"deploy_state":"`+deployState+`"}}`)(w, r)
+	})
+	muxCartel.HandleFunc("/v3/api/add_tags", record("add_tags", endpointMocker([]byte(sharedSecret),
+		`{"message": "Added tags"}`)))
+	muxCartel.HandleFunc("/v3/api/add_security_groups", record("add_security_groups", func(w http.ResponseWriter, r *http.Request) {
+		endpointMocker([]byte(sharedSecret), `{"message": "ok"}`, securityGroupStatus)(w, r)
+	}))
+	muxCartel.HandleFunc("/v3/api/add_ldap_group", record("add_ldap_group", endpointMocker([]byte(sharedSecret),
+		`{"message": "ok"}`)))
+	muxCartel.HandleFunc("/v3/api/instance_details", record("instance_details", endpointMocker([]byte(sharedSecret),
+		`[{"foo.dev":{"instance_id":"i-1","role":"container-host","state":"running"}}]`)))
+	muxCartel.HandleFunc("/v3/api/destroy", record("destroy", endpointMocker([]byte(sharedSecret),
+		`{"Cartel":{"foo.dev":"Instance removed."}}`)))
+
+	options := CreateAndWaitOptions{
+		Tags:           map[string]string{"billing": "team"},
+		SecurityGroups: []string{"https-from-cf"},
+		UserGroups:     []string{"devs"},
+	}
+	details, err := client.CreateAndWait(context.Background(), "foo.dev", options)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "i-1", details.InstanceID)
+	}
+	assert.Equal(t, []string{"create", "add_tags", "add_security_groups", "add_ldap_group", "instance_details"}, calls)
+
+	// A failing step destroys the instance
+	calls = nil
+	securityGroupStatus = http.StatusInternalServerError
+	_, err = client.CreateAndWait(context.Background(), "foo.dev", options)
+	var stepErr *StepError
+	if assert.True(t, errors.As(err, &stepErr)) {
+		assert.Equal(t, StepSecurityGroups, stepErr.Step)
+		assert.True(t, stepErr.RolledBack)
+		assert.Nil(t, stepErr.RollbackErr)
+	}
+	assert.Equal(t, []string{"create", "add_tags", "add_security_groups", "destroy"}, calls)
+
+	// unless asked to keep it
+	calls = nil
+	deployState = "failed"
+	options.KeepOnFailure = true
+	_, err = client.CreateAndWait(context.Background(), "foo.dev", options)
+	assert.True(t, errors.Is(err, ErrDeploymentFailed))
+	if assert.True(t, errors.As(err, &stepErr)) {
+		assert.Equal(t, StepWait, stepErr.Step)
+		assert.False(t, stepErr.RolledBack)
+	}
+	assert.Equal(t, []string{"create"}, calls)
+}
+
+func TestCreateAndWaitExisting(t *testing.T) {
+	teardown, err := setup(t, &Config{
+		NoTLS:  true,
+		Token:  sharedToken,
+		Secret: sharedSecret,
+		Host:   "foo",
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	destroyed := false
+	muxCartel.HandleFunc("/v3/api/create", endpointMocker([]byte(sharedSecret),
+		`{"code": 400, "description": "Host named foo.dev already exists!"}`, http.StatusBadRequest))
+	muxCartel.HandleFunc("/v3/api/destroy", func(w http.ResponseWriter, r *http.Request) {
+		destroyed = true
+	})
+
+	_, err = client.CreateAndWait(context.Background(), "foo.dev", CreateAndWaitOptions{})
+	assert.True(t, errors.Is(err, ErrHostnameAlreadyExists))
+	assert.False(t, destroyed)
+}
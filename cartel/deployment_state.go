@@ -1,25 +1,146 @@
 package cartel
 
-func (c *Client) GetDeploymentState(nameTag string) (string, *Response, error) {
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// DeploymentState is the deployment state of an instance as reported by Cartel
+type DeploymentState string
+
+const (
+	StateProvisioning DeploymentState = "provisioning"
+	StateSucceeded    DeploymentState = "succeeded"
+	StateFailed       DeploymentState = "failed"
+	// StateIndeterminate is returned when Cartel knows the instance but reports no state
+	StateIndeterminate DeploymentState = "indeterminate"
+	// StateUnknownInstance is returned when Cartel does not know the instance
+	StateUnknownInstance DeploymentState = "unknown_instance"
+	// StateFatalError is returned when the request could not be made
+	StateFatalError DeploymentState = "fatal_error"
+)
+
+// InstanceState is the run state of an instance, see InstanceDetails.State
+type InstanceState string
+
+const (
+	InstancePending      InstanceState = "pending"
+	InstanceRunning      InstanceState = "running"
+	InstanceStopping     InstanceState = "stopping"
+	InstanceStopped      InstanceState = "stopped"
+	InstanceShuttingDown InstanceState = "shutting-down"
+	InstanceTerminated   InstanceState = "terminated"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+func (c *Client) GetDeploymentState(nameTag string) (DeploymentState, *Response, error) {
 	var body RequestBody
 	body.NameTag = []string{nameTag}
 
 	req, err := c.newRequest("POST", "v3/api/deployment_status", &body, nil)
 	if err != nil {
-		return "fatal_error", nil, err
+		return StateFatalError, nil, err
 	}
 	var responseBody map[string]interface{}
 	resp, err := c.do(req, &responseBody)
 	if err != nil {
-		return "unknown_instance", resp, err
+		return StateUnknownInstance, resp, err
 	}
 	state, ok := responseBody[nameTag].(map[string]interface{})
 	if !ok {
-		return "unknown_instance", resp, err
+		return StateUnknownInstance, resp, err
 	}
 	deployState, ok := state["deploy_state"].(string)
 	if !ok {
-		return "indeterminate", resp, err
+		return StateIndeterminate, resp, err
+	}
+	return DeploymentState(deployState), resp, err
+}
+
+// WaitForState polls the deployment state of the instance with backoff until it
+// reaches desired. It stops with ErrDeploymentFailed when the deployment fails
+// and with the context error when ctx is done. Client errors other than 404 and
+// 429, e.g. bad credentials, stop it as well. The last seen state is returned
+func (c *Client) WaitForState(ctx context.Context, nameTag string, desired DeploymentState) (DeploymentState, error) {
+	var state DeploymentState
+	operation := func() error {
+		var resp *Response
+		var err error
+		state, resp, err = c.GetDeploymentState(nameTag)
+		switch {
+		case state == desired && err == nil:
+			return nil
+		case state == StateFatalError || (err != nil && clientError(resp)):
+			return backoff.Permanent(err)
+		case state == StateFailed:
+			return backoff.Permanent(fmt.Errorf("%w: %s", ErrDeploymentFailed, nameTag))
+		case err != nil:
+			return err
+		}
+		return fmt.Errorf("%w: %s is %s", ErrUnexpectedState, nameTag, state)
+	}
+	if err := backoff.Retry(operation, backoff.WithContext(c.pollPolicy(), ctx)); err != nil {
+		return state, fmt.Errorf("waiting for %s to be %s: %w", nameTag, desired, err)
+	}
+	return state, nil
+}
+
+// WaitForInstanceState polls the details of the instance with backoff until its
+// run state is desired, e.g. InstanceStopped after Stop. It stops with
+// ErrUnexpectedState when the instance is terminated unexpectedly, on client
+// errors other than 404 and 429 and with the context error when ctx is done
+func (c *Client) WaitForInstanceState(ctx context.Context, nameTag string, desired InstanceState) (*InstanceDetails, error) {
+	var details *InstanceDetails
+	operation := func() error {
+		var resp *Response
+		var err error
+		details, resp, err = c.GetDetails(nameTag)
+		if err != nil && clientError(resp) {
+			return backoff.Permanent(err)
+		}
+		if err != nil {
+			return err
+		}
+		state := InstanceState(details.State)
+		switch {
+		case state == desired:
+			return nil
+		case state == InstanceTerminated:
+			return backoff.Permanent(fmt.Errorf("%w: %s is %s", ErrUnexpectedState, nameTag, state))
+		}
+		return fmt.Errorf("%w: %s is %s", ErrUnexpectedState, nameTag, state)
+	}
+	if err := backoff.Retry(operation, backoff.WithContext(c.pollPolicy(), ctx)); err != nil {
+		return details, fmt.Errorf("waiting for %s to be %s: %w", nameTag, desired, err)
+	}
+	return details, nil
+}
+
+// clientError reports 4xx responses which polling cannot resolve. Unknown
+// instances and rate limits may clear up so 404 and 429 are retried
+func clientError(resp *Response) bool {
+	if resp == nil || resp.Response == nil {
+		return false
+	}
+	code := resp.StatusCode
+	return code >= http.StatusBadRequest && code < http.StatusInternalServerError &&
+		code != http.StatusNotFound && code != http.StatusTooManyRequests
+}
+
+// pollPolicy polls until the context is done
+func (c *Client) pollPolicy() backoff.BackOff {
+	interval := c.config.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
 	}
-	return deployState, resp, err
+	policy := backoff.NewExponentialBackOff()
+	policy.InitialInterval = interval
+	policy.MaxInterval = 6 * interval
+	policy.MaxElapsedTime = 0
+	return policy
 }
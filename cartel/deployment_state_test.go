@@ -1,8 +1,12 @@
 package cartel
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -34,5 +38,133 @@ func TestDeploymentState(t *testing.T) {
 		return
 	}
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
-	assert.Equal(t, "succeeded", aur)
+	assert.Equal(t, StateSucceeded, aur)
+}
+
+func TestWaitForState(t *testing.T) {
+	teardown, err := setup(t, &Config{
+		NoTLS:        true,
+		SkipVerify:   true,
+		Token:        sharedToken,
+		Secret:       sharedSecret,
+		Host:         "foo",
+		PollInterval: time.Millisecond,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := []string{"provisioning", "provisioning", "succeeded"}
+	calls := 0
+	muxCartel.HandleFunc("/v3/api/deployment_status", func(w http.ResponseWriter, r *http.Request) {
+		state := states[len(states)-1]
+		if calls < len(states) {
+			state = states[calls]
+		}
+		calls++
+		endpointMocker([]byte(sharedSecret), fmt.Sprintf(`{"foo.dev":{"deploy_state":"%s"}}`, state))(w, r)
+	})
+
+	state, err := client.WaitForState(context.Background(), "foo.dev", StateSucceeded)
+	assert.Nil(t, err)
+	assert.Equal(t, StateSucceeded, state)
+	assert.Equal(t, 3, calls)
+
+	states = []string{"provisioning", "failed"}
+	calls = 0
+	state, err = client.WaitForState(context.Background(), "foo.dev", StateSucceeded)
+	assert.True(t, errors.Is(err, ErrDeploymentFailed))
+	assert.Equal(t, StateFailed, state)
+	assert.Equal(t, 2, calls)
+
+	states = []string{"provisioning"}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	state, err = client.WaitForState(ctx, "foo.dev", StateSucceeded)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, StateProvisioning, state)
+}
+
+func TestWaitForInstanceState(t *testing.T) {
+	teardown, err := setup(t, &Config{
+		NoTLS:        true,
+		SkipVerify:   true,
+		Token:        sharedToken,
+		Secret:       sharedSecret,
+		Host:         "foo",
+		PollInterval: time.Millisecond,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := []string{"stopping", "stopped"}
+	calls := 0
+	muxCartel.HandleFunc("/v3/api/instance_details", func(w http.ResponseWriter, r *http.Request) {
+		state := states[len(states)-1]
+		if calls < len(states) {
+			state = states[calls]
+		}
+		calls++
+		endpointMocker([]byte(sharedSecret), fmt.Sprintf(`[{"foo.dev":{"instance_id":"i-1","role":"container-host","state":"%s"}}]`, state))(w, r)
+	})
+
+	details, err := client.WaitForInstanceState(context.Background(), "foo.dev", InstanceStopped)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "i-1", details.InstanceID)
+	}
+	assert.Equal(t, 2, calls)
+
+	states = []string{"shutting-down", "terminated"}
+	calls = 0
+	_, err = client.WaitForInstanceState(context.Background(), "foo.dev", InstanceRunning)
+	assert.True(t, errors.Is(err, ErrUnexpectedState))
+	assert.Equal(t, 2, calls)
+}
+
+func TestWaitStopsOnClientErrors(t *testing.T) {
+	teardown, err := setup(t, &Config{
+		NoTLS:        true,
+		SkipVerify:   true,
+		Token:        sharedToken,
+		Secret:       sharedSecret,
+		Host:         "foo",
+		PollInterval: time.Millisecond,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := http.StatusForbidden
+	calls := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"message":"Invalid token"}`))
+	}
+	muxCartel.HandleFunc("/v3/api/deployment_status", handler)
+	muxCartel.HandleFunc("/v3/api/instance_details", handler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.WaitForState(ctx, "foo.dev", StateSucceeded)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	_, err = client.WaitForInstanceState(ctx, "foo.dev", InstanceRunning)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, calls)
+
+	// Unknown instances are retried until the context is done
+	status = http.StatusNotFound
+	calls = 0
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	_, err = client.WaitForState(short, "foo.dev", StateSucceeded)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Greater(t, calls, 1)
 }
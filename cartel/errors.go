@@ -12,6 +12,13 @@ var (
 	ErrNotFound              = errors.New("not found")
 	ErrHostnameAlreadyExists = errors.New("hostname already exists")
 	ErrInvalidSubnetType     = errors.New("invalid subnet type, must be public or private")
+	ErrDeploymentFailed      = errors.New("deployment failed")
+	ErrUnexpectedState       = errors.New("unexpected state")
+	ErrOperationFailed       = errors.New("operation failed")
+	ErrInvalidFleet          = errors.New("invalid fleet")
+	ErrUnknownAction         = errors.New("unknown action")
+	ErrInvalidRule           = errors.New("invalid security group rule")
+	ErrInvalidSecurityGroup  = errors.New("invalid security group name")
 )
 
 var (
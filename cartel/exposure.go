@@ -0,0 +1,181 @@
+package cartel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// worldSources are sources open to any address
+var worldSources = map[string]bool{
+	"0.0.0.0/0": true,
+	"::/0":      true,
+}
+
+// Exposure is an inbound protocol and port range open to a source
+type Exposure struct {
+	Protocol  string
+	PortRange string
+	Source    string
+	// Groups lists the attached security groups granting the exposure
+	Groups []string
+	// WorldOpen is true when the source is any address
+	WorldOpen bool
+}
+
+func (e Exposure) String() string {
+	return fmt.Sprintf("%s/%s from %s (%s)", e.Protocol, e.PortRange, e.Source, strings.Join(e.Groups, ", "))
+}
+
+// InstanceExposure is the effective inbound exposure of an instance
+type InstanceExposure struct {
+	NameTag        string
+	InstanceID     string
+	SecurityGroups []string
+	Inbound        []Exposure
+}
+
+// WorldOpen returns the exposures open to any address
+func (i InstanceExposure) WorldOpen() []Exposure {
+	var open []Exposure
+	for _, e := range i.Inbound {
+		if e.WorldOpen {
+			open = append(open, e)
+		}
+	}
+	return open
+}
+
+// ExposureReport holds the inbound exposure of all instances
+type ExposureReport struct {
+	Instances []InstanceExposure
+	// Groups holds the rules of every attached security group by name
+	Groups map[string]SecurityGroupDetails
+}
+
+// WorldOpen returns the instances with world open exposures
+func (r ExposureReport) WorldOpen() []InstanceExposure {
+	var open []InstanceExposure
+	for _, i := range r.Instances {
+		if len(i.WorldOpen()) > 0 {
+			open = append(open, i)
+		}
+	}
+	return open
+}
+
+// GetExposureReport combines the rules of the security groups attached to each
+// instance from GetAllInstances into its effective inbound exposure. Every
+// security group is retrieved once
+func (c *Client) GetExposureReport() (*ExposureReport, error) {
+	instances, _, err := c.GetAllInstances()
+	if err != nil {
+		return nil, err
+	}
+	report := &ExposureReport{Groups: make(map[string]SecurityGroupDetails)}
+	for _, instance := range *instances {
+		for _, group := range instance.SecurityGroups {
+			if _, ok := report.Groups[group]; ok {
+				continue
+			}
+			details, _, err := c.GetSecurityGroupDetails(group)
+			if err != nil {
+				return nil, fmt.Errorf("security group %s: %w", group, err)
+			}
+			report.Groups[group] = *details
+		}
+		report.Instances = append(report.Instances, Exposures(instance, report.Groups))
+	}
+	sort.Slice(report.Instances, func(i, j int) bool {
+		return report.Instances[i].NameTag < report.Instances[j].NameTag
+	})
+	return report, nil
+}
+
+// Exposures combines the rules of the groups attached to the instance into the
+// effective exposure. Overlapping and adjacent port ranges of a protocol and
+// source are merged, so "443" and "443-443" are one exposure
+func Exposures(instance InstanceDetails, groups map[string]SecurityGroupDetails) InstanceExposure {
+	result := InstanceExposure{
+		NameTag:        instance.NameTag,
+		InstanceID:     instance.InstanceID,
+		SecurityGroups: instance.SecurityGroups,
+	}
+	type span struct {
+		from, to int
+		groups   map[string]bool
+	}
+	spans := make(map[[2]string][]span)
+	var keys [][2]string
+	for _, group := range instance.SecurityGroups {
+		for _, rule := range groups[group] {
+			from, to, err := rule.Ports()
+			for _, source := range rule.Source {
+				if err != nil {
+					// Keep what cannot be parsed as it is
+					result.Inbound = append(result.Inbound, Exposure{
+						Protocol:  rule.Protocol,
+						PortRange: rule.PortRange,
+						Source:    source,
+						Groups:    []string{group},
+						WorldOpen: worldSources[source],
+					})
+					continue
+				}
+				key := [2]string{rule.Protocol, source}
+				if _, ok := spans[key]; !ok {
+					keys = append(keys, key)
+				}
+				spans[key] = append(spans[key], span{from: from, to: to, groups: map[string]bool{group: true}})
+			}
+		}
+	}
+	for _, key := range keys {
+		list := spans[key]
+		sort.Slice(list, func(i, j int) bool { return list[i].from < list[j].from })
+		merged := []span{list[0]}
+		for _, next := range list[1:] {
+			last := &merged[len(merged)-1]
+			if next.from > last.to+1 {
+				merged = append(merged, next)
+				continue
+			}
+			if next.to > last.to {
+				last.to = next.to
+			}
+			for group := range next.groups {
+				last.groups[group] = true
+			}
+		}
+		for _, m := range merged {
+			exposure := Exposure{
+				Protocol:  key[0],
+				PortRange: fmt.Sprintf("%d-%d", m.from, m.to),
+				Source:    key[1],
+				WorldOpen: worldSources[key[1]],
+			}
+			if m.from == allPorts {
+				exposure.PortRange = "-1"
+			}
+			for _, group := range instance.SecurityGroups {
+				if m.groups[group] {
+					exposure.Groups = append(exposure.Groups, group)
+				}
+			}
+			result.Inbound = append(result.Inbound, exposure)
+		}
+	}
+	sort.SliceStable(result.Inbound, func(i, j int) bool {
+		a, b := result.Inbound[i], result.Inbound[j]
+		aFrom, _, _ := SecurityRule{Protocol: a.Protocol, PortRange: a.PortRange}.Ports()
+		bFrom, _, _ := SecurityRule{Protocol: b.Protocol, PortRange: b.PortRange}.Ports()
+		if aFrom != bFrom {
+			return aFrom < bFrom
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Source < b.Source
+	})
+	return result
+}
@@ -0,0 +1,77 @@
+package cartel
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExposureReport(t *testing.T) {
+	teardown, err := setup(t, &Config{
+		Token:  sharedToken,
+		Secret: sharedSecret,
+		Host:   "foo",
+		NoTLS:  true,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	muxCartel.HandleFunc("/v3/api/get_all_instances", endpointMocker([]byte(sharedSecret), `[
+  {"instance_id": "i-2", "name_tag": "web.dev", "role": "container-host", "security_groups": ["https-world", "tcp-8080"]},
+  {"instance_id": "i-1", "name_tag": "db.dev", "role": "container-host", "security_groups": ["tcp-8080"]}
+]`))
+	detailsCalls := 0
+	muxCartel.HandleFunc("/v3/api/security_group_details", func(w http.ResponseWriter, r *http.Request) {
+		detailsCalls++
+		endpointMocker([]byte(sharedSecret), `{
+  "https-world": [{"port_range": "443-443", "protocol": "tcp", "source": ["0.0.0.0/0"]}],
+  "tcp-8080": [
+    {"port_range": "8080-8080", "protocol": "tcp", "source": ["base", "10.0.0.0/8"]},
+    {"port_range": "443-443", "protocol": "tcp", "source": ["0.0.0.0/0"]}
+  ]
+}`)(w, r)
+	})
+
+	report, err := client.GetExposureReport()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 2, detailsCalls)
+	if !assert.Len(t, report.Instances, 2) {
+		return
+	}
+	web := report.Instances[1]
+	assert.Equal(t, "web.dev", web.NameTag)
+	assert.Equal(t, []Exposure{
+		{Protocol: "tcp", PortRange: "443-443", Source: "0.0.0.0/0", Groups: []string{"https-world", "tcp-8080"}, WorldOpen: true},
+		{Protocol: "tcp", PortRange: "8080-8080", Source: "10.0.0.0/8", Groups: []string{"tcp-8080"}},
+		{Protocol: "tcp", PortRange: "8080-8080", Source: "base", Groups: []string{"tcp-8080"}},
+	}, web.Inbound)
+	assert.Len(t, web.WorldOpen(), 1)
+	assert.Len(t, report.WorldOpen(), 2)
+	assert.Equal(t, "tcp/443-443 from 0.0.0.0/0 (https-world, tcp-8080)", web.WorldOpen()[0].String())
+}
+
+func TestExposuresMergeRanges(t *testing.T) {
+	instance := InstanceDetails{NameTag: "web.dev", SecurityGroups: []string{"a", "b"}}
+	groups := map[string]SecurityGroupDetails{
+		"a": {
+			{Protocol: "tcp", PortRange: "443", Source: []string{"0.0.0.0/0"}},
+			{Protocol: "tcp", PortRange: "8000-8080", Source: []string{"base"}},
+			{Protocol: "icmp", PortRange: "-1", Source: []string{"base"}},
+		},
+		"b": {
+			{Protocol: "tcp", PortRange: "443-443", Source: []string{"0.0.0.0/0"}},
+			{Protocol: "tcp", PortRange: "8080-8090", Source: []string{"base"}},
+			{Protocol: "tcp", PortRange: "9000", Source: []string{"base"}},
+		},
+	}
+	assert.Equal(t, []Exposure{
+		{Protocol: "icmp", PortRange: "-1", Source: "base", Groups: []string{"a"}},
+		{Protocol: "tcp", PortRange: "443-443", Source: "0.0.0.0/0", Groups: []string{"a", "b"}, WorldOpen: true},
+		{Protocol: "tcp", PortRange: "8000-8090", Source: "base", Groups: []string{"a", "b"}},
+		{Protocol: "tcp", PortRange: "9000-9000", Source: "base", Groups: []string{"b"}},
+	}, Exposures(instance, groups).Inbound)
+}
@@ -0,0 +1,396 @@
+package cartel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultApplyConcurrency = 4
+
+// InstanceSpec is the desired configuration of an instance. Nil SecurityGroups,
+// UserGroups and Protect are not managed, an empty list removes all groups.
+// Tags are only added or updated since Cartel cannot remove them. NumVolumes is
+// checked against the block devices besides the root device. Image, VolSize,
+// VolumeType, IOPs, EncryptVols and SubnetType are only used on create since
+// Cartel does not report them, so their drift is not detected
+type InstanceSpec struct {
+	NameTag        string            `json:"name" yaml:"name"`
+	Role           string            `json:"role,omitempty" yaml:"role,omitempty"`
+	InstanceType   string            `json:"instance_type,omitempty" yaml:"instance_type,omitempty"`
+	Image          string            `json:"image,omitempty" yaml:"image,omitempty"`
+	NumVolumes     int               `json:"num_vols,omitempty" yaml:"num_vols,omitempty"`
+	VolSize        int               `json:"vol_size,omitempty" yaml:"vol_size,omitempty"`
+	VolumeType     string            `json:"vol_type,omitempty" yaml:"vol_type,omitempty"`
+	IOPs           int               `json:"iops,omitempty" yaml:"iops,omitempty"`
+	EncryptVols    bool              `json:"encrypt_vols,omitempty" yaml:"encrypt_vols,omitempty"`
+	SubnetType     string            `json:"subnet_type,omitempty" yaml:"subnet_type,omitempty"`
+	Subnet         string            `json:"subnet,omitempty" yaml:"subnet,omitempty"`
+	VpcID          string            `json:"vpc_id,omitempty" yaml:"vpc_id,omitempty"`
+	SecurityGroups []string          `json:"security_groups,omitempty" yaml:"security_groups,omitempty"`
+	UserGroups     []string          `json:"ldap_groups,omitempty" yaml:"ldap_groups,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Protect        *bool             `json:"protect,omitempty" yaml:"protect,omitempty"`
+}
+
+// Fleet is a set of instance specs
+type Fleet struct {
+	Instances []InstanceSpec `json:"instances" yaml:"instances"`
+}
+
+// ParseFleet decodes a YAML fleet definition
+func ParseFleet(data []byte) (*Fleet, error) {
+	var fleet Fleet
+	if err := yaml.Unmarshal(data, &fleet); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i, spec := range fleet.Instances {
+		if spec.NameTag == "" {
+			return nil, fmt.Errorf("%w: instance %d has no name", ErrInvalidFleet, i)
+		}
+		if seen[spec.NameTag] {
+			return nil, fmt.Errorf("%w: duplicate instance %s", ErrInvalidFleet, spec.NameTag)
+		}
+		seen[spec.NameTag] = true
+		if err := spec.requestBody(&RequestBody{}); err != nil {
+			return nil, fmt.Errorf("%w: instance %s: %v", ErrInvalidFleet, spec.NameTag, err)
+		}
+	}
+	return &fleet, nil
+}
+
+// requestBody is the RequestOptionFunc creating the instance of the spec
+func (s InstanceSpec) requestBody(body *RequestBody) error {
+	if s.Role != "" {
+		body.Role = s.Role
+	}
+	if s.SubnetType != "" {
+		if err := SubnetType(s.SubnetType)(body); err != nil {
+			return err
+		}
+	}
+	body.InstanceType = s.InstanceType
+	body.Image = s.Image
+	body.NumVolumes = s.NumVolumes
+	body.VolSize = s.VolSize
+	body.VolumeType = s.VolumeType
+	body.IOPs = s.IOPs
+	body.EncryptVols = s.EncryptVols
+	body.Subnet = s.Subnet
+	body.VpcId = s.VpcID
+	body.SecurityGroup = s.SecurityGroups
+	body.LDAPGroups = s.UserGroups
+	body.Tags = s.Tags
+	body.Protect = s.Protect != nil && *s.Protect
+	return nil
+}
+
+// ActionType is a Cartel call made to reconcile an instance
+type ActionType string
+
+const (
+	ActionCreate               ActionType = "create"
+	ActionAddTags              ActionType = "add_tags"
+	ActionAddSecurityGroups    ActionType = "add_security_groups"
+	ActionRemoveSecurityGroups ActionType = "remove_security_groups"
+	ActionAddUserGroups        ActionType = "add_user_groups"
+	ActionRemoveUserGroups     ActionType = "remove_user_groups"
+	ActionSetProtection        ActionType = "set_protection"
+)
+
+// Action is a step of an InstancePlan
+type Action struct {
+	Type    ActionType
+	Tags    map[string]string
+	Groups  []string
+	Protect bool
+}
+
+func (a Action) String() string {
+	switch a.Type {
+	case ActionAddTags:
+		keys := make([]string, 0, len(a.Tags))
+		for k := range a.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = k + "=" + a.Tags[k]
+		}
+		return fmt.Sprintf("%s %s", a.Type, strings.Join(pairs, ", "))
+	case ActionAddSecurityGroups, ActionRemoveSecurityGroups, ActionAddUserGroups, ActionRemoveUserGroups:
+		return fmt.Sprintf("%s %s", a.Type, strings.Join(a.Groups, ", "))
+	case ActionSetProtection:
+		return fmt.Sprintf("%s %t", a.Type, a.Protect)
+	}
+	return string(a.Type)
+}
+
+// InstancePlan holds the actions reconciling one instance
+type InstancePlan struct {
+	Spec    InstanceSpec
+	Actions []Action
+	// Drift lists differences that cannot be reconciled in place, e.g. the
+	// instance type. The instance must be recreated to resolve them
+	Drift []string
+}
+
+// Plan holds the instance plans of a fleet, in spec order
+type Plan struct {
+	Instances []InstancePlan
+}
+
+// Empty returns true when there is nothing to apply
+func (p Plan) Empty() bool {
+	for _, i := range p.Instances {
+		if len(i.Actions) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (p Plan) String() string {
+	var lines []string
+	for _, i := range p.Instances {
+		for _, a := range i.Actions {
+			lines = append(lines, fmt.Sprintf("%s: %s", i.Spec.NameTag, a))
+		}
+		for _, d := range i.Drift {
+			lines = append(lines, fmt.Sprintf("%s: drift %s", i.Spec.NameTag, d))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Diff returns the plan reconciling the current instance with spec. A nil
+// current instance is created
+func Diff(spec InstanceSpec, current *InstanceDetails) InstancePlan {
+	plan := InstancePlan{Spec: spec}
+	if current == nil {
+		plan.Actions = append(plan.Actions, Action{Type: ActionCreate})
+		return plan
+	}
+	drift := func(field, have, want string) {
+		if want != "" && have != want {
+			plan.Drift = append(plan.Drift, fmt.Sprintf("%s is %q, want %q", field, have, want))
+		}
+	}
+	drift("role", current.Role, spec.Role)
+	drift("instance_type", current.InstanceType, spec.InstanceType)
+	drift("subnet", current.Subnet, spec.Subnet)
+	drift("vpc_id", current.Vpc, spec.VpcID)
+	if spec.NumVolumes > 0 && len(current.BlockDevices) > 0 && len(current.BlockDevices)-1 != spec.NumVolumes {
+		drift("num_vols", strconv.Itoa(len(current.BlockDevices)-1), strconv.Itoa(spec.NumVolumes))
+	}
+
+	tags := make(map[string]string)
+	for k, v := range spec.Tags {
+		if have, ok := current.Tags[k]; !ok || have != v {
+			tags[k] = v
+		}
+	}
+	if len(tags) > 0 {
+		plan.Actions = append(plan.Actions, Action{Type: ActionAddTags, Tags: tags})
+	}
+	if spec.SecurityGroups != nil {
+		add, remove := difference(spec.SecurityGroups, current.SecurityGroups)
+		if len(add) > 0 {
+			plan.Actions = append(plan.Actions, Action{Type: ActionAddSecurityGroups, Groups: add})
+		}
+		if len(remove) > 0 {
+			plan.Actions = append(plan.Actions, Action{Type: ActionRemoveSecurityGroups, Groups: remove})
+		}
+	}
+	if spec.UserGroups != nil {
+		add, remove := difference(spec.UserGroups, current.LdapGroups)
+		if len(add) > 0 {
+			plan.Actions = append(plan.Actions, Action{Type: ActionAddUserGroups, Groups: add})
+		}
+		if len(remove) > 0 {
+			plan.Actions = append(plan.Actions, Action{Type: ActionRemoveUserGroups, Groups: remove})
+		}
+	}
+	if spec.Protect != nil && *spec.Protect != current.Protection {
+		plan.Actions = append(plan.Actions, Action{Type: ActionSetProtection, Protect: *spec.Protect})
+	}
+	return plan
+}
+
+// difference returns the sorted elements missing from have and those not in want
+func difference(want, have []string) (add, remove []string) {
+	inWant := make(map[string]bool)
+	for _, w := range want {
+		inWant[w] = true
+	}
+	inHave := make(map[string]bool)
+	for _, h := range have {
+		inHave[h] = true
+		if !inWant[h] {
+			remove = append(remove, h)
+		}
+	}
+	for w := range inWant {
+		if !inHave[w] {
+			add = append(add, w)
+		}
+	}
+	sort.Strings(add)
+	sort.Strings(remove)
+	return add, remove
+}
+
+// PlanFleet retrieves the current instances of the specs and returns the plan
+// reconciling them
+func (c *Client) PlanFleet(specs []InstanceSpec) (*Plan, error) {
+	if len(specs) == 0 {
+		return &Plan{}, nil
+	}
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = spec.NameTag
+	}
+	current, _, err := c.GetDetailsMulti(names...)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{}
+	for _, spec := range specs {
+		var details *InstanceDetails
+		if d, ok := (*current)[spec.NameTag]; ok {
+			details = &d
+		}
+		plan.Instances = append(plan.Instances, Diff(spec, details))
+	}
+	return plan, nil
+}
+
+// ApplyOptions control ApplyPlan
+type ApplyOptions struct {
+	// Concurrency is the maximum number of instances reconciled at once. Defaults to 4
+	Concurrency int
+	// WaitForCreate waits for the deployment of created instances to succeed
+	WaitForCreate bool
+}
+
+// InstanceResult is the outcome of reconciling an instance
+type InstanceResult struct {
+	NameTag string
+	// Applied lists the actions that succeeded
+	Applied []Action
+	Err     error
+}
+
+// ApplyPlan runs the actions of the plan with bounded concurrency. The actions
+// of an instance run in order and stop at the first failure. Every instance gets
+// a result, in plan order. Instances not yet started when ctx is done fail with
+// the context error
+func (c *Client) ApplyPlan(ctx context.Context, plan *Plan, options ApplyOptions) []InstanceResult {
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultApplyConcurrency
+	}
+	results := make([]InstanceResult, len(plan.Instances))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(results); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = c.applyInstance(ctx, plan.Instances[i], options)
+			}
+		}()
+	}
+	for i := range plan.Instances {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func (c *Client) applyInstance(ctx context.Context, plan InstancePlan, options ApplyOptions) InstanceResult {
+	result := InstanceResult{NameTag: plan.Spec.NameTag}
+	for _, action := range plan.Actions {
+		if err := ctx.Err(); err != nil {
+			result.Err = err
+			return result
+		}
+		if err := c.applyAction(ctx, plan.Spec, action, options); err != nil {
+			result.Err = fmt.Errorf("%s: %w", action.Type, err)
+			return result
+		}
+		result.Applied = append(result.Applied, action)
+	}
+	return result
+}
+
+func (c *Client) applyAction(ctx context.Context, spec InstanceSpec, action Action, options ApplyOptions) error {
+	instances := []string{spec.NameTag}
+	var err error
+	switch action.Type {
+	case ActionCreate:
+		var resp *CreateResponse
+		resp, _, err = c.Create(spec.NameTag, spec.requestBody)
+		if err == nil && !resp.Success() {
+			err = fmt.Errorf("%w: %s", ErrOperationFailed, resp.Description)
+		}
+		if err == nil && options.WaitForCreate {
+			_, err = c.WaitForState(ctx, spec.NameTag, StateSucceeded)
+		}
+	case ActionAddTags:
+		var resp *AddTagResponse
+		resp, _, err = c.AddTags(instances, action.Tags)
+		if err == nil && !resp.Success() {
+			err = fmt.Errorf("%w: %s", ErrOperationFailed, resp.Description)
+		}
+	case ActionAddSecurityGroups, ActionRemoveSecurityGroups:
+		var resp *SecurityGroupsResponse
+		if action.Type == ActionAddSecurityGroups {
+			resp, _, err = c.AddSecurityGroups(instances, action.Groups)
+		} else {
+			resp, _, err = c.RemoveSecurityGroups(instances, action.Groups)
+		}
+		if err == nil && !resp.Success() {
+			err = fmt.Errorf("%w: %s", ErrOperationFailed, resp.Description)
+		}
+	case ActionAddUserGroups, ActionRemoveUserGroups:
+		var resp *UserGroupsResponse
+		if action.Type == ActionAddUserGroups {
+			resp, _, err = c.AddUserGroups(instances, action.Groups)
+		} else {
+			resp, _, err = c.RemoveUserGroups(instances, action.Groups)
+		}
+		if err == nil && !resp.Success() {
+			err = fmt.Errorf("%w: %s", ErrOperationFailed, resp.Description)
+		}
+	case ActionSetProtection:
+		var resp *ProtectionResponse
+		resp, _, err = c.SetProtection(spec.NameTag, action.Protect)
+		if err == nil && !resp.Success() {
+			err = fmt.Errorf("%w: %s", ErrOperationFailed, resp.Description)
+		}
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnknownAction, action.Type)
+	}
+	return err
+}
+
+// FailedInstances returns the results with an error
+func FailedInstances(results []InstanceResult) []InstanceResult {
+	var failed []InstanceResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,177 @@
+package cartel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fleetYAML = `
+instances:
+  - name: web.dev
+    instance_type: m5.large
+    security_groups: [https-from-cf, tcp-8080]
+    ldap_groups: []
+    tags:
+      billing: team
+    protect: true
+  - name: worker.dev
+    encrypt_vols: true
+    vol_size: 50
+    num_vols: 1
+    security_groups: [tcp-8080]
+`
+
+func TestParseFleet(t *testing.T) {
+	fleet, err := ParseFleet([]byte(fleetYAML))
+	if !assert.Nil(t, err) {
+		return
+	}
+	if !assert.Len(t, fleet.Instances, 2) {
+		return
+	}
+	web := fleet.Instances[0]
+	assert.Equal(t, "web.dev", web.NameTag)
+	assert.NotNil(t, web.UserGroups, "an empty list manages the groups")
+	if assert.NotNil(t, web.Protect) {
+		assert.True(t, *web.Protect)
+	}
+	assert.Nil(t, fleet.Instances[1].UserGroups)
+	assert.Nil(t, fleet.Instances[1].Protect)
+
+	_, err = ParseFleet([]byte("instances:\n  - name: a.dev\n  - name: a.dev\n"))
+	assert.True(t, errors.Is(err, ErrInvalidFleet))
+	_, err = ParseFleet([]byte("instances:\n  - role: container-host\n"))
+	assert.True(t, errors.Is(err, ErrInvalidFleet))
+	_, err = ParseFleet([]byte("instances:\n  - name: a.dev\n    subnet_type: dmz\n"))
+	assert.True(t, errors.Is(err, ErrInvalidFleet))
+}
+
+func TestDiff(t *testing.T) {
+	protect := true
+	spec := InstanceSpec{
+		NameTag:        "web.dev",
+		InstanceType:   "m5.xlarge",
+		SecurityGroups: []string{"https-from-cf", "tcp-8080"},
+		UserGroups:     []string{},
+		Tags:           map[string]string{"billing": "team", "owner": "me"},
+		Protect:        &protect,
+		NumVolumes:     2,
+	}
+	current := &InstanceDetails{
+		BlockDevices:   []string{"/dev/sda1", "/dev/xvdb"},
+		InstanceType:   "m5.large",
+		SecurityGroups: []string{"base", "tcp-8080"},
+		LdapGroups:     LdapGroups{"old-group"},
+		Tags:           map[string]string{"billing": "team", "extra": "kept"},
+	}
+	plan := Diff(spec, current)
+	assert.Equal(t, []Action{
+		{Type: ActionAddTags, Tags: map[string]string{"owner": "me"}},
+		{Type: ActionAddSecurityGroups, Groups: []string{"https-from-cf"}},
+		{Type: ActionRemoveSecurityGroups, Groups: []string{"base"}},
+		{Type: ActionRemoveUserGroups, Groups: []string{"old-group"}},
+		{Type: ActionSetProtection, Protect: true},
+	}, plan.Actions)
+	assert.Equal(t, []string{
+		`instance_type is "m5.large", want "m5.xlarge"`,
+		`num_vols is "1", want "2"`,
+	}, plan.Drift)
+
+	// Unmanaged fields cause no actions
+	plan = Diff(InstanceSpec{NameTag: "web.dev"}, current)
+	assert.Empty(t, plan.Actions)
+
+	plan = Diff(spec, nil)
+	assert.Equal(t, []Action{{Type: ActionCreate}}, plan.Actions)
+}
+
+func TestReconcileFleet(t *testing.T) {
+	teardown, err := setup(t, &Config{
+		NoTLS:  true,
+		Token:  sharedToken,
+		Secret: sharedSecret,
+		Host:   "foo",
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var calls []string
+	record := func(name string, response string, status ...int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			r.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+			var request RequestBody
+			_ = json.Unmarshal(body, &request)
+			mu.Lock()
+			calls = append(calls, name+" "+strings.Join(request.NameTag, ","))
+			mu.Unlock()
+			endpointMocker([]byte(sharedSecret), response, status...)(w, r)
+		}
+	}
+	// No instance details are requested without specs
+	empty, err := client.PlanFleet(nil)
+	if assert.Nil(t, err) {
+		assert.True(t, empty.Empty())
+	}
+
+	muxCartel.HandleFunc("/v3/api/instance_details", endpointMocker([]byte(sharedSecret), `[
+  {"web.dev": {"instance_id": "i-1", "role": "container-host", "instance_type": "m5.large",
+    "security_groups": ["tcp-8080"], "ldap_groups": "old-group", "tags": {"billing": "team"}}}
+]`))
+	muxCartel.HandleFunc("/v3/api/add_security_groups", record("add_security_groups", `{"message": "ok"}`))
+	muxCartel.HandleFunc("/v3/api/remove_ldap_group", record("remove_ldap_group", `{"message": "ok"}`))
+	muxCartel.HandleFunc("/v3/api/protect", record("protect", `{"message": "failed"}`, http.StatusInternalServerError))
+	muxCartel.HandleFunc("/v3/api/create", record("create",
+		`{"message":[{"instance_id":"i-2","name":"worker.dev"}],"result":"Success"}`))
+
+	fleet, err := ParseFleet([]byte(fleetYAML))
+	if !assert.Nil(t, err) {
+		return
+	}
+	plan, err := client.PlanFleet(fleet.Instances)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.False(t, plan.Empty())
+	assert.Equal(t, `web.dev: add_security_groups https-from-cf
+web.dev: remove_user_groups old-group
+web.dev: set_protection true
+worker.dev: create`, plan.String())
+
+	results := client.ApplyPlan(context.Background(), plan, ApplyOptions{Concurrency: 2})
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	assert.Equal(t, "web.dev", results[0].NameTag)
+	assert.Len(t, results[0].Applied, 2)
+	assert.NotNil(t, results[0].Err)
+	assert.Nil(t, results[1].Err)
+	assert.Len(t, results[1].Applied, 1)
+	failed := FailedInstances(results)
+	if assert.Len(t, failed, 1) {
+		assert.Equal(t, "web.dev", failed[0].NameTag)
+	}
+	sort.Strings(calls)
+	assert.Equal(t, []string{
+		"add_security_groups web.dev",
+		"create worker.dev",
+		"protect web.dev",
+		"remove_ldap_group web.dev",
+	}, calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = client.ApplyPlan(ctx, plan, ApplyOptions{})
+	assert.Len(t, FailedInstances(results), 2)
+}
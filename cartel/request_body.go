@@ -21,6 +21,7 @@ type RequestBody struct {
 	Tags          map[string]string `json:"tags,omitempty"`
 	Protect       bool              `json:"protect"`
 	VpcId         string            `json:"vpc_id,omitempty"`
+	Rules         []SecurityRule    `json:"rules,omitempty"`
 }
 
 func (crb *RequestBody) ToJson() []byte {
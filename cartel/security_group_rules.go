@@ -0,0 +1,187 @@
+package cartel
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var groupNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Validate checks the protocol, port range and sources of the rule. Sources are
+// CIDR blocks or names of other security groups
+func (r SecurityRule) Validate() error {
+	switch r.Protocol {
+	case "tcp", "udp", "icmp":
+	default:
+		return fmt.Errorf("%w: protocol %q must be tcp, udp or icmp", ErrInvalidRule, r.Protocol)
+	}
+	from, to, err := r.Ports()
+	if err != nil {
+		return err
+	}
+	allICMP := r.Protocol == "icmp" && from == allPorts
+	if !allICMP && (from < 0 || to > 65535 || from > to) {
+		return fmt.Errorf("%w: port range %q", ErrInvalidRule, r.PortRange)
+	}
+	if len(r.Source) == 0 {
+		return fmt.Errorf("%w: no source", ErrInvalidRule)
+	}
+	for _, source := range r.Source {
+		if strings.Contains(source, "/") {
+			if _, _, err := net.ParseCIDR(source); err != nil {
+				return fmt.Errorf("%w: source %q: %v", ErrInvalidRule, source, err)
+			}
+		} else if !groupNameRegex.MatchString(source) {
+			return fmt.Errorf("%w: source %q is neither a CIDR block nor a security group", ErrInvalidRule, source)
+		}
+	}
+	return nil
+}
+
+func (r SecurityRule) String() string {
+	return fmt.Sprintf("%s/%s from %s", r.Protocol, r.PortRange, strings.Join(r.Source, ","))
+}
+
+// allPorts is the port range of ICMP rules covering all ICMP types
+const allPorts = -1
+
+// Ports parses the port range, "8080" or "8000-8080". ICMP rules without a
+// port range or with "-1" return -1 for both
+func (r SecurityRule) Ports() (from int, to int, err error) {
+	if r.Protocol == "icmp" {
+		if portRange := strings.TrimSpace(r.PortRange); portRange == "" || portRange == "-1" {
+			return allPorts, allPorts, nil
+		}
+	}
+	parts := strings.SplitN(r.PortRange, "-", 2)
+	if from, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil {
+		return 0, 0, fmt.Errorf("%w: port range %q", ErrInvalidRule, r.PortRange)
+	}
+	to = from
+	if len(parts) == 2 {
+		if to, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+			return 0, 0, fmt.Errorf("%w: port range %q", ErrInvalidRule, r.PortRange)
+		}
+	}
+	return from, to, nil
+}
+
+// Equal returns true when both rules have the same protocol, ports and sources,
+// regardless of source order
+func (r SecurityRule) Equal(other SecurityRule) bool {
+	if r.Protocol != other.Protocol || len(r.Source) != len(other.Source) {
+		return false
+	}
+	from, to, err := r.Ports()
+	otherFrom, otherTo, otherErr := other.Ports()
+	if err != nil || otherErr != nil {
+		if r.PortRange != other.PortRange {
+			return false
+		}
+	} else if from != otherFrom || to != otherTo {
+		return false
+	}
+	add, remove := difference(r.Source, other.Source)
+	return len(add) == 0 && len(remove) == 0
+}
+
+// CreateSecurityGroup creates a security group with the rules
+func (c *Client) CreateSecurityGroup(group string, rules ...SecurityRule) (*SecurityGroupsResponse, *Response, error) {
+	return c.securityGroupRequest("v3/api/create_security_group", group, rules, true)
+}
+
+// UpdateSecurityGroup replaces the rules of a security group
+func (c *Client) UpdateSecurityGroup(group string, rules ...SecurityRule) (*SecurityGroupsResponse, *Response, error) {
+	return c.securityGroupRequest("v3/api/update_security_group", group, rules, true)
+}
+
+// DeleteSecurityGroup deletes a security group. It must not be attached to any instance
+func (c *Client) DeleteSecurityGroup(group string) (*SecurityGroupsResponse, *Response, error) {
+	return c.securityGroupRequest("v3/api/delete_security_group", group, nil, false)
+}
+
+func (c *Client) securityGroupRequest(path, group string, rules []SecurityRule, withRules bool) (*SecurityGroupsResponse, *Response, error) {
+	if !groupNameRegex.MatchString(group) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrInvalidSecurityGroup, group)
+	}
+	if withRules && len(rules) == 0 {
+		return nil, nil, fmt.Errorf("%w: no rules", ErrInvalidRule)
+	}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+	var body RequestBody
+	body.SecurityGroup = []string{group}
+	body.Rules = rules
+
+	req, err := c.newRequest("POST", path, &body, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var responseBody SecurityGroupsResponse
+	resp, err := c.do(req, &responseBody)
+	return &responseBody, resp, err
+}
+
+// AddSecurityGroupRule adds a rule to a security group. Adding a rule the group
+// already has is a no-op and returns a nil response
+func (c *Client) AddSecurityGroupRule(group string, rule SecurityRule) (*SecurityGroupsResponse, *Response, error) {
+	rules, resp, err := c.GetSecurityGroupDetails(group)
+	if err != nil {
+		return nil, resp, err
+	}
+	for _, r := range *rules {
+		if r.Equal(rule) {
+			return nil, resp, nil
+		}
+	}
+	return c.UpdateSecurityGroup(group, append(*rules, rule)...)
+}
+
+// UpdateSecurityGroupRule replaces the rule equal to old with rule
+func (c *Client) UpdateSecurityGroupRule(group string, old, rule SecurityRule) (*SecurityGroupsResponse, *Response, error) {
+	rules, resp, err := c.GetSecurityGroupDetails(group)
+	if err != nil {
+		return nil, resp, err
+	}
+	updated := make([]SecurityRule, 0, len(*rules))
+	found := false
+	for _, r := range *rules {
+		if !found && r.Equal(old) {
+			found = true
+			r = rule
+		}
+		updated = append(updated, r)
+	}
+	if !found {
+		return nil, resp, fmt.Errorf("%w: rule %s in %s", ErrNotFound, old, group)
+	}
+	return c.UpdateSecurityGroup(group, updated...)
+}
+
+// RemoveSecurityGroupRule removes the rule equal to rule from a security group.
+// A group keeps at least one rule, use DeleteSecurityGroup to remove the last one
+func (c *Client) RemoveSecurityGroupRule(group string, rule SecurityRule) (*SecurityGroupsResponse, *Response, error) {
+	rules, resp, err := c.GetSecurityGroupDetails(group)
+	if err != nil {
+		return nil, resp, err
+	}
+	remaining := make([]SecurityRule, 0, len(*rules))
+	for _, r := range *rules {
+		if !r.Equal(rule) {
+			remaining = append(remaining, r)
+		}
+	}
+	if len(remaining) == len(*rules) {
+		return nil, resp, fmt.Errorf("%w: rule %s in %s", ErrNotFound, rule, group)
+	}
+	if len(remaining) == 0 {
+		return nil, resp, fmt.Errorf("%w: removing the last rule of %s", ErrInvalidRule, group)
+	}
+	return c.UpdateSecurityGroup(group, remaining...)
+}
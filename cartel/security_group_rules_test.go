@@ -0,0 +1,109 @@
+package cartel
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityRuleValidate(t *testing.T) {
+	valid := SecurityRule{Protocol: "tcp", PortRange: "8000-8080", Source: []string{"base", "10.0.0.0/8"}}
+	assert.Nil(t, valid.Validate())
+	for _, portRange := range []string{"-1", "", "8-8"} {
+		assert.Nil(t, SecurityRule{Protocol: "icmp", PortRange: portRange, Source: []string{"base"}}.Validate(), portRange)
+	}
+	for _, rule := range []SecurityRule{
+		{Protocol: "tcp", PortRange: "-1", Source: []string{"base"}},
+		{Protocol: "gre", PortRange: "80", Source: []string{"base"}},
+		{Protocol: "tcp", PortRange: "http", Source: []string{"base"}},
+		{Protocol: "tcp", PortRange: "8080-80", Source: []string{"base"}},
+		{Protocol: "tcp", PortRange: "70000", Source: []string{"base"}},
+		{Protocol: "tcp", PortRange: "80"},
+		{Protocol: "tcp", PortRange: "80", Source: []string{"10.0.0.0/33"}},
+		{Protocol: "tcp", PortRange: "80", Source: []string{"not a group"}},
+	} {
+		assert.True(t, errors.Is(rule.Validate(), ErrInvalidRule), rule.String())
+	}
+	assert.True(t, valid.Equal(SecurityRule{Protocol: "tcp", PortRange: "8000-8080", Source: []string{"10.0.0.0/8", "base"}}))
+	assert.True(t, SecurityRule{Protocol: "tcp", PortRange: "80", Source: []string{"base"}}.Equal(
+		SecurityRule{Protocol: "tcp", PortRange: "80-80", Source: []string{"base"}}))
+	assert.False(t, valid.Equal(SecurityRule{Protocol: "udp", PortRange: "8000-8080", Source: []string{"10.0.0.0/8", "base"}}))
+}
+
+func TestSecurityGroupRules(t *testing.T) {
+	teardown, err := setup(t, &Config{
+		Token:  sharedToken,
+		Secret: sharedSecret,
+		Host:   "foo",
+		NoTLS:  true,
+	})
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := map[string][]SecurityRule{
+		"tcp-1080": {{PortRange: "1080-1080", Protocol: "tcp", Source: []string{"base"}}},
+	}
+	store := func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(strings.NewReader(string(data)))
+		var body RequestBody
+		_ = json.Unmarshal(data, &body)
+		group := body.SecurityGroup[0]
+		switch r.URL.Path {
+		case "/v3/api/security_group_details":
+			response, _ := json.Marshal(map[string][]SecurityRule{group: groups[group]})
+			endpointMocker([]byte(sharedSecret), string(response))(w, r)
+			return
+		case "/v3/api/delete_security_group":
+			delete(groups, group)
+		default:
+			groups[group] = body.Rules
+		}
+		endpointMocker([]byte(sharedSecret), `{"message": "ok"}`)(w, r)
+	}
+	for _, path := range []string{"security_group_details", "create_security_group", "update_security_group", "delete_security_group"} {
+		muxCartel.HandleFunc("/v3/api/"+path, store)
+	}
+
+	https := SecurityRule{Protocol: "tcp", PortRange: "443", Source: []string{"0.0.0.0/0"}}
+	resp, _, err := client.CreateSecurityGroup("https", https)
+	if assert.Nil(t, err) {
+		assert.True(t, resp.Success())
+	}
+	assert.Equal(t, []SecurityRule{https}, groups["https"])
+	_, _, err = client.CreateSecurityGroup("empty")
+	assert.True(t, errors.Is(err, ErrInvalidRule))
+	_, _, err = client.CreateSecurityGroup("bad group", https)
+	assert.True(t, errors.Is(err, ErrInvalidSecurityGroup))
+
+	socks := SecurityRule{Protocol: "tcp", PortRange: "1081", Source: []string{"10.0.0.0/8"}}
+	_, _, err = client.AddSecurityGroupRule("tcp-1080", socks)
+	assert.Nil(t, err)
+	assert.Len(t, groups["tcp-1080"], 2)
+	resp, _, err = client.AddSecurityGroupRule("tcp-1080", socks)
+	assert.Nil(t, err)
+	assert.Nil(t, resp, "rule exists already")
+
+	narrowed := SecurityRule{Protocol: "tcp", PortRange: "1081", Source: []string{"10.1.0.0/16"}}
+	_, _, err = client.UpdateSecurityGroupRule("tcp-1080", socks, narrowed)
+	assert.Nil(t, err)
+	assert.Equal(t, narrowed, groups["tcp-1080"][1])
+	_, _, err = client.UpdateSecurityGroupRule("tcp-1080", socks, narrowed)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, _, err = client.RemoveSecurityGroupRule("tcp-1080", narrowed)
+	assert.Nil(t, err)
+	assert.Len(t, groups["tcp-1080"], 1)
+	_, _, err = client.RemoveSecurityGroupRule("tcp-1080", groups["tcp-1080"][0])
+	assert.True(t, errors.Is(err, ErrInvalidRule))
+
+	_, _, err = client.DeleteSecurityGroup("https")
+	assert.Nil(t, err)
+	assert.NotContains(t, groups, "https")
+}
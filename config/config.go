@@ -2,79 +2,248 @@
 package config
 
 import (
+	"bytes"
+	"context"
 	"embed"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// CanonicalURL is the canonical source of the configuration
+const CanonicalURL = "https://raw.githubusercontent.com/philips-software/go-hsdp-api/master/config/hsdp.json"
+
+// Layer identifies where a configuration value came from. Layers are listed
+// from lowest to highest precedence
+type Layer string
+
+const (
+	LayerNone     Layer = ""
+	LayerEmbedded Layer = "embedded"
+	LayerReader   Layer = "reader"
+	LayerRemote   Layer = "remote"
+	LayerFile     Layer = "file"
+	LayerEnv      Layer = "env"
+	LayerOverride Layer = "override"
 )
 
+// DefaultEnvPrefix is the prefix of environment variables used by FromEnv
+const DefaultEnvPrefix = "HSDP_"
+
 // Config holds the state of a Config instance
 type Config struct {
 	region      string
 	environment string
-	source      io.Reader
-	world       World
+	store       *store
 }
 
 type World struct {
-	Regions map[string]Region `json:"region"`
+	Regions map[string]Region `json:"region" toml:"region"`
 }
 type Region struct {
-	Environments map[string]Environment `json:"env,omitempty"`
-	Services     map[string]Service     `json:"service,omitempty"`
+	// Aliases are alternative names of the region, e.g. the AWS region name
+	Aliases []string `json:"alias,omitempty" toml:"alias"`
+	// Domains are the DNS domains service hosts of the region belong to
+	Domains []string `json:"domains,omitempty" toml:"domains"`
+	// SharedServices are hosted on a domain shared between regions and are
+	// not checked against Domains
+	SharedServices []string               `json:"shared_services,omitempty" toml:"shared_services"`
+	Environments   map[string]Environment `json:"env,omitempty" toml:"env"`
+	Services       map[string]Service     `json:"service,omitempty" toml:"service"`
 }
 
 type Environment struct {
-	Services map[string]Service `json:"service,omitempty"`
+	Services map[string]Service `json:"service,omitempty" toml:"service"`
 }
 
 // Service holds the relevant data for a service
 type Service struct {
-	URL    string `json:"url,omitempty"`
-	Domain string `json:"domain,omitempty"`
-	Host   string `json:"host,omitempty"`
+	URL    string `json:"url,omitempty" toml:"url"`
+	Domain string `json:"domain,omitempty" toml:"domain"`
+	Host   string `json:"host,omitempty" toml:"host"`
+	// Origin reports the layer each value came from
+	Origin ServiceOrigin `json:"-" toml:"-"`
+}
+
+// ServiceOrigin holds the layer of each Service value
+type ServiceOrigin struct {
+	URL    Layer
+	Domain Layer
+	Host   Layer
 }
 
 type OptionFunc func(*Config) error
 
+type layer struct {
+	name  Layer
+	world World
+}
+
+// store holds the layers shared by a Config and the instances derived from it
+type store struct {
+	sync.RWMutex
+	base      layer
+	remote    *layer
+	file      *layer
+	envPrefix string
+	overrides map[string]Service
+
+	remoteURL  string
+	httpClient *http.Client
+	etag       string
+	reader     io.Reader
+}
+
 //go:embed hsdp.json
 var cfg embed.FS
 
 // New returns a Config Instance. You can pass
-// a list OptionFunc to cater the Config to your needs
+// a list OptionFunc to cater the Config to your needs.
+// Values are resolved from the embedded defaults, or the FromReader source,
+// then the remote source, a local file, environment variables and overrides
 func New(opts ...OptionFunc) (*Config, error) {
-	config := &Config{}
+	config := &Config{store: &store{overrides: make(map[string]Service)}}
 	for _, opt := range opts {
 		if err := opt(config); err != nil {
 			return nil, err
 		}
 	}
-	var world World
+	s := config.store
+	if s.reader != nil {
+		data, err := ioutil.ReadAll(s.reader)
+		if err != nil {
+			return nil, err
+		}
+		world, err := parseWorld(data, "")
+		if err != nil {
+			return nil, err
+		}
+		s.base = layer{name: LayerReader, world: world}
+	} else {
+		world, err := embeddedWorld()
+		if err != nil {
+			return nil, err
+		}
+		s.base = layer{name: LayerEmbedded, world: world}
+	}
+	if s.remoteURL != "" {
+		// The embedded copy remains in use when the remote source is unavailable
+		_ = config.Refresh(context.Background())
+	}
+	// Aliases are declared in the data so resolve the region once it is loaded
+	config.region = config.regionMapping(config.region)
+	return config, nil
+}
+
+func embeddedWorld() (World, error) {
 	data, err := cfg.ReadFile("hsdp.json")
 	if err != nil {
-		return nil, err
+		return World{}, err
 	}
-	err = json.Unmarshal(data, &world)
-	if err != nil {
-		return nil, err
+	return parseWorld(data, ".json")
+}
+
+// parseWorld decodes JSON or TOML. Without an extension the format is detected
+func parseWorld(data []byte, ext string) (World, error) {
+	var world World
+	if ext == "" {
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			ext = ".json"
+		} else {
+			ext = ".toml"
+		}
 	}
-	config.world = world
-	return config, nil
+	switch strings.ToLower(ext) {
+	case ".toml":
+		if _, err := toml.Decode(string(data), &world); err != nil {
+			return world, err
+		}
+	default:
+		if err := json.Unmarshal(data, &world); err != nil {
+			return world, err
+		}
+	}
+	return world, nil
 }
 
-// FromReader option specifies the JSON source to read
-// If this option is not provided the canonical source
-// hosted on Github will be used. See CanonicalURL
+// FromReader option specifies the JSON or TOML source to use instead of the
+// embedded configuration
 func FromReader(reader io.Reader) OptionFunc {
 	return func(c *Config) error {
-		c.source = reader
+		c.store.reader = reader
+		return nil
+	}
+}
+
+// FromFile option layers a local JSON or TOML file, determined by extension,
+// over the embedded configuration
+func FromFile(path string) OptionFunc {
+	return func(c *Config) error {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		world, err := parseWorld(data, filepath.Ext(path))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		c.store.file = &layer{name: LayerFile, world: world}
+		return nil
+	}
+}
+
+// FromEnv option enables environment variables of the form
+// <prefix><SERVICE>_URL, _DOMAIN and _HOST, e.g. HSDP_IAM_URL. Dashes in service
+// names become underscores. They apply to every region and environment.
+// An empty prefix selects DefaultEnvPrefix
+func FromEnv(prefix string) OptionFunc {
+	return func(c *Config) error {
+		if prefix == "" {
+			prefix = DefaultEnvPrefix
+		}
+		c.store.envPrefix = prefix
+		return nil
+	}
+}
+
+// WithOverride option sets values of a service in every region and environment.
+// Empty fields are not overridden
+func WithOverride(service string, values Service) OptionFunc {
+	return func(c *Config) error {
+		c.store.overrides[service] = values
 		return nil
 	}
 }
 
-// WithRegion sets the region of the newly created Config instance
+// FromURL option retrieves the configuration from url, typically CanonicalURL.
+// When it cannot be retrieved the embedded configuration is used. Call Refresh
+// to update it later
+func FromURL(url string, httpClient *http.Client) OptionFunc {
+	return func(c *Config) error {
+		if httpClient == nil {
+			httpClient = &http.Client{Timeout: 30 * time.Second}
+		}
+		c.store.remoteURL = url
+		c.store.httpClient = httpClient
+		return nil
+	}
+}
+
+// WithRegion sets the region of the newly created Config instance.
+// A region alias, e.g. us-east-1, selects the region declaring it
 func WithRegion(region string) OptionFunc {
 	return func(c *Config) error {
-		c.region = c.regionMapping(region)
+		c.region = region
 		return nil
 	}
 }
@@ -90,10 +259,81 @@ func WithEnv(env string) OptionFunc {
 	}
 }
 
+// Refresh retrieves the remote configuration set with FromURL. The ETag of the
+// last response is sent so unchanged configuration is not downloaded again.
+// On failure the previous configuration remains in use
+func (c *Config) Refresh(ctx context.Context) error {
+	s := c.store
+	s.RLock()
+	remoteURL, etag, httpClient := s.remoteURL, s.etag, s.httpClient
+	s.RUnlock()
+	if remoteURL == "" {
+		return ErrNoRemoteSource
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, remoteURL, nil)
+	if err != nil {
+		return err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("%w: HTTP %d", ErrRefreshFailed, resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	ext := ""
+	if strings.HasSuffix(req.URL.Path, ".toml") {
+		ext = ".toml"
+	}
+	world, err := parseWorld(data, ext)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRefreshFailed, err)
+	}
+	if len(world.Regions) == 0 {
+		return fmt.Errorf("%w: no regions", ErrRefreshFailed)
+	}
+	s.Lock()
+	s.remote = &layer{name: LayerRemote, world: world}
+	s.etag = resp.Header.Get("ETag")
+	s.Unlock()
+	return nil
+}
+
+// StartRefresh calls Refresh every interval until ctx is done. Errors are passed
+// to onError when it is not nil
+func (c *Config) StartRefresh(ctx context.Context, interval time.Duration, onError func(error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.Refresh(ctx); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
+
 // Region returns a new Config instance with region set
 func (c *Config) Region(region string) *Config {
 	return &Config{
-		world:       c.world,
+		store:       c.store,
 		region:      c.regionMapping(region),
 		environment: c.environment,
 	}
@@ -102,68 +342,179 @@ func (c *Config) Region(region string) *Config {
 // Env returns a new Config instance with environment set
 func (c *Config) Env(environment string) *Config {
 	return &Config{
-		world:       c.world,
+		store:       c.store,
 		region:      c.region,
 		environment: environment,
 	}
 }
 
+// layers returns the data layers from lowest to highest precedence
+func (s *store) layers() []layer {
+	layers := []layer{s.base}
+	if s.remote != nil {
+		layers = append(layers, *s.remote)
+	}
+	if s.file != nil {
+		layers = append(layers, *s.file)
+	}
+	return layers
+}
+
 // Regions returns the known regions
 func (c *Config) Regions() []string {
+	c.store.RLock()
+	defer c.store.RUnlock()
+	seen := make(map[string]bool)
 	regions := make([]string, 0)
-	// region level
-	for k := range c.world.Regions {
-		regions = append(regions, k)
+	for _, l := range c.store.layers() {
+		for k := range l.world.Regions {
+			if !seen[k] {
+				seen[k] = true
+				regions = append(regions, k)
+			}
+		}
 	}
 	return regions
 }
 
 // Services returns a list of available services in the region
 func (c *Config) Services() []string {
+	c.store.RLock()
+	defer c.store.RUnlock()
+	seen := make(map[string]bool)
 	services := make([]string, 0)
-	// region level
-	if svcs, ok := c.world.Regions[c.region]; ok {
-		for s := range svcs.Services {
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
 			services = append(services, s)
 		}
 	}
-
-	// environment
-	if svcs, ok := c.world.Regions[c.region].Environments[c.environment]; ok {
-		for s := range svcs.Services {
-			services = append(services, s)
+	for _, l := range c.store.layers() {
+		// region level
+		if svcs, ok := l.world.Regions[c.region]; ok {
+			for s := range svcs.Services {
+				add(s)
+			}
+		}
+		// environment
+		if svcs, ok := l.world.Regions[c.region].Environments[c.environment]; ok {
+			for s := range svcs.Services {
+				add(s)
+			}
 		}
 	}
+	for _, s := range c.envServices() {
+		add(s)
+	}
+	overrides := make([]string, 0, len(c.store.overrides))
+	for s := range c.store.overrides {
+		overrides = append(overrides, s)
+	}
+	sort.Strings(overrides)
+	for _, s := range overrides {
+		add(s)
+	}
 	return services
 }
 
-// Service returns an instance scoped to the service in the region and environment
+// Service returns an instance scoped to the service in the region and environment.
+// Each value is taken from the highest layer defining it, see Service.Origin
 func (c *Config) Service(service string) *Service {
-	// Check if service is at region level
-	if regionService, ok := c.world.Regions[c.region]; ok {
+	c.store.RLock()
+	defer c.store.RUnlock()
+	result := &Service{}
+	for _, l := range c.store.layers() {
+		if found, ok := lookup(l.world, c.region, c.environment, service); ok {
+			result.merge(found, l.name)
+		}
+	}
+	if c.store.envPrefix != "" {
+		name := c.store.envPrefix + envName(service)
+		result.merge(Service{
+			URL:    os.Getenv(name + "_URL"),
+			Domain: os.Getenv(name + "_DOMAIN"),
+			Host:   os.Getenv(name + "_HOST"),
+		}, LayerEnv)
+	}
+	if override, ok := c.store.overrides[service]; ok {
+		result.merge(override, LayerOverride)
+	}
+	return result
+}
+
+// lookup finds the service at region level or else at environment level
+func lookup(world World, region, environment, service string) (Service, bool) {
+	if regionService, ok := world.Regions[region]; ok {
 		if service, ok := regionService.Services[service]; ok {
-			return &service
+			return service, true
 		}
-		if envService, ok := regionService.Environments[c.environment]; ok {
+		if envService, ok := regionService.Environments[environment]; ok {
 			if service, ok := envService.Services[service]; ok {
-				return &service
+				return service, true
+			}
+		}
+	}
+	return Service{}, false
+}
+
+func (s *Service) merge(values Service, from Layer) {
+	if values.URL != "" {
+		s.URL, s.Origin.URL = values.URL, from
+	}
+	if values.Domain != "" {
+		s.Domain, s.Origin.Domain = values.Domain, from
+	}
+	if values.Host != "" {
+		s.Host, s.Origin.Host = values.Host, from
+	}
+}
+
+// envServices returns the services configured through environment variables
+func (c *Config) envServices() []string {
+	prefix := c.store.envPrefix
+	if prefix == "" {
+		return nil
+	}
+	var services []string
+	for _, kv := range os.Environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		name = strings.TrimPrefix(name, prefix)
+		for _, suffix := range []string{"_URL", "_DOMAIN", "_HOST"} {
+			if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+				services = append(services, strings.ReplaceAll(strings.ToLower(strings.TrimSuffix(name, suffix)), "_", "-"))
+				break
 			}
 		}
 	}
-	return &Service{}
+	sort.Strings(services)
+	return services
 }
 
+func envName(service string) string {
+	return strings.ToUpper(strings.ReplaceAll(service, "-", "_"))
+}
+
+// regionMapping resolves a region alias declared in any data layer
 func (c *Config) regionMapping(region string) string {
-	switch region {
-	case "us-east-1":
-		return "us-east"
-	case "eu-west-1":
-		return "eu-west"
-	case "ap-se-2":
-		return "apac3"
-	case "sa-east-1":
-		return "sa1"
-	default:
-		return region
+	c.store.RLock()
+	defer c.store.RUnlock()
+	layers := c.store.layers()
+	for _, l := range layers {
+		if _, ok := l.world.Regions[region]; ok {
+			return region
+		}
+	}
+	for _, l := range layers {
+		for name, r := range l.world.Regions {
+			for _, alias := range r.Aliases {
+				if alias == region {
+					return name
+				}
+			}
+		}
 	}
+	return region
 }
@@ -0,0 +1,11 @@
+package config
+
+import (
+	"errors"
+)
+
+var (
+	ErrNoRemoteSource  = errors.New("no remote source configured")
+	ErrRefreshFailed   = errors.New("refreshing configuration failed")
+	ErrInvalidRegistry = errors.New("invalid registry data")
+)
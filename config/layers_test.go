@@ -0,0 +1,136 @@
+package config_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/philips-software/go-hsdp-api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromFileTOML(t *testing.T) {
+	c, err := config.New(config.FromFile("hsdp.toml"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	iam := c.Region("us-east").Env("client-test").Service("iam")
+	assert.Equal(t, "https://iam-client-test.us-east.philips-healthsuite.com", iam.URL)
+	assert.Equal(t, config.LayerFile, iam.Origin.URL)
+}
+
+func TestLayers(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "local.toml")
+	err := os.WriteFile(file, []byte(`
+[region.us-east.env.client-test.service.iam]
+url = "https://iam.local"
+[region.us-east.env.client-test.service.custom]
+url = "https://custom.local"
+host = "custom.local"
+`), 0600)
+	if !assert.Nil(t, err) {
+		return
+	}
+	t.Setenv("TESTCFG_CUSTOM_HOST", "custom.env")
+	t.Setenv("TESTCFG_LOG_QUERY_URL", "https://logquery.env")
+
+	c, err := config.New(
+		config.WithRegion("us-east-1"),
+		config.WithEnv("client-test"),
+		config.FromFile(file),
+		config.FromEnv("TESTCFG_"),
+		config.WithOverride("idm", config.Service{URL: "https://idm.override"}),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	iam := c.Service("iam")
+	assert.Equal(t, "https://iam.local", iam.URL)
+	assert.Equal(t, config.LayerFile, iam.Origin.URL)
+
+	custom := c.Service("custom")
+	assert.Equal(t, "https://custom.local", custom.URL)
+	assert.Equal(t, "custom.env", custom.Host)
+	assert.Equal(t, config.ServiceOrigin{URL: config.LayerFile, Host: config.LayerEnv}, custom.Origin)
+
+	idm := c.Service("idm")
+	assert.Equal(t, "https://idm.override", idm.URL)
+	assert.Equal(t, config.LayerOverride, idm.Origin.URL)
+
+	assert.Equal(t, "https://logquery.env", c.Service("log-query").URL)
+	assert.Equal(t, config.LayerEmbedded, c.Service("s3creds").Origin.URL)
+	assert.Equal(t, config.LayerNone, c.Service("bogus").Origin.URL)
+
+	services := c.Services()
+	assert.Contains(t, services, "custom")
+	assert.Contains(t, services, "log-query")
+
+	_, err = config.New(config.FromFile(filepath.Join(dir, "missing.json")))
+	assert.NotNil(t, err)
+}
+
+func TestRefresh(t *testing.T) {
+	requests := 0
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, `{"region":{"us-east":{"env":{"client-test":{"service":{"iam":{"url":"https://iam.remote"}}}}}}}`)
+	}))
+	defer server.Close()
+
+	c, err := config.New(
+		config.WithRegion("us-east"),
+		config.WithEnv("client-test"),
+		config.FromURL(server.URL+"/hsdp.json", nil),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+	iam := c.Service("iam")
+	assert.Equal(t, "https://iam.remote", iam.URL)
+	assert.Equal(t, config.LayerRemote, iam.Origin.URL)
+	// Values missing from the remote copy come from the embedded one
+	assert.Equal(t, config.LayerEmbedded, c.Service("idm").Origin.URL)
+
+	assert.Nil(t, c.Refresh(context.Background()))
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, "https://iam.remote", c.Service("iam").URL)
+
+	fail = true
+	err = c.Refresh(context.Background())
+	assert.True(t, errors.Is(err, config.ErrRefreshFailed))
+	assert.Equal(t, "https://iam.remote", c.Service("iam").URL)
+
+	// An unreachable source falls back to the embedded configuration
+	c, err = config.New(
+		config.WithRegion("us-east"),
+		config.WithEnv("client-test"),
+		config.FromURL(server.URL+"/hsdp.json", nil),
+	)
+	if !assert.Nil(t, err) {
+		return
+	}
+	iam = c.Service("iam")
+	assert.True(t, strings.HasPrefix(iam.URL, "https://iam-client-test"))
+	assert.Equal(t, config.LayerEmbedded, iam.Origin.URL)
+
+	c, _ = config.New()
+	assert.Equal(t, config.ErrNoRemoteSource, c.Refresh(context.Background()))
+}
@@ -0,0 +1,345 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Severity of a validation Issue
+type Severity string
+
+const (
+	// SeverityError marks data that is wrong, e.g. a malformed URL
+	SeverityError Severity = "error"
+	// SeverityWarning marks data that is suspicious, e.g. a host outside the region domains
+	SeverityWarning Severity = "warning"
+)
+
+// KnownEnvironments lists the environment names used in the registry
+var KnownEnvironments = []string{"client-test", "dev", "prod", "sandbox"}
+
+var (
+	nameRegex  = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	labelRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+)
+
+// Issue is a problem found in registry data
+type Issue struct {
+	Severity    Severity
+	Region      string
+	Environment string
+	Service     string
+	// Field is the offending key, e.g. url, host or alias
+	Field   string
+	Message string
+}
+
+func (i Issue) String() string {
+	path := i.Region
+	if i.Environment != "" {
+		path += "/" + i.Environment
+	}
+	if i.Service != "" {
+		path += "/" + i.Service
+	}
+	if i.Field != "" {
+		path += " " + i.Field
+	}
+	if path == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, strings.TrimSpace(path), i.Message)
+}
+
+// Issues is the result of a validation
+type Issues []Issue
+
+// Errors returns the issues with SeverityError
+func (issues Issues) Errors() Issues {
+	return issues.filter(SeverityError)
+}
+
+// Warnings returns the issues with SeverityWarning
+func (issues Issues) Warnings() Issues {
+	return issues.filter(SeverityWarning)
+}
+
+func (issues Issues) filter(severity Severity) Issues {
+	var filtered Issues
+	for _, i := range issues {
+		if i.Severity == severity {
+			filtered = append(filtered, i)
+		}
+	}
+	return filtered
+}
+
+// Err returns an ErrInvalidRegistry error listing the errors, or nil when
+// there are only warnings
+func (issues Issues) Err() error {
+	errs := issues.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.String()
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidRegistry, strings.Join(messages, "; "))
+}
+
+// Validate checks JSON or TOML registry data. ext is ".json" or ".toml", when
+// empty the format is detected. Besides the checks of ValidateWorld unknown keys,
+// e.g. an environment table missing its env level, are reported.
+// An error is returned when the data cannot be parsed at all
+func Validate(data []byte, ext string) (Issues, error) {
+	var raw map[string]interface{}
+	if ext == "" {
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+			ext = ".json"
+		} else {
+			ext = ".toml"
+		}
+	}
+	switch strings.ToLower(ext) {
+	case ".toml":
+		if _, err := toml.Decode(string(data), &raw); err != nil {
+			return nil, err
+		}
+	default:
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+	}
+	world, err := parseWorld(data, ext)
+	if err != nil {
+		return nil, err
+	}
+	issues := unknownKeys(raw)
+	return append(issues, ValidateWorld(world)...), nil
+}
+
+// ValidateWorld checks that
+//   - region and environment names are lowercase and dash separated
+//   - environments are one of KnownEnvironments
+//   - region aliases are unique and do not shadow a region
+//   - every service has a URL or host, and that these are well formed
+//   - service hosts belong to one of the region domains, when declared, unless
+//     the service is listed in the shared services of the region
+func ValidateWorld(world World) Issues {
+	var issues Issues
+	aliases := make(map[string]string)
+	for _, region := range sortedKeys(world.Regions) {
+		r := world.Regions[region]
+		if !nameRegex.MatchString(region) {
+			issues = append(issues, Issue{Severity: SeverityError, Region: region,
+				Message: "region name must be lowercase letters, digits and dashes"})
+		}
+		for _, alias := range r.Aliases {
+			switch {
+			case !nameRegex.MatchString(alias):
+				issues = append(issues, Issue{Severity: SeverityError, Region: region, Field: "alias",
+					Message: fmt.Sprintf("alias %q must be lowercase letters, digits and dashes", alias)})
+			case aliases[alias] != "":
+				issues = append(issues, Issue{Severity: SeverityError, Region: region, Field: "alias",
+					Message: fmt.Sprintf("alias %q is also declared by region %s", alias, aliases[alias])})
+			default:
+				if _, ok := world.Regions[alias]; ok {
+					issues = append(issues, Issue{Severity: SeverityError, Region: region, Field: "alias",
+						Message: fmt.Sprintf("alias %q is the name of a region", alias)})
+				}
+			}
+			if aliases[alias] == "" {
+				aliases[alias] = region
+			}
+		}
+		for _, domain := range r.Domains {
+			if !validHostname(domain) {
+				issues = append(issues, Issue{Severity: SeverityError, Region: region, Field: "domains",
+					Message: fmt.Sprintf("invalid domain %q", domain)})
+			}
+		}
+		for _, service := range sortedKeys(r.Services) {
+			issues = append(issues, validateService(r, region, "", service, r.Services[service])...)
+		}
+		for _, env := range sortedKeys(r.Environments) {
+			if !nameRegex.MatchString(env) {
+				issues = append(issues, Issue{Severity: SeverityError, Region: region, Environment: env,
+					Message: "environment name must be lowercase letters, digits and dashes"})
+			} else if !knownEnvironment(env) {
+				issues = append(issues, Issue{Severity: SeverityWarning, Region: region, Environment: env,
+					Message: fmt.Sprintf("unknown environment, expected one of %s", strings.Join(KnownEnvironments, ", "))})
+			}
+			services := r.Environments[env].Services
+			for _, service := range sortedKeys(services) {
+				if _, ok := r.Services[service]; ok {
+					issues = append(issues, Issue{Severity: SeverityWarning, Region: region, Environment: env, Service: service,
+						Message: "service is also defined at region level, which takes precedence"})
+				}
+				issues = append(issues, validateService(r, region, env, service, services[service])...)
+			}
+		}
+	}
+	return issues
+}
+
+// Validate checks the data layers of the configuration, see ValidateWorld.
+// Issues of every layer are combined
+func (c *Config) Validate() Issues {
+	c.store.RLock()
+	defer c.store.RUnlock()
+	var issues Issues
+	for _, l := range c.store.layers() {
+		issues = append(issues, ValidateWorld(l.world)...)
+	}
+	return issues
+}
+
+func validateService(r Region, region, env, name string, service Service) Issues {
+	var issues Issues
+	issue := func(severity Severity, field, format string, args ...interface{}) {
+		issues = append(issues, Issue{Severity: severity, Region: region, Environment: env,
+			Service: name, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if !nameRegex.MatchString(name) {
+		issue(SeverityError, "", "service name must be lowercase letters, digits and dashes")
+	}
+	domains := r.Domains
+	for _, shared := range r.SharedServices {
+		if shared == name {
+			domains = nil
+		}
+	}
+	if service.URL == "" && service.Host == "" {
+		issue(SeverityError, "", "service has neither url nor host")
+	}
+	if service.URL != "" {
+		u, err := url.Parse(service.URL)
+		switch {
+		case err != nil:
+			issue(SeverityError, "url", "invalid url: %v", err)
+		case u.Scheme == "" || u.Host == "":
+			issue(SeverityError, "url", "url %q must be absolute", service.URL)
+		case !validHostname(u.Hostname()):
+			issue(SeverityError, "url", "invalid host %q", u.Hostname())
+		case !inDomains(u.Hostname(), domains):
+			issue(SeverityWarning, "url", "host %s is not in the region domains", u.Hostname())
+		}
+	}
+	if service.Host != "" {
+		switch {
+		case !validHostname(service.Host):
+			issue(SeverityError, "host", "invalid host %q", service.Host)
+		case !inDomains(service.Host, domains):
+			issue(SeverityWarning, "host", "host %s is not in the region domains", service.Host)
+		}
+	}
+	if service.Domain != "" && !validHostname(service.Domain) {
+		issue(SeverityError, "domain", "invalid domain %q", service.Domain)
+	}
+	return issues
+}
+
+// unknownKeys reports keys that do not map onto World
+func unknownKeys(raw map[string]interface{}) Issues {
+	var issues Issues
+	unknown := func(region, env, service, key string) {
+		issues = append(issues, Issue{Severity: SeverityError, Region: region, Environment: env,
+			Service: service, Field: key, Message: "unknown key"})
+	}
+	for _, key := range sortedKeys(raw) {
+		if key != "region" {
+			unknown("", "", "", key)
+		}
+	}
+	services := func(region, env string, value interface{}) {
+		svcs, _ := value.(map[string]interface{})
+		for _, service := range sortedKeys(svcs) {
+			fields, _ := svcs[service].(map[string]interface{})
+			for _, key := range sortedKeys(fields) {
+				if key != "url" && key != "domain" && key != "host" {
+					unknown(region, env, service, key)
+				}
+			}
+		}
+	}
+	regions, _ := raw["region"].(map[string]interface{})
+	for _, region := range sortedKeys(regions) {
+		r, _ := regions[region].(map[string]interface{})
+		for _, key := range sortedKeys(r) {
+			switch key {
+			case "alias", "domains", "shared_services":
+			case "service":
+				services(region, "", r[key])
+			case "env":
+				envs, _ := r[key].(map[string]interface{})
+				for _, env := range sortedKeys(envs) {
+					e, _ := envs[env].(map[string]interface{})
+					for _, key := range sortedKeys(e) {
+						if key == "service" {
+							services(region, env, e[key])
+						} else {
+							unknown(region, env, "", key)
+						}
+					}
+				}
+			default:
+				unknown(region, "", "", key)
+			}
+		}
+	}
+	return issues
+}
+
+func knownEnvironment(env string) bool {
+	for _, known := range KnownEnvironments {
+		if env == known {
+			return true
+		}
+	}
+	return false
+}
+
+func validHostname(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if !labelRegex.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+// inDomains reports whether host is one of domains or a subdomain of one.
+// Without domains every host matches
+func inDomains(host string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/philips-software/go-hsdp-api/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRegistry(t *testing.T) {
+	for _, file := range []string{"hsdp.json", "hsdp.toml"} {
+		data, err := os.ReadFile(file)
+		if !assert.Nil(t, err) {
+			return
+		}
+		issues, err := config.Validate(data, "")
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Empty(t, issues, file)
+	}
+
+	c, err := config.New()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, c.Validate().Err())
+}
+
+func TestValidate(t *testing.T) {
+	issues, err := config.Validate([]byte(`
+[region.us-east]
+alias = ["us-east-1"]
+domains = ["us-east.example.com"]
+shared_services = ["cartel"]
+[region.eu-west]
+alias = ["us-east-1", "us-east"]
+[region.us-east.service.cf]
+url = "https://api.elsewhere.com"
+[region.us-east.service.cartel]
+host = "cartel-na1.cloud.phsdp.com"
+[region.us-east.service.vault-proxy]
+url = "https://vproxy.cloud.phsdp.com"
+[region.us-east.env.client-test.service.iam]
+url = "iam.us-east.example.com"
+[region.us-east.env.client-test.service.idm]
+domain = "example.com"
+[region.us-east.env.staging.service.logging]
+host = "bad_host.us-east.example.com"
+[region.us-east.prod.service.s3creds]
+url = "https://s3creds.us-east.example.com"
+`), ".toml")
+	if !assert.Nil(t, err) {
+		return
+	}
+	messages := make([]string, 0, len(issues))
+	for _, i := range issues {
+		messages = append(messages, i.String())
+	}
+	assert.Equal(t, []string{
+		"error: us-east prod: unknown key",
+		`error: eu-west alias: alias "us-east" is the name of a region`,
+		`error: us-east alias: alias "us-east-1" is also declared by region eu-west`,
+		"warning: us-east/cf url: host api.elsewhere.com is not in the region domains",
+		"warning: us-east/vault-proxy url: host vproxy.cloud.phsdp.com is not in the region domains",
+		`error: us-east/client-test/iam url: url "iam.us-east.example.com" must be absolute`,
+		"error: us-east/client-test/idm: service has neither url nor host",
+		"warning: us-east/staging: unknown environment, expected one of client-test, dev, prod, sandbox",
+		`error: us-east/staging/logging host: invalid host "bad_host.us-east.example.com"`,
+	}, messages)
+	assert.Len(t, issues.Errors(), 6)
+	assert.Len(t, issues.Warnings(), 3)
+	assert.True(t, errors.Is(issues.Err(), config.ErrInvalidRegistry))
+	assert.Nil(t, issues.Warnings().Err())
+
+	_, err = config.Validate([]byte(`{"region": `), ".json")
+	assert.NotNil(t, err)
+}
+
+func TestRegionAliases(t *testing.T) {
+	c, err := config.New(config.WithRegion("ap-se-2"), config.WithEnv("prod"))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, strings.HasSuffix(c.Service("iam").URL, ".ap3.hsdp.io"))
+	assert.Equal(t, c.Service("s3creds").URL, c.Region("apac3").Service("s3creds").URL)
+
+	c, err = config.New(config.FromReader(strings.NewReader(`
+[region.local]
+alias = ["laptop"]
+[region.local.env.dev.service.iam]
+url = "https://iam.local"
+`)))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "https://iam.local", c.Region("laptop").Env("dev").Service("iam").URL)
+	assert.Equal(t, "", c.Region("us-east-1").Env("dev").Service("iam").URL)
+}
@@ -0,0 +1,250 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/philips-software/go-hsdp-api/blr"
+	"github.com/philips-software/go-hsdp-api/cdr"
+	"github.com/philips-software/go-hsdp-api/connect/mdm"
+	"github.com/philips-software/go-hsdp-api/logging"
+	"github.com/philips-software/go-hsdp-api/notification"
+)
+
+// Services the Bootstrap can build clients for
+const (
+	ServiceCDR          = "cdr"
+	ServiceBLR          = "blr"
+	ServiceMDM          = "mdm"
+	ServiceNotification = "notification"
+	ServiceLogging      = "logging"
+)
+
+const defaultCatalogTTL = 15 * time.Minute
+
+// DefaultServiceNames maps the bootstrap services to the names or tags under
+// which they can appear in the discovery catalog
+var DefaultServiceNames = map[string][]string{
+	ServiceCDR:          {"cdr", "cdr-r4", "cdr-stu3"},
+	ServiceBLR:          {"blr", "blob-repository"},
+	ServiceMDM:          {"connect-mdm", "mdm"},
+	ServiceNotification: {"notification"},
+	ServiceLogging:      {"logging", "log-ingestor"},
+}
+
+// BootstrapConfig configures a Bootstrap
+type BootstrapConfig struct {
+	// TTL is how long the catalog is cached. Defaults to 15 minutes
+	TTL time.Duration
+	// ServiceNames overrides DefaultServiceNames per service
+	ServiceNames map[string][]string
+}
+
+// Bootstrap builds service clients from the discovery catalog of the tenant.
+// The catalog is retrieved once and cached for the TTL
+type Bootstrap struct {
+	client *Client
+	config BootstrapConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	services []Service
+	expires  time.Time
+}
+
+// Clients holds the clients built by Bootstrap.Clients. Clients of services
+// the tenant does not have are nil and listed in Missing
+type Clients struct {
+	CDR          *cdr.Client
+	BLR          *blr.Client
+	MDM          *mdm.Client
+	Notification *notification.Client
+	Logging      *logging.Client
+	Missing      []string
+}
+
+// ClientsConfig holds the service specific configuration for Bootstrap.Clients.
+// Base URLs are filled in from the catalog
+type ClientsConfig struct {
+	CDR          cdr.Config
+	BLR          blr.Config
+	MDM          mdm.Config
+	Notification notification.Config
+	Logging      logging.Config
+}
+
+// NewBootstrap returns a Bootstrap using the discovery client
+func NewBootstrap(client *Client, config BootstrapConfig) *Bootstrap {
+	if config.TTL <= 0 {
+		config.TTL = defaultCatalogTTL
+	}
+	return &Bootstrap{client: client, config: config, now: time.Now}
+}
+
+// Services returns the cached catalog, retrieving it when it expired
+func (b *Bootstrap) Services() ([]Service, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.services != nil && b.now().Before(b.expires) {
+		return b.services, nil
+	}
+	services, _, err := b.client.GetServices()
+	if err != nil {
+		return nil, err
+	}
+	if services == nil {
+		return nil, ErrEmptyResult
+	}
+	b.services = *services
+	b.expires = b.now().Add(b.config.TTL)
+	return b.services, nil
+}
+
+// Invalidate drops the cached catalog
+func (b *Bootstrap) Invalidate() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.services = nil
+}
+
+// URL returns the first URL of the service from the catalog. It returns
+// ErrServiceNotAvailable when the tenant does not have the service
+func (b *Bootstrap) URL(service string) (string, error) {
+	services, err := b.Services()
+	if err != nil {
+		return "", err
+	}
+	names := b.config.ServiceNames[service]
+	if len(names) == 0 {
+		names = DefaultServiceNames[service]
+	}
+	if len(names) == 0 {
+		names = []string{service}
+	}
+	for _, name := range names {
+		for _, s := range services {
+			if (strings.EqualFold(s.Name, name) || strings.EqualFold(s.Tag, name)) && len(s.URLS) > 0 {
+				return s.URLS[0], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrServiceNotAvailable, service)
+}
+
+// Missing returns the bootstrap services the tenant does not have
+func (b *Bootstrap) Missing() ([]string, error) {
+	if _, err := b.Services(); err != nil {
+		return nil, err
+	}
+	var missing []string
+	for service := range DefaultServiceNames {
+		if _, err := b.URL(service); err != nil {
+			missing = append(missing, service)
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
+
+// CDR returns a CDR client for the discovered FHIR store
+func (b *Bootstrap) CDR(config cdr.Config) (*cdr.Client, error) {
+	if config.CDRURL == "" && config.FHIRStore == "" {
+		u, err := b.URL(ServiceCDR)
+		if err != nil {
+			return nil, err
+		}
+		// The catalog lists the CDR host, the client expects the FHIR store path
+		if !strings.Contains(u, "/store/") {
+			u = strings.TrimSuffix(u, "/") + "/store/fhir/"
+		}
+		config.CDRURL = u
+	}
+	return cdr.NewClient(b.client.Client, &config)
+}
+
+// BLR returns a Blob Repository client
+func (b *Bootstrap) BLR(config blr.Config) (*blr.Client, error) {
+	if config.BaseURL == "" {
+		u, err := b.URL(ServiceBLR)
+		if err != nil {
+			return nil, err
+		}
+		config.BaseURL = u
+	}
+	return blr.NewClient(b.client.Client, &config)
+}
+
+// MDM returns a Connect MDM client
+func (b *Bootstrap) MDM(config mdm.Config) (*mdm.Client, error) {
+	if config.BaseURL == "" {
+		u, err := b.URL(ServiceMDM)
+		if err != nil {
+			return nil, err
+		}
+		config.BaseURL = u
+	}
+	return mdm.NewClient(b.client.Client, &config)
+}
+
+// Notification returns a Notification client
+func (b *Bootstrap) Notification(config notification.Config) (*notification.Client, error) {
+	if config.NotificationURL == "" {
+		u, err := b.URL(ServiceNotification)
+		if err != nil {
+			return nil, err
+		}
+		config.NotificationURL = u
+	}
+	return notification.NewClient(b.client.Client, &config)
+}
+
+// Logging returns a logging client. Without shared credentials it authenticates
+// with the IAM client of the discovery client
+func (b *Bootstrap) Logging(config logging.Config) (*logging.Client, error) {
+	if config.BaseURL == "" {
+		u, err := b.URL(ServiceLogging)
+		if err != nil {
+			return nil, err
+		}
+		config.BaseURL = strings.TrimSuffix(u, "/")
+	}
+	if config.SharedKey == "" && config.IAMClient == nil {
+		config.IAMClient = b.client.Client
+	}
+	return logging.NewClient(nil, &config)
+}
+
+// Clients builds a client for every service in the catalog. Services the tenant
+// does not have are listed in Clients.Missing. Other errors abort
+func (b *Bootstrap) Clients(config ClientsConfig) (*Clients, error) {
+	if _, err := b.Services(); err != nil {
+		return nil, err
+	}
+	clients := &Clients{}
+	var err error
+	for _, service := range []string{ServiceBLR, ServiceCDR, ServiceLogging, ServiceMDM, ServiceNotification} {
+		if _, urlErr := b.URL(service); urlErr != nil {
+			clients.Missing = append(clients.Missing, service)
+			continue
+		}
+		switch service {
+		case ServiceBLR:
+			clients.BLR, err = b.BLR(config.BLR)
+		case ServiceCDR:
+			clients.CDR, err = b.CDR(config.CDR)
+		case ServiceLogging:
+			clients.Logging, err = b.Logging(config.Logging)
+		case ServiceMDM:
+			clients.MDM, err = b.MDM(config.MDM)
+		case ServiceNotification:
+			clients.Notification, err = b.Notification(config.Notification)
+		}
+		if err != nil {
+			return clients, fmt.Errorf("%s: %w", service, err)
+		}
+	}
+	return clients, nil
+}
@@ -0,0 +1,98 @@
+package discovery_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/philips-software/go-hsdp-api/discovery"
+	"github.com/philips-software/go-hsdp-api/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveCatalog(t *testing.T) *int {
+	calls := 0
+	muxDiscovery.HandleFunc("/client-test/core/discovery/Service", func(w http.ResponseWriter, r *http.Request) {
+		if !assert.Equal(t, http.MethodGet, r.Method) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{
+  "resourceType": "Bundle",
+  "type": "searchset",
+  "total": 4,
+  "entry": [
+    {"resource": {"resourceType": "Service", "id": "1", "name": "Clinical Data Repository", "tag": "cdr", "urls": ["https://cdr.example.com"]}},
+    {"resource": {"resourceType": "Service", "id": "2", "name": "blr", "urls": ["https://blr.example.com/connect/blobrepository"]}},
+    {"resource": {"resourceType": "Service", "id": "3", "name": "Notification", "urls": ["https://notification.example.com"]}},
+    {"resource": {"resourceType": "Service", "id": "4", "name": "logging", "urls": ["https://logging.example.com/"]}}
+  ]
+}`)
+	})
+	return &calls
+}
+
+func TestBootstrapClients(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+	calls := serveCatalog(t)
+
+	bootstrap := discovery.NewBootstrap(discoveryClient, discovery.BootstrapConfig{})
+
+	u, err := bootstrap.URL(discovery.ServiceNotification)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://notification.example.com", u)
+	_, err = bootstrap.URL(discovery.ServiceMDM)
+	assert.True(t, errors.Is(err, discovery.ErrServiceNotAvailable))
+
+	missing, err := bootstrap.Missing()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{discovery.ServiceMDM}, missing)
+
+	clients, err := bootstrap.Clients(discovery.ClientsConfig{
+		Logging: logging.Config{ProductKey: "product-key"},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{discovery.ServiceMDM}, clients.Missing)
+	assert.Nil(t, clients.MDM)
+	if assert.NotNil(t, clients.CDR) {
+		assert.Equal(t, "https://cdr.example.com/store/fhir/", clients.CDR.GetFHIRStoreURL())
+	}
+	if assert.NotNil(t, clients.BLR) {
+		assert.Equal(t, "https://blr.example.com/connect/blobrepository/", clients.BLR.GetBaseURL())
+	}
+	assert.NotNil(t, clients.Notification)
+	assert.NotNil(t, clients.Logging)
+
+	// The catalog was retrieved once
+	assert.Equal(t, 1, *calls)
+	bootstrap.Invalidate()
+	_, _ = bootstrap.Services()
+	assert.Equal(t, 2, *calls)
+}
+
+func TestBootstrapTTL(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+	calls := serveCatalog(t)
+
+	bootstrap := discovery.NewBootstrap(discoveryClient, discovery.BootstrapConfig{
+		TTL:          20 * time.Millisecond,
+		ServiceNames: map[string][]string{discovery.ServiceMDM: {"clinical data repository"}},
+	})
+	u, err := bootstrap.URL(discovery.ServiceMDM)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://cdr.example.com", u)
+	_, _ = bootstrap.Services()
+	assert.Equal(t, 1, *calls)
+	time.Sleep(30 * time.Millisecond)
+	_, _ = bootstrap.Services()
+	assert.Equal(t, 2, *calls)
+}
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/philips-software/go-hsdp-api/ai"
+	"github.com/philips-software/go-hsdp-api/discovery"
 	"github.com/philips-software/go-hsdp-api/iam"
 	"github.com/stretchr/testify/assert"
 )
@@ -21,6 +22,10 @@ var (
 	muxAI     *http.ServeMux
 	serverAI  *httptest.Server
 
+	muxDiscovery    *http.ServeMux
+	serverDiscovery *httptest.Server
+	discoveryClient *discovery.Client
+
 	iamClient  *iam.Client
 	aiClient   *ai.Client
 	aiTenantID = "48a0183d-a588-41c2-9979-737d15e9e860"
@@ -34,6 +39,8 @@ func setup(t *testing.T) func() {
 	serverIDM = httptest.NewServer(muxIDM)
 	muxAI = http.NewServeMux()
 	serverAI = httptest.NewServer(muxAI)
+	muxDiscovery = http.NewServeMux()
+	serverDiscovery = httptest.NewServer(muxDiscovery)
 
 	var err error
 
@@ -60,7 +67,7 @@ func setup(t *testing.T) func() {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		_, _ = io.WriteString(w, `{
-    "scope": "mail tdr.contract tdr.dataitem",
+    "scope": "mail tdr.contract tdr.dataitem ?.?.dsc.service.readAny",
     "access_token": "`+token+`",
     "refresh_token": "31f1a449-ef8e-4bfc-a227-4f2353fde547",
     "expires_in": 1799,
@@ -137,10 +144,19 @@ func setup(t *testing.T) func() {
 		}
 	}
 
+	discoveryClient, err = discovery.NewClient(iamClient, &discovery.Config{
+		BaseURL: serverDiscovery.URL + "/client-test/core/discovery",
+	})
+	if !assert.Nilf(t, err, "failed to create discoveryClient: %v", err) {
+		return func() {
+		}
+	}
+
 	return func() {
 		serverIAM.Close()
 		serverIDM.Close()
 		serverAI.Close()
+		serverDiscovery.Close()
 	}
 }
 
@@ -8,4 +8,5 @@ var (
 	ErrBaseURLCannotBeEmpty = errors.New("base URL cannot be empty")
 	ErrEmptyResult          = errors.New("empty result")
 	ErrInvalidEndpointURL   = errors.New("invalid endpoint URL")
+	ErrServiceNotAvailable  = errors.New("service not available")
 )
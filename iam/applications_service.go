@@ -22,6 +22,8 @@ type GetApplicationsOptions struct {
 	PropositionID     *string `url:"propositionId,omitempty"`
 	GlobalReferenceID *string `url:"globalReferenceId,omitempty"`
 	Name              *string `url:"name,omitempty"`
+	Count             *int    `url:"_count,omitempty"`
+	Page              *int    `url:"_page,omitempty"`
 }
 
 // GetApplicationByID retrieves an Application by its ID
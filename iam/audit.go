@@ -0,0 +1,250 @@
+package iam
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Audit operations
+const (
+	AuditOperationCreate = "create"
+	AuditOperationUpdate = "update"
+	AuditOperationDelete = "delete"
+	AuditOperationAction = "action"
+)
+
+const auditRedacted = "[REDACTED]"
+
+// AuditRecord describes a single mutating call made through the client
+type AuditRecord struct {
+	Time              time.Time       `json:"time"`
+	Method            string          `json:"method"`
+	Path              string          `json:"path"`
+	Operation         string          `json:"operation"`
+	ResourceType      string          `json:"resourceType"`
+	ResourceID        string          `json:"resourceId,omitempty"`
+	Action            string          `json:"action,omitempty"`
+	Before            json.RawMessage `json:"before,omitempty"`
+	After             json.RawMessage `json:"after,omitempty"`
+	Actor             string          `json:"actor,omitempty"`
+	ActorOrganization string          `json:"actorOrganization,omitempty"`
+	StatusCode        int             `json:"statusCode"`
+	Err               error           `json:"-"`
+}
+
+// Success returns true if the mutation was accepted by IAM
+func (r AuditRecord) Success() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// AuditHook is called for every create, update, delete or action call made through the client
+type AuditHook func(record AuditRecord)
+
+// auditPathPrefixes are stripped from request paths to find the resource type
+var auditPathPrefixes = []string{
+	"authorize/identity/",
+	"authorize/scim/v2/Configurations/",
+	"authorize/scim/v2/",
+}
+
+// auditSensitiveKeys are fields whose values are never recorded
+var auditSensitiveKeys = []string{"password", "privatekey", "secret", "answer", "token"}
+
+func (c *Client) auditEnabled(req *http.Request) bool {
+	if c.config == nil || c.config.AuditHook == nil || c.baseIDMURL == nil {
+		return false
+	}
+	if req.URL == nil || req.URL.Host != c.baseIDMURL.Host {
+		return false
+	}
+	// Token related calls are never audited, also when IAM and IDM share a host
+	if strings.Contains(req.URL.Opaque+req.URL.Path, "authorize/oauth2/") {
+		return false
+	}
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func (c *Client) doAudited(req *http.Request, v interface{}) (*Response, error) {
+	record := AuditRecord{
+		Time:   time.Now().UTC(),
+		Method: req.Method,
+	}
+	path := req.URL.Opaque
+	if path == "" {
+		path = req.URL.Path
+	}
+	record.Path = strings.TrimPrefix(path, c.baseIDMURL.Path)
+	record.ResourceType, record.ResourceID, record.Action = parseAuditPath(record.Path)
+	record.Operation = auditOperation(req.Method, record.ResourceID, record.Action)
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		record.After = redactAuditBody(body)
+	}
+	if c.config.AuditCaptureBefore && record.ResourceID != "" &&
+		(record.Operation == AuditOperationUpdate || record.Operation == AuditOperationDelete) {
+		record.Before = c.auditBefore(req)
+	}
+	record.Actor, record.ActorOrganization = c.auditActor()
+
+	resp, err := c.doRequest(req, v)
+	record.Err = err
+	if resp != nil {
+		record.StatusCode = resp.StatusCode
+		if record.ResourceID == "" && record.Operation == AuditOperationCreate {
+			if location := resp.Header.Get("Location"); location != "" {
+				record.ResourceID = location[strings.LastIndex(location, "/")+1:]
+			}
+		}
+	}
+	c.config.AuditHook(record)
+	return resp, err
+}
+
+// auditBefore fetches the current state of the resource targeted by req
+func (c *Client) auditBefore(req *http.Request) json.RawMessage {
+	u := *req.URL
+	u.RawQuery = ""
+	getReq := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     req.Header.Clone(),
+		Host:       u.Host,
+	}
+	getReq.Header.Del("If-Match")
+	getReq.Header.Del("Content-Type")
+	getReq = getReq.WithContext(req.Context())
+	var before bytes.Buffer
+	if _, err := c.doRequest(getReq, &before); err != nil {
+		return nil
+	}
+	return redactAuditBody(before.Bytes())
+}
+
+// auditActor returns the identity behind the current token, introspecting once per token
+func (c *Client) auditActor() (string, string) {
+	c.auditMutex.Lock()
+	defer c.auditMutex.Unlock()
+	if c.token == "" {
+		return "", ""
+	}
+	if c.auditActorToken == c.token {
+		return c.auditActorName, c.auditActorOrg
+	}
+	introspect, _, err := c.Introspect()
+	if err != nil || introspect == nil {
+		return "", ""
+	}
+	actor := introspect.Username
+	if actor == "" {
+		actor = introspect.Sub
+	}
+	if actor == "" {
+		actor = introspect.ClientID
+	}
+	c.auditActorToken = c.token
+	c.auditActorName = actor
+	c.auditActorOrg = introspect.Organizations.ManagingOrganization
+	return c.auditActorName, c.auditActorOrg
+}
+
+func parseAuditPath(path string) (resourceType, resourceID, action string) {
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	for _, prefix := range auditPathPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			path = strings.TrimPrefix(path, prefix)
+			break
+		}
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	resourceType = segments[0]
+	for _, s := range segments[1:] {
+		switch {
+		case strings.HasPrefix(s, "$"):
+			action = s
+		case resourceID == "":
+			resourceID = s
+		}
+	}
+	return
+}
+
+func auditOperation(method, resourceID, action string) string {
+	if action != "" {
+		return AuditOperationAction
+	}
+	switch method {
+	case http.MethodPut, http.MethodPatch:
+		return AuditOperationUpdate
+	case http.MethodDelete:
+		return AuditOperationDelete
+	}
+	if resourceID == "" {
+		return AuditOperationCreate
+	}
+	return AuditOperationAction
+}
+
+// redactAuditBody removes sensitive values from a JSON body. Non JSON bodies are not recorded
+func redactAuditBody(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil
+	}
+	redacted, err := json.Marshal(redactAuditValue(data))
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+func redactAuditValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if isSensitiveAuditKey(key) {
+				v[key] = auditRedacted
+				continue
+			}
+			v[key] = redactAuditValue(val)
+		}
+		return v
+	case []interface{}:
+		for i, val := range v {
+			v[i] = redactAuditValue(val)
+		}
+		return v
+	}
+	return value
+}
+
+func isSensitiveAuditKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, s := range auditSensitiveKeys {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
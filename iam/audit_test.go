@@ -0,0 +1,101 @@
+package iam
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAuditPath(t *testing.T) {
+	resourceType, id, action := parseAuditPath("authorize/identity/Group/123/$assign-role")
+	assert.Equal(t, "Group", resourceType)
+	assert.Equal(t, "123", id)
+	assert.Equal(t, "$assign-role", action)
+
+	resourceType, id, action = parseAuditPath("authorize/scim/v2/Configurations/SMSTemplate/abc")
+	assert.Equal(t, "SMSTemplate", resourceType)
+	assert.Equal(t, "abc", id)
+	assert.Equal(t, "", action)
+
+	resourceType, id, action = parseAuditPath("authorize/identity/User/$set-password")
+	assert.Equal(t, "User", resourceType)
+	assert.Equal(t, "", id)
+	assert.Equal(t, "$set-password", action)
+}
+
+func TestRedactAuditBody(t *testing.T) {
+	redacted := redactAuditBody([]byte(`{"loginId":"dev","password":"Secret1!","nested":{"privateKey":"x"}}`))
+	var data map[string]interface{}
+	_ = json.Unmarshal(redacted, &data)
+	assert.Equal(t, "dev", data["loginId"])
+	assert.Equal(t, auditRedacted, data["password"])
+	assert.Equal(t, auditRedacted, data["nested"].(map[string]interface{})["privateKey"])
+	assert.Nil(t, redactAuditBody([]byte("not json")))
+}
+
+func TestAuditHook(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	var records []AuditRecord
+	client.config.AuditHook = func(record AuditRecord) {
+		records = append(records, record)
+	}
+	client.config.AuditCaptureBefore = true
+	defer func() {
+		client.config.AuditHook = nil
+		client.config.AuditCaptureBefore = false
+	}()
+
+	groupID := "dbf1d779-ab9f-4c27-b4aa-ea75f9efbbc0"
+	orgID := "dbf1d779-ab9f-4c27-b4aa-ea75f9efbbc1"
+	muxIAM.HandleFunc("/authorize/oauth2/introspect", jsonHandler(`{
+		"active": true,
+		"username": "admin@example.com",
+		"sub": "a9f4c5a0-0d5c-4c7a-9b52-36bd8ea0d0a8",
+		"organizations": {"managingOrganization": "`+orgID+`"}
+	}`))
+	muxIDM.HandleFunc("/authorize/identity/Group", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{"id":"`+groupID+`","name":"TestGroup","managingOrganization":"`+orgID+`"}`)
+	})
+	muxIDM.HandleFunc("/authorize/identity/Group/"+groupID, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			jsonHandler(`{"id":"`+groupID+`","name":"TestGroup"}`)(w, r)
+		case "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+
+	_ = client.Login("username", "password")
+	group, _, err := client.Groups.CreateGroup(Group{Name: "TestGroup", ManagingOrganization: orgID})
+	if !assert.Nil(t, err) {
+		return
+	}
+	ok, _, err := client.Groups.DeleteGroup(*group)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	if !assert.Len(t, records, 2) {
+		return
+	}
+	create := records[0]
+	assert.Equal(t, AuditOperationCreate, create.Operation)
+	assert.Equal(t, "Group", create.ResourceType)
+	assert.Equal(t, "admin@example.com", create.Actor)
+	assert.Equal(t, orgID, create.ActorOrganization)
+	assert.Equal(t, http.StatusCreated, create.StatusCode)
+	assert.True(t, create.Success())
+	assert.Contains(t, string(create.After), "TestGroup")
+
+	deleted := records[1]
+	assert.Equal(t, AuditOperationDelete, deleted.Operation)
+	assert.Equal(t, groupID, deleted.ResourceID)
+	assert.Contains(t, string(deleted.Before), "TestGroup")
+	assert.True(t, deleted.Success())
+}
@@ -79,6 +79,11 @@ type Client struct {
 
 	debugFile *os.File
 
+	auditMutex      sync.Mutex
+	auditActorToken string
+	auditActorName  string
+	auditActorOrg   string
+
 	Organizations    *OrganizationsService
 	Groups           *GroupsService
 	Permissions      *PermissionsService
@@ -209,6 +214,16 @@ func (c *Client) WithLogin(username, password string) (*Client, error) {
 	return client, err
 }
 
+// WithDeviceLogin returns a cloned client with new device login
+func (c *Client) WithDeviceLogin(loginID, password string) (*Client, error) {
+	client, err := NewClient(c.Client, c.config)
+	if err != nil {
+		return nil, err
+	}
+	err = client.DeviceLogin(loginID, password)
+	return client, err
+}
+
 func (c *Client) accessTokenEndpoint() string {
 	if c.baseIAMURL != nil {
 		return c.baseIAMURL.String() + "oauth2/access_token"
@@ -513,6 +528,13 @@ func (c *Client) doSigned(req *http.Request, v interface{}) (*Response, error) {
 }
 
 func (c *Client) do(req *http.Request, v interface{}) (*Response, error) {
+	if c.auditEnabled(req) {
+		return c.doAudited(req, v)
+	}
+	return c.doRequest(req, v)
+}
+
+func (c *Client) doRequest(req *http.Request, v interface{}) (*Response, error) {
 	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
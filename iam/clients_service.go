@@ -53,6 +53,8 @@ type GetClientsOptions struct {
 	Name              *string `url:"name,omitempty"`
 	GlobalReferenceID *string `url:"globalReferenceId,omitempty"`
 	ApplicationID     *string `url:"applicationId,omitempty"`
+	Count             *int    `url:"_count,omitempty"`
+	Page              *int    `url:"_page,omitempty"`
 }
 
 // CreateClient creates a Client
@@ -23,4 +23,8 @@ type Config struct {
 	Debug            bool
 	DebugLog         string
 	Signer           *hsdpsigner.Signer
+	// AuditHook, when set, is called for every mutating IDM call
+	AuditHook AuditHook
+	// AuditCaptureBefore fetches the resource state before updates and deletes
+	AuditCaptureBefore bool
 }
@@ -0,0 +1,340 @@
+package iam
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDevicePasswordLength = 24
+	devicePasswordLower         = "abcdefghijkmnopqrstuvwxyz"
+	devicePasswordUpper         = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	devicePasswordNumerics      = "23456789"
+	devicePasswordSpecial       = "!#$%*+-=?@_"
+)
+
+// ErrDeviceCredentialsNotFound is returned when no stored credentials exist for a device
+var ErrDeviceCredentialsNotFound = errors.New("device credentials not found")
+
+// DeviceCredentials holds the credentials of a device as tracked by the DeviceLifecycleManager
+type DeviceCredentials struct {
+	LoginID     string     `json:"loginId"`
+	Password    string     `json:"password"`
+	RotatedAt   time.Time  `json:"rotatedAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+}
+
+// DeviceCredentialStore persists device credentials. IAM does not return
+// device passwords so rotation and login checks depend on this store
+type DeviceCredentialStore interface {
+	Get(loginID string) (*DeviceCredentials, error)
+	Put(credentials DeviceCredentials) error
+}
+
+// MemoryDeviceCredentialStore is a DeviceCredentialStore which keeps credentials in memory
+type MemoryDeviceCredentialStore struct {
+	credentials map[string]DeviceCredentials
+	sync.Mutex
+}
+
+// NewMemoryDeviceCredentialStore returns an empty in-memory credential store
+func NewMemoryDeviceCredentialStore() *MemoryDeviceCredentialStore {
+	return &MemoryDeviceCredentialStore{
+		credentials: make(map[string]DeviceCredentials),
+	}
+}
+
+// Get returns the credentials of the device with the given loginID
+func (m *MemoryDeviceCredentialStore) Get(loginID string) (*DeviceCredentials, error) {
+	m.Lock()
+	defer m.Unlock()
+	creds, ok := m.credentials[loginID]
+	if !ok {
+		return nil, ErrDeviceCredentialsNotFound
+	}
+	return &creds, nil
+}
+
+// Put stores the credentials of a device
+func (m *MemoryDeviceCredentialStore) Put(credentials DeviceCredentials) error {
+	m.Lock()
+	defer m.Unlock()
+	m.credentials[credentials.LoginID] = credentials
+	return nil
+}
+
+// DeviceManifestEntry describes a device to register and the groups it should join
+type DeviceManifestEntry struct {
+	Device Device   `json:"device"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// DeviceManifest describes a batch of devices to register
+type DeviceManifest struct {
+	Devices []DeviceManifestEntry `json:"devices"`
+}
+
+// ReadDeviceManifest decodes a JSON device manifest
+func ReadDeviceManifest(r io.Reader) (*DeviceManifest, error) {
+	var manifest DeviceManifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// DeviceRegistration is the outcome of registering a single manifest entry
+type DeviceRegistration struct {
+	LoginID  string   `json:"loginId"`
+	Device   *Device  `json:"device,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	Verified bool     `json:"verified"`
+	Err      error    `json:"-"`
+}
+
+// DeviceState describes the credential and login state of a device
+type DeviceState struct {
+	Device           Device        `json:"device"`
+	Tracked          bool          `json:"tracked"`
+	NeverLoggedIn    bool          `json:"neverLoggedIn"`
+	LastLoginAt      *time.Time    `json:"lastLoginAt,omitempty"`
+	CredentialsAge   time.Duration `json:"credentialsAge"`
+	StaleCredentials bool          `json:"staleCredentials"`
+}
+
+// DeviceLifecycleManager handles bulk registration, credential rotation and
+// login verification of IAM devices
+type DeviceLifecycleManager struct {
+	client *Client
+	store  DeviceCredentialStore
+
+	// PasswordPolicy, when set, is used to validate generated passwords
+	PasswordPolicy *PasswordPolicy
+	// PasswordLength is the length of generated passwords
+	PasswordLength int
+	// VerifyLogin performs a device login after registration and rotation
+	VerifyLogin bool
+
+	now func() time.Time
+}
+
+// NewDeviceLifecycleManager returns a manager using client for IAM calls
+// and store to keep track of device credentials
+func NewDeviceLifecycleManager(client *Client, store DeviceCredentialStore) (*DeviceLifecycleManager, error) {
+	if client == nil {
+		return nil, ErrMissingClient
+	}
+	if store == nil {
+		store = NewMemoryDeviceCredentialStore()
+	}
+	return &DeviceLifecycleManager{
+		client:         client,
+		store:          store,
+		PasswordLength: defaultDevicePasswordLength,
+		now:            time.Now,
+	}, nil
+}
+
+// GenerateDevicePassword generates a random password of the given length
+// containing at least two characters of each character class
+func GenerateDevicePassword(length int) (string, error) {
+	classes := []string{devicePasswordLower, devicePasswordUpper, devicePasswordNumerics, devicePasswordSpecial}
+	if length < 2*len(classes) {
+		return "", ErrMalformedInputValue
+	}
+	all := ""
+	for _, c := range classes {
+		all += c
+	}
+	password := make([]byte, 0, length)
+	for _, c := range classes {
+		for i := 0; i < 2; i++ {
+			b, err := randomChar(c)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, b)
+		}
+	}
+	for len(password) < length {
+		b, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, b)
+	}
+	// Shuffle so the class order is not predictable
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
+
+func randomChar(set string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+	if err != nil {
+		return 0, err
+	}
+	return set[n.Int64()], nil
+}
+
+func (m *DeviceLifecycleManager) generatePassword() (string, error) {
+	password, err := GenerateDevicePassword(m.PasswordLength)
+	if err != nil {
+		return "", err
+	}
+	if m.PasswordPolicy != nil {
+		if err := m.PasswordPolicy.EvaluatePassword(password).Error(); err != nil {
+			return "", err
+		}
+	}
+	return password, nil
+}
+
+// Register registers all devices in the manifest. A password is generated for
+// entries without one. Devices are added to their groups and, if VerifyLogin is
+// set, a device login is performed. Failures are reported per entry
+func (m *DeviceLifecycleManager) Register(manifest DeviceManifest) []DeviceRegistration {
+	results := make([]DeviceRegistration, 0, len(manifest.Devices))
+	for _, entry := range manifest.Devices {
+		results = append(results, m.register(entry))
+	}
+	return results
+}
+
+func (m *DeviceLifecycleManager) register(entry DeviceManifestEntry) DeviceRegistration {
+	device := entry.Device
+	result := DeviceRegistration{LoginID: device.LoginID}
+	if device.Password == "" {
+		password, err := m.generatePassword()
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		device.Password = password
+	}
+	created, _, err := m.client.Devices.CreateDevice(device)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Device = created
+	if err := m.store.Put(DeviceCredentials{
+		LoginID:   device.LoginID,
+		Password:  device.Password,
+		RotatedAt: m.now(),
+	}); err != nil {
+		result.Err = err
+		return result
+	}
+	for _, groupID := range entry.Groups {
+		if _, _, err := m.client.Groups.AddDevices(Group{ID: groupID}, created.ID); err != nil {
+			result.Err = err
+			return result
+		}
+		result.Groups = append(result.Groups, groupID)
+	}
+	if m.VerifyLogin {
+		if err := m.Verify(device.LoginID); err != nil {
+			result.Err = err
+			return result
+		}
+		result.Verified = true
+	}
+	return result
+}
+
+// Rotate replaces the password of the device with a newly generated one
+// and returns the new password
+func (m *DeviceLifecycleManager) Rotate(device Device) (string, error) {
+	creds, err := m.store.Get(device.LoginID)
+	if err != nil {
+		return "", err
+	}
+	password, err := m.generatePassword()
+	if err != nil {
+		return "", err
+	}
+	ok, _, err := m.client.Devices.ChangePassword(device.ID, creds.Password, password)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrOperationFailed
+	}
+	creds.Password = password
+	creds.RotatedAt = m.now()
+	if err := m.store.Put(*creds); err != nil {
+		return "", err
+	}
+	if m.VerifyLogin {
+		if err := m.Verify(device.LoginID); err != nil {
+			return "", err
+		}
+	}
+	return password, nil
+}
+
+// Verify performs a device login with the stored credentials and records the login time
+func (m *DeviceLifecycleManager) Verify(loginID string) error {
+	creds, err := m.store.Get(loginID)
+	if err != nil {
+		return err
+	}
+	deviceClient, err := m.client.WithDeviceLogin(creds.LoginID, creds.Password)
+	if err != nil {
+		return err
+	}
+	defer deviceClient.Close()
+	now := m.now()
+	creds.LastLoginAt = &now
+	return m.store.Put(*creds)
+}
+
+// StateReport lists all devices matching opt and reports which never logged in
+// and which have credentials older than maxCredentialsAge. Devices without
+// stored credentials are reported as untracked and stale
+func (m *DeviceLifecycleManager) StateReport(opt GetDevicesOptions, maxCredentialsAge time.Duration) ([]DeviceState, error) {
+	count := 100
+	page := 1
+	opt.Count = &count
+	now := m.now()
+	var states []DeviceState
+	for {
+		pageNumber := page
+		opt.Page = &pageNumber
+		devices, _, err := m.client.Devices.GetDevices(&opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, device := range *devices {
+			state := DeviceState{Device: device, NeverLoggedIn: true, StaleCredentials: true}
+			creds, err := m.store.Get(device.LoginID)
+			if err != nil && !errors.Is(err, ErrDeviceCredentialsNotFound) {
+				return nil, err
+			}
+			if creds != nil {
+				state.Tracked = true
+				state.LastLoginAt = creds.LastLoginAt
+				state.NeverLoggedIn = creds.LastLoginAt == nil
+				state.CredentialsAge = now.Sub(creds.RotatedAt)
+				state.StaleCredentials = maxCredentialsAge > 0 && state.CredentialsAge > maxCredentialsAge
+			}
+			states = append(states, state)
+		}
+		if len(*devices) < count {
+			break
+		}
+		page++
+	}
+	return states, nil
+}
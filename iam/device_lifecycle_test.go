@@ -0,0 +1,160 @@
+package iam
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateDevicePassword(t *testing.T) {
+	password, err := GenerateDevicePassword(24)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Len(t, password, 24)
+	policy := testPasswordPolicy()
+	policy.Complexity.MaxLength = 32
+	assert.True(t, policy.EvaluatePassword(password).Valid())
+
+	_, err = GenerateDevicePassword(4)
+	assert.Equal(t, ErrMalformedInputValue, err)
+}
+
+func TestDeviceLifecycle(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	deviceID := "dbf1d779-ab9f-4c27-b4aa-ea75f9efbbc1"
+	groupID := "dbf1d779-ab9f-4c27-b4aa-ea75f9efbbc0"
+	loginID := "fleetdevice01"
+	var registeredPassword string
+	var assigned []string
+
+	muxIDM.HandleFunc("/authorize/identity/Device", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "POST":
+			var device Device
+			_ = json.NewDecoder(r.Body).Decode(&device)
+			registeredPassword = device.Password
+			w.Header().Set("Location", "/authorize/identity/Device/"+deviceID)
+			w.WriteHeader(http.StatusCreated)
+		case "GET":
+			w.WriteHeader(http.StatusOK)
+			_, _ = io.WriteString(w, `{
+  "total": 2,
+  "entry": [
+    {
+      "loginId": "`+loginID+`",
+      "organizationId": "f5fe538f-c3b5-4454-8774-cd3789f59b9a",
+      "applicationId": "711171ab-d28c-4616-a314-f95584e280c3",
+      "type": "Device",
+      "id": "`+deviceID+`"
+    },
+    {
+      "loginId": "unknowndevice",
+      "organizationId": "f5fe538f-c3b5-4454-8774-cd3789f59b9a",
+      "applicationId": "711171ab-d28c-4616-a314-f95584e280c3",
+      "type": "Device",
+      "id": "3f0f8c42-3c5b-4bd1-9b34-e1b1a1d3f0aa"
+    }
+  ]
+}`)
+		}
+	})
+	muxIDM.HandleFunc("/authorize/identity/Device/"+deviceID+"/$change-password", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			OldPassword string `json:"oldPassword"`
+			NewPassword string `json:"newPassword"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.OldPassword != registeredPassword {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		registeredPassword = body.NewPassword
+		w.WriteHeader(http.StatusNoContent)
+	})
+	muxIDM.HandleFunc("/authorize/identity/Group/"+groupID, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", "v1")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"id": "`+groupID+`", "name": "Fleet"}`)
+	})
+	muxIDM.HandleFunc("/authorize/identity/Group/"+groupID+"/$assign", func(w http.ResponseWriter, r *http.Request) {
+		var body memberRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, "DEVICE", body.MemberType)
+		assert.Equal(t, "v1", r.Header.Get("If-Match"))
+		assigned = append(assigned, body.Value...)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{}`)
+	})
+
+	manager, err := NewDeviceLifecycleManager(client, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	manager.VerifyLogin = true
+
+	manifest, err := ReadDeviceManifest(strings.NewReader(`{
+  "devices": [
+    {
+      "device": {
+        "loginId": "` + loginID + `",
+        "deviceExtId": {"type": {"code": "ID"}, "system": "http://example.com", "value": "0001"},
+        "type": "Device",
+        "organizationId": "f5fe538f-c3b5-4454-8774-cd3789f59b9a",
+        "globalReferenceId": "c157bd2e-e992-4b5e-88ab-911766b7b8f4",
+        "applicationId": "711171ab-d28c-4616-a314-f95584e280c3"
+      },
+      "groups": ["` + groupID + `"]
+    }
+  ]
+}`))
+	if !assert.Nil(t, err) {
+		return
+	}
+	results := manager.Register(*manifest)
+	if !assert.Len(t, results, 1) {
+		return
+	}
+	assert.Nil(t, results[0].Err)
+	assert.True(t, results[0].Verified)
+	assert.Equal(t, []string{groupID}, results[0].Groups)
+	assert.Equal(t, []string{deviceID}, assigned)
+	assert.Len(t, registeredPassword, defaultDevicePasswordLength)
+
+	creds, err := manager.store.Get(loginID)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, registeredPassword, creds.Password)
+	assert.NotNil(t, creds.LastLoginAt)
+
+	newPassword, err := manager.Rotate(*results[0].Device)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, registeredPassword, newPassword)
+
+	manager.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	states, err := manager.StateReport(GetDevicesOptions{}, 24*time.Hour)
+	if !assert.Nil(t, err) {
+		return
+	}
+	if !assert.Len(t, states, 2) {
+		return
+	}
+	assert.True(t, states[0].Tracked)
+	assert.False(t, states[0].NeverLoggedIn)
+	assert.True(t, states[0].StaleCredentials)
+	assert.False(t, states[1].Tracked)
+	assert.True(t, states[1].NeverLoggedIn)
+}
@@ -25,6 +25,8 @@ var (
 	ErrNotAuthorized                  = errors.New("not authorized")
 	ErrNoValidSignerAvailable         = errors.New("no valid HSDP signer available")
 	ErrMissingOAuth2Credentials       = errors.New("missing OAuth2 credentials")
+	ErrPasswordPolicyViolation        = errors.New("password policy violation")
+	ErrMissingClient                  = errors.New("missing client")
 )
 
 type UserError struct {
@@ -0,0 +1,210 @@
+package iam
+
+import (
+	"sort"
+)
+
+// Group member types
+const (
+	MemberTypeUser    = "USER"
+	MemberTypeDevice  = "DEVICE"
+	MemberTypeService = "SERVICE"
+)
+
+const (
+	defaultSyncChunkSize = 10
+	syncPageSize         = 100
+)
+
+// SyncMembersOptions controls the behaviour of SyncMembers
+type SyncMembersOptions struct {
+	// DryRun computes the changes without applying them
+	DryRun bool
+	// ChunkSize is the maximum number of identities per add or remove call
+	ChunkSize int
+}
+
+// MemberSyncFailure describes an identity which could not be added or removed
+type MemberSyncFailure struct {
+	Identity  string `json:"identity"`
+	Operation string `json:"operation"`
+	Err       error  `json:"-"`
+}
+
+// MemberSyncReport describes the changes computed and applied by SyncMembers
+type MemberSyncReport struct {
+	GroupID    string              `json:"groupId"`
+	MemberType string              `json:"memberType"`
+	DryRun     bool                `json:"dryRun"`
+	ToAdd      []string            `json:"toAdd"`
+	ToRemove   []string            `json:"toRemove"`
+	Unchanged  []string            `json:"unchanged"`
+	Added      []string            `json:"added"`
+	Removed    []string            `json:"removed"`
+	Failed     []MemberSyncFailure `json:"failed,omitempty"`
+}
+
+// InSync returns true if the group already matched the desired members
+func (r MemberSyncReport) InSync() bool {
+	return len(r.ToAdd) == 0 && len(r.ToRemove) == 0
+}
+
+// GetMembers returns the IDs of all members of the given type
+func (g *GroupsService) GetMembers(group Group, memberType string) ([]string, *Response, error) {
+	switch memberType {
+	case MemberTypeUser:
+		return g.client.Users.GetAllUsers(&GetUserOptions{GroupID: &group.ID})
+	case MemberTypeDevice:
+		return pagedIDs(func(count, page int) ([]string, *Response, error) {
+			devices, resp, err := g.client.Devices.GetDevices(&GetDevicesOptions{GroupID: &group.ID, Count: &count, Page: &page})
+			if err != nil {
+				return nil, resp, err
+			}
+			ids := make([]string, 0, len(*devices))
+			for _, d := range *devices {
+				ids = append(ids, d.ID)
+			}
+			return ids, resp, nil
+		})
+	case MemberTypeService:
+		return pagedIDs(func(count, page int) ([]string, *Response, error) {
+			services, resp, err := g.client.Services.GetServices(&GetServiceOptions{GroupID: &group.ID, Count: &count, Page: &page})
+			if err != nil {
+				return nil, resp, err
+			}
+			ids := make([]string, 0, len(*services))
+			for _, s := range *services {
+				ids = append(ids, s.ID)
+			}
+			return ids, resp, nil
+		})
+	}
+	return nil, nil, ErrMalformedInputValue
+}
+
+func pagedIDs(fetch func(count, page int) ([]string, *Response, error)) ([]string, *Response, error) {
+	var all []string
+	var resp *Response
+	for page := 1; ; page++ {
+		ids, r, err := fetch(syncPageSize, page)
+		resp = r
+		if err != nil {
+			return all, resp, err
+		}
+		all = append(all, ids...)
+		if len(ids) < syncPageSize {
+			return all, resp, nil
+		}
+	}
+}
+
+// SyncMembers makes the members of the given type equal to desired. Current
+// members are listed, the difference is computed and applied in chunks using
+// the group version for If-Match. With DryRun set only the report is computed.
+// Chunk failures do not stop the sync; the first error is returned with the report
+func (g *GroupsService) SyncMembers(group Group, memberType string, desired []string, opt *SyncMembersOptions) (*MemberSyncReport, *Response, error) {
+	if opt == nil {
+		opt = &SyncMembersOptions{}
+	}
+	chunkSize := opt.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultSyncChunkSize
+	}
+	current, resp, err := g.GetMembers(group, memberType)
+	if err != nil {
+		return nil, resp, err
+	}
+	report := diffMembers(current, desired)
+	report.GroupID = group.ID
+	report.MemberType = memberType
+	report.DryRun = opt.DryRun
+	if opt.DryRun || report.InSync() {
+		return report, resp, nil
+	}
+
+	var firstErr error
+	apply := func(identities []string, operation string, applied *[]string) {
+		for i := 0; i < len(identities); i += chunkSize {
+			end := i + chunkSize
+			if end > len(identities) {
+				end = len(identities)
+			}
+			chunk := identities[i:end]
+			var err error
+			resp, err = g.syncChunk(group, memberType, operation, chunk)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				for _, id := range chunk {
+					report.Failed = append(report.Failed, MemberSyncFailure{Identity: id, Operation: operation, Err: err})
+				}
+				continue
+			}
+			*applied = append(*applied, chunk...)
+		}
+	}
+	apply(report.ToRemove, "remove", &report.Removed)
+	apply(report.ToAdd, "add", &report.Added)
+	return report, resp, firstErr
+}
+
+func (g *GroupsService) syncChunk(group Group, memberType, operation string, chunk []string) (*Response, error) {
+	_, resp, err := g.GetGroupByID(group.ID)
+	if err != nil {
+		return resp, err
+	}
+	options := []OptionFunc{addIfMatchHeader(resp.Header.Get("ETag"))}
+	var action string
+	var body interface{}
+	if memberType == MemberTypeUser {
+		action = "$add-members"
+		if operation == "remove" {
+			action = "$remove-members"
+		}
+		body = groupRequestBody(chunk...)
+	} else {
+		action = "$assign"
+		if operation == "remove" {
+			action = "$remove"
+		}
+		body = memberRequestBody(memberType, chunk...)
+	}
+	_, resp, err = g.memberAction(group, action, body, options)
+	return resp, err
+}
+
+func diffMembers(current, desired []string) *MemberSyncReport {
+	currentSet := make(map[string]bool, len(current))
+	for _, id := range current {
+		currentSet[id] = true
+	}
+	desiredSet := make(map[string]bool, len(desired))
+	report := &MemberSyncReport{
+		ToAdd:     []string{},
+		ToRemove:  []string{},
+		Unchanged: []string{},
+		Added:     []string{},
+		Removed:   []string{},
+	}
+	for _, id := range desired {
+		if desiredSet[id] {
+			continue
+		}
+		desiredSet[id] = true
+		if currentSet[id] {
+			report.Unchanged = append(report.Unchanged, id)
+		} else {
+			report.ToAdd = append(report.ToAdd, id)
+		}
+	}
+	for id := range currentSet {
+		if !desiredSet[id] {
+			report.ToRemove = append(report.ToRemove, id)
+		}
+	}
+	sort.Strings(report.ToAdd)
+	sort.Strings(report.ToRemove)
+	sort.Strings(report.Unchanged)
+	return report
+}
@@ -0,0 +1,168 @@
+package iam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncMembers(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	groupID := "dbf1d779-ab9f-4c27-b4aa-ea75f9efbbc0"
+	eTag := "W/\"42\""
+	var assigned, removed []string
+
+	muxIDM.HandleFunc("/authorize/identity/Group/"+groupID, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", eTag)
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"id": "`+groupID+`", "name": "Fleet"}`)
+	})
+	muxIDM.HandleFunc("/authorize/identity/Device", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, groupID, r.URL.Query().Get("groupId"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"total": 2, "entry": [{"id": "dev1"}, {"id": "dev2"}]}`)
+	})
+	memberHandler := func(target *[]string) func(w http.ResponseWriter, r *http.Request) {
+		return func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, eTag, r.Header.Get("If-Match"))
+			var body memberRequest
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, MemberTypeDevice, body.MemberType)
+			*target = append(*target, body.Value...)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = io.WriteString(w, `{}`)
+		}
+	}
+	muxIDM.HandleFunc("/authorize/identity/Group/"+groupID+"/$assign", memberHandler(&assigned))
+	muxIDM.HandleFunc("/authorize/identity/Group/"+groupID+"/$remove", memberHandler(&removed))
+
+	group := Group{ID: groupID}
+	desired := []string{"dev2", "dev3", "dev4", "dev3"}
+
+	report, _, err := client.Groups.SyncMembers(group, MemberTypeDevice, desired, &SyncMembersOptions{DryRun: true})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []string{"dev3", "dev4"}, report.ToAdd)
+	assert.Equal(t, []string{"dev1"}, report.ToRemove)
+	assert.Equal(t, []string{"dev2"}, report.Unchanged)
+	assert.Empty(t, report.Added)
+	assert.Empty(t, assigned)
+
+	report, _, err = client.Groups.SyncMembers(group, MemberTypeDevice, desired, &SyncMembersOptions{ChunkSize: 1})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"dev3", "dev4"}, report.Added)
+	assert.Equal(t, []string{"dev1"}, report.Removed)
+	assert.Empty(t, report.Failed)
+	assert.Equal(t, []string{"dev3", "dev4"}, assigned)
+	assert.Equal(t, []string{"dev1"}, removed)
+
+	_, _, err = client.Groups.SyncMembers(group, "FOO", desired, nil)
+	assert.Equal(t, ErrMalformedInputValue, err)
+}
+
+// syncGroup serves a group whose ETag changes after every member action, so
+// each action must use the ETag of a fresh group read
+func syncGroup(t *testing.T, groupID string) (actions *[]string, bodies *[][]byte) {
+	version := 1
+	etag := func() string { return fmt.Sprintf("W/\"%d\"", version) }
+	actions = &[]string{}
+	bodies = &[][]byte{}
+	muxIDM.HandleFunc("/authorize/identity/Group/"+groupID, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", etag())
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"id": "`+groupID+`", "name": "Team"}`)
+	})
+	for _, action := range []string{"$add-members", "$remove-members", "$assign", "$remove"} {
+		action := action
+		muxIDM.HandleFunc("/authorize/identity/Group/"+groupID+"/"+action, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, etag(), r.Header.Get("If-Match"), action)
+			body, _ := io.ReadAll(r.Body)
+			*actions = append(*actions, action)
+			*bodies = append(*bodies, body)
+			version++
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_, _ = io.WriteString(w, `{}`)
+		})
+	}
+	return actions, bodies
+}
+
+func TestSyncMembersUsers(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	groupID := "7d3a2f4e-5b8c-4f1a-9e6d-2c0b1a8f7e65"
+	actions, bodies := syncGroup(t, groupID)
+	muxIDM.HandleFunc("/security/users", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, groupID, r.URL.Query().Get("groupId"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"exchange": {"users": [{"userUUID": "user1"}, {"userUUID": "user2"}], "nextPageExists": false}, "responseCode": "200"}`)
+	})
+
+	report, _, err := client.Groups.SyncMembers(Group{ID: groupID}, MemberTypeUser, []string{"user2", "user3", "user4"}, &SyncMembersOptions{ChunkSize: 1})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"user3", "user4"}, report.Added)
+	assert.Equal(t, []string{"user1"}, report.Removed)
+	assert.Equal(t, []string{"$remove-members", "$add-members", "$add-members"}, *actions)
+	if !assert.Len(t, *bodies, 3) {
+		return
+	}
+	for i, user := range []string{"user1", "user3", "user4"} {
+		var body groupRequest
+		assert.Nil(t, json.Unmarshal((*bodies)[i], &body))
+		assert.Equal(t, "Parameters", body.ResourceType)
+		if assert.Len(t, body.Parameter, 1) {
+			assert.Equal(t, "UserIDCollection", body.Parameter[0].Name)
+			assert.Equal(t, []Reference{{Reference: user}}, body.Parameter[0].References)
+		}
+	}
+}
+
+func TestSyncMembersServices(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	groupID := "3e9c1b7a-0f2d-4a6e-8b5c-9d4f2e1a0c38"
+	actions, bodies := syncGroup(t, groupID)
+	muxIDM.HandleFunc("/authorize/identity/Service", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, groupID, r.URL.Query().Get("groupId"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"total": 1, "entry": [{"id": "svc1"}]}`)
+	})
+
+	report, _, err := client.Groups.SyncMembers(Group{ID: groupID}, MemberTypeService, []string{"svc2", "svc3"}, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"svc2", "svc3"}, report.Added)
+	assert.Equal(t, []string{"svc1"}, report.Removed)
+	assert.Equal(t, []string{"$remove", "$assign"}, *actions)
+	if !assert.Len(t, *bodies, 2) {
+		return
+	}
+	for i, value := range [][]string{{"svc1"}, {"svc2", "svc3"}} {
+		var body memberRequest
+		assert.Nil(t, json.Unmarshal((*bodies)[i], &body))
+		assert.Equal(t, MemberTypeService, body.MemberType)
+		assert.Equal(t, value, body.Value)
+	}
+}
@@ -19,6 +19,8 @@ type GetGroupOptions struct {
 	Name           *string `url:"name,omitempty"`
 	MemberType     *string `url:"memberType,omitempty"`
 	MemberID       *string `url:"memberId,omitempty"`
+	Count          *int    `url:"_count,omitempty"`
+	Page           *int    `url:"_page,omitempty"`
 }
 
 // GroupsService implements actions on Group entities
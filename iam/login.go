@@ -110,6 +110,37 @@ func (c *Client) Login(username, password string) error {
 	return c.doTokenRequest(req)
 }
 
+// DeviceLogin logs in a device with `loginID` and `password` using the device grant
+func (c *Client) DeviceLogin(loginID, password string) error {
+	// Authorize
+	u := *c.baseIAMURL
+	u.Opaque = c.baseIAMURL.Path + "authorize/oauth2/token"
+
+	req := &http.Request{
+		Method:     "POST",
+		URL:        &u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+	form := url.Values{}
+	form.Add("username", loginID)
+	form.Add("password", password)
+	form.Add("grant_type", "device")
+	if len(c.config.Scopes) > 0 {
+		scopes := strings.Join(c.config.Scopes, " ")
+		form.Add("scope", scopes)
+	}
+	req.SetBasicAuth(c.config.OAuth2ClientID, c.config.OAuth2Secret)
+	req.Body = ioutil.NopCloser(strings.NewReader(form.Encode()))
+	req.ContentLength = int64(len(form.Encode()))
+	c.service = Service{} // reset
+
+	return c.doTokenRequest(req)
+}
+
 // ClientCredentialsLogin logs in using client credentials
 // The client credentials and scopes are expected to passed during configuration of the client
 func (c *Client) ClientCredentialsLogin() error {
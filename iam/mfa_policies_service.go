@@ -100,3 +100,39 @@ func (p *MFAPoliciesService) DeleteMFAPolicy(policy MFAPolicy) (bool, *Response,
 	}
 	return true, resp, err
 }
+
+// GetMFAPolicyOptions describes the criteria for looking up MFA policies
+type GetMFAPolicyOptions struct {
+	Filter             *string `url:"filter,omitempty"`
+	Attributes         *string `url:"attributes,omitempty"`
+	ExcludedAttributes *string `url:"excludedAttributes,omitempty"`
+}
+
+// MFAPolicyFilterOrganization returns options which find all MFA policies of an organization
+func MFAPolicyFilterOrganization(orgID string) *GetMFAPolicyOptions {
+	query := "resource.type eq \"Organization\" and resource.value eq \"" + orgID + "\""
+	return &GetMFAPolicyOptions{
+		Filter: &query,
+	}
+}
+
+// GetMFAPolicies looks up MFA policies based on GetMFAPolicyOptions
+func (p *MFAPoliciesService) GetMFAPolicies(opt *GetMFAPolicyOptions, options ...OptionFunc) (*[]MFAPolicy, *Response, error) {
+	req, err := p.client.newRequest(IDM, "GET", scimBasePath+"MFAPolicies", opt, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("api-version", mfaPoliciesAPIVersion)
+	req.Header.Set("Accept", "application/scim+json")
+
+	var bundleResponse struct {
+		TotalResults int         `json:"totalResults"`
+		Resources    []MFAPolicy `json:"Resources"`
+	}
+
+	resp, err := p.client.do(req, &bundleResponse)
+	if err != nil {
+		return nil, resp, err
+	}
+	return &bundleResponse.Resources, resp, nil
+}
@@ -0,0 +1,209 @@
+package iam
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Password policy rules which can be violated
+const (
+	PasswordRuleMinLength       = "minLength"
+	PasswordRuleMaxLength       = "maxLength"
+	PasswordRuleMinNumerics     = "minNumerics"
+	PasswordRuleMinUpperCase    = "minUpperCase"
+	PasswordRuleMinLowerCase    = "minLowerCase"
+	PasswordRuleMinSpecialChars = "minSpecialChars"
+)
+
+// PasswordViolation describes a single password policy rule that was not met
+type PasswordViolation struct {
+	Rule     string `json:"rule"`
+	Required int    `json:"required"`
+	Actual   int    `json:"actual"`
+	Message  string `json:"message"`
+}
+
+// PasswordStrength is a rough indication of how hard a password is to guess
+type PasswordStrength int
+
+const (
+	PasswordStrengthVeryWeak PasswordStrength = iota
+	PasswordStrengthWeak
+	PasswordStrengthFair
+	PasswordStrengthStrong
+	PasswordStrengthVeryStrong
+)
+
+func (s PasswordStrength) String() string {
+	switch s {
+	case PasswordStrengthVeryWeak:
+		return "very weak"
+	case PasswordStrengthWeak:
+		return "weak"
+	case PasswordStrengthFair:
+		return "fair"
+	case PasswordStrengthStrong:
+		return "strong"
+	case PasswordStrengthVeryStrong:
+		return "very strong"
+	}
+	return "unknown"
+}
+
+// PasswordEvaluation is the result of evaluating a password against a PasswordPolicy
+type PasswordEvaluation struct {
+	Violations  []PasswordViolation `json:"violations,omitempty"`
+	Strength    PasswordStrength    `json:"strength"`
+	EntropyBits float64             `json:"entropyBits"`
+}
+
+// Valid returns true if the password did not violate any rule
+func (e PasswordEvaluation) Valid() bool {
+	return len(e.Violations) == 0
+}
+
+// Error returns all violations as a single error, or nil if the password is valid
+func (e PasswordEvaluation) Error() error {
+	if e.Valid() {
+		return nil
+	}
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return fmt.Errorf("%w: %s", ErrPasswordPolicyViolation, strings.Join(messages, ", "))
+}
+
+type passwordCharCounts struct {
+	length    int
+	numerics  int
+	upperCase int
+	lowerCase int
+	special   int
+}
+
+func countPasswordChars(password string) passwordCharCounts {
+	var counts passwordCharCounts
+	for _, r := range password {
+		counts.length++
+		switch {
+		case unicode.IsDigit(r):
+			counts.numerics++
+		case unicode.IsUpper(r):
+			counts.upperCase++
+		case unicode.IsLower(r):
+			counts.lowerCase++
+		case unicode.IsLetter(r):
+			// Letters without case count towards length only
+		default:
+			counts.special++
+		}
+	}
+	return counts
+}
+
+// EvaluatePassword checks password against the complexity rules of the policy.
+// The history rule can only be checked by IAM and is therefore not evaluated
+func (p PasswordPolicy) EvaluatePassword(password string) PasswordEvaluation {
+	counts := countPasswordChars(password)
+	complexity := p.Complexity
+
+	var violations []PasswordViolation
+	atLeast := func(rule string, required, actual int, what string) {
+		if actual < required {
+			violations = append(violations, PasswordViolation{
+				Rule:     rule,
+				Required: required,
+				Actual:   actual,
+				Message:  fmt.Sprintf("must contain at least %d %s", required, what),
+			})
+		}
+	}
+	atLeast(PasswordRuleMinLength, complexity.MinLength, counts.length, "characters")
+	if complexity.MaxLength > 0 && counts.length > complexity.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Rule:     PasswordRuleMaxLength,
+			Required: complexity.MaxLength,
+			Actual:   counts.length,
+			Message:  fmt.Sprintf("must contain at most %d characters", complexity.MaxLength),
+		})
+	}
+	atLeast(PasswordRuleMinNumerics, complexity.MinNumerics, counts.numerics, "numeric characters")
+	atLeast(PasswordRuleMinUpperCase, complexity.MinUpperCase, counts.upperCase, "uppercase characters")
+	atLeast(PasswordRuleMinLowerCase, complexity.MinLowerCase, counts.lowerCase, "lowercase characters")
+	atLeast(PasswordRuleMinSpecialChars, complexity.MinSpecialChars, counts.special, "special characters")
+
+	entropy := PasswordEntropy(password)
+	return PasswordEvaluation{
+		Violations:  violations,
+		EntropyBits: entropy,
+		Strength:    strengthFromEntropy(entropy),
+	}
+}
+
+// PasswordEntropy estimates the entropy in bits of a password based on its
+// length and the character classes it uses. Repeated characters do not add entropy
+func PasswordEntropy(password string) float64 {
+	counts := countPasswordChars(password)
+	pool := 0
+	if counts.numerics > 0 {
+		pool += 10
+	}
+	if counts.upperCase > 0 {
+		pool += 26
+	}
+	if counts.lowerCase > 0 {
+		pool += 26
+	}
+	if counts.special > 0 {
+		pool += 33
+	}
+	if other := counts.length - counts.numerics - counts.upperCase - counts.lowerCase - counts.special; other > 0 {
+		pool += 100
+	}
+	if pool == 0 {
+		return 0
+	}
+	unique := make(map[rune]bool)
+	for _, r := range password {
+		unique[r] = true
+	}
+	return float64(len(unique)) * math.Log2(float64(pool))
+}
+
+func strengthFromEntropy(bits float64) PasswordStrength {
+	switch {
+	case bits < 28:
+		return PasswordStrengthVeryWeak
+	case bits < 36:
+		return PasswordStrengthWeak
+	case bits < 60:
+		return PasswordStrengthFair
+	case bits < 128:
+		return PasswordStrengthStrong
+	}
+	return PasswordStrengthVeryStrong
+}
+
+// PasswordExpiresAt returns the time a password changed at changedOn expires.
+// The boolean is false when the policy has no expiry period
+func (p PasswordPolicy) PasswordExpiresAt(changedOn time.Time) (time.Time, bool) {
+	if p.ExpiryPeriodInDays <= 0 {
+		return time.Time{}, false
+	}
+	return changedOn.AddDate(0, 0, p.ExpiryPeriodInDays), true
+}
+
+// DaysUntilExpiry returns the number of whole days between now and the expiry
+// of a password changed at changedOn. A negative value means the password expired
+// that many days ago. The boolean is false when the policy has no expiry period
+func (p PasswordPolicy) DaysUntilExpiry(changedOn, now time.Time) (int, bool) {
+	expiresAt, ok := p.PasswordExpiresAt(changedOn)
+	if !ok {
+		return 0, false
+	}
+	return int(math.Floor(expiresAt.Sub(now).Hours() / 24)), true
+}
@@ -0,0 +1,75 @@
+package iam
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPasswordPolicy() PasswordPolicy {
+	var policy PasswordPolicy
+	policy.ExpiryPeriodInDays = 90
+	policy.Complexity.MinLength = 8
+	policy.Complexity.MaxLength = 16
+	policy.Complexity.MinNumerics = 1
+	policy.Complexity.MinUpperCase = 1
+	policy.Complexity.MinLowerCase = 1
+	policy.Complexity.MinSpecialChars = 1
+	return policy
+}
+
+func TestEvaluatePassword(t *testing.T) {
+	policy := testPasswordPolicy()
+
+	result := policy.EvaluatePassword("Secr3t!Pass")
+	assert.True(t, result.Valid())
+	assert.Nil(t, result.Error())
+	assert.GreaterOrEqual(t, result.Strength, PasswordStrengthFair)
+
+	result = policy.EvaluatePassword("secret")
+	assert.False(t, result.Valid())
+	rules := make([]string, 0)
+	for _, v := range result.Violations {
+		rules = append(rules, v.Rule)
+	}
+	assert.ElementsMatch(t, []string{
+		PasswordRuleMinLength,
+		PasswordRuleMinNumerics,
+		PasswordRuleMinUpperCase,
+		PasswordRuleMinSpecialChars,
+	}, rules)
+	assert.True(t, errors.Is(result.Error(), ErrPasswordPolicyViolation))
+	assert.Equal(t, PasswordStrengthVeryWeak, result.Strength)
+
+	result = policy.EvaluatePassword("Th1s!sAVeryLongPassword")
+	if assert.Len(t, result.Violations, 1) {
+		assert.Equal(t, PasswordRuleMaxLength, result.Violations[0].Rule)
+		assert.Equal(t, 16, result.Violations[0].Required)
+		assert.Equal(t, 23, result.Violations[0].Actual)
+	}
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	assert.Equal(t, 0.0, PasswordEntropy(""))
+	assert.Less(t, PasswordEntropy("aaaaaaaa"), PasswordEntropy("abcdefgh"))
+	assert.Less(t, PasswordEntropy("abcdefgh"), PasswordEntropy("aBcD3f!h"))
+}
+
+func TestDaysUntilExpiry(t *testing.T) {
+	policy := testPasswordPolicy()
+	changedOn := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	days, ok := policy.DaysUntilExpiry(changedOn, changedOn.AddDate(0, 0, 80))
+	assert.True(t, ok)
+	assert.Equal(t, 10, days)
+
+	days, ok = policy.DaysUntilExpiry(changedOn, changedOn.AddDate(0, 0, 95))
+	assert.True(t, ok)
+	assert.Equal(t, -5, days)
+
+	policy.ExpiryPeriodInDays = 0
+	_, ok = policy.DaysUntilExpiry(changedOn, time.Now())
+	assert.False(t, ok)
+}
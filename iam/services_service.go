@@ -46,6 +46,9 @@ type GetServiceOptions struct {
 	ApplicationID  *string `url:"applicationId,omitempty"`
 	OrganizationID *string `url:"organizationId,omitempty"`
 	ServiceID      *string `url:"serviceId,omitempty"`
+	GroupID        *string `url:"groupId,omitempty"`
+	Count          *int    `url:"_count,omitempty"`
+	Page           *int    `url:"_page,omitempty"`
 }
 
 type CertificateOptionFunc func(cert *x509.Certificate) error
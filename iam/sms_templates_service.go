@@ -160,3 +160,29 @@ func (o *SMSTemplatesService) GetSMSTemplate(opt *GetSMSTemplateOptions, options
 
 	return o.GetSMSTemplateByID(bundleResponse.Resources[0].ID)
 }
+
+// SMSTemplateFilterOrg returns options which find all SMS templates of an organization
+func SMSTemplateFilterOrg(orgID string) *GetSMSTemplateOptions {
+	query := "organization.value eq \"" + orgID + "\""
+	return &GetSMSTemplateOptions{
+		Filter: &query,
+	}
+}
+
+// GetSMSTemplates retrieves all SMS templates matching the GetSMSTemplateOptions parameters.
+func (o *SMSTemplatesService) GetSMSTemplates(opt *GetSMSTemplateOptions, options ...OptionFunc) (*[]SMSTemplate, *Response, error) {
+	req, err := o.client.newRequest(IDM, "GET", "authorize/scim/v2/Configurations/SMSTemplate", opt, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("api-version", smsServicesAPIVersion)
+
+	var bundleResponse struct {
+		Resources []SMSTemplate `json:"Resources"`
+	}
+	resp, err := o.client.do(req, &bundleResponse)
+	if err != nil {
+		return nil, resp, err
+	}
+	return &bundleResponse.Resources, resp, nil
+}
@@ -0,0 +1,529 @@
+package iam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// SnapshotFormatVersion is the version of the snapshot archive format written by ExportSnapshot
+const SnapshotFormatVersion = 1
+
+const (
+	snapshotClientPasswordLength = 16
+	snapshotPageSize             = 100
+)
+
+var (
+	// ErrUnsupportedSnapshotVersion is returned when reading a snapshot written by a newer format version
+	ErrUnsupportedSnapshotVersion = errors.New("unsupported snapshot version")
+	// ErrSnapshotTruncated is returned when a list cannot be read completely
+	ErrSnapshotTruncated = errors.New("snapshot truncated")
+)
+
+// Snapshot holds the configuration of an IAM organization. Secrets such as
+// service private keys and client passwords are never included
+type Snapshot struct {
+	Version          int                   `json:"version"`
+	OrganizationID   string                `json:"organizationId"`
+	CreatedAt        time.Time             `json:"createdAt"`
+	Propositions     []SnapshotProposition `json:"propositions,omitempty"`
+	Roles            []SnapshotRole        `json:"roles,omitempty"`
+	Groups           []SnapshotGroup       `json:"groups,omitempty"`
+	PasswordPolicies []PasswordPolicy      `json:"passwordPolicies,omitempty"`
+	MFAPolicies      []MFAPolicy           `json:"mfaPolicies,omitempty"`
+	EmailTemplates   []EmailTemplate       `json:"emailTemplates,omitempty"`
+	SMSTemplates     []SMSTemplate         `json:"smsTemplates,omitempty"`
+}
+
+// SnapshotProposition is a Proposition with its applications
+type SnapshotProposition struct {
+	Proposition  Proposition           `json:"proposition"`
+	Applications []SnapshotApplication `json:"applications,omitempty"`
+}
+
+// SnapshotApplication is an Application with its services and OAuth clients
+type SnapshotApplication struct {
+	Application Application         `json:"application"`
+	Services    []Service           `json:"services,omitempty"`
+	Clients     []ApplicationClient `json:"clients,omitempty"`
+}
+
+// SnapshotRole is a Role with its permissions and sharing policies
+type SnapshotRole struct {
+	Role            Role                `json:"role"`
+	Permissions     []string            `json:"permissions,omitempty"`
+	SharingPolicies []RoleSharingPolicy `json:"sharingPolicies,omitempty"`
+}
+
+// SnapshotGroup is a Group with the IDs of its assigned roles
+type SnapshotGroup struct {
+	Group Group    `json:"group"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// ReadSnapshot decodes a snapshot archive
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.Version < 1 || snapshot.Version > SnapshotFormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedSnapshotVersion, snapshot.Version)
+	}
+	return &snapshot, nil
+}
+
+// Write encodes the snapshot as a JSON archive
+func (s Snapshot) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// ExportSnapshot reads the configuration of the organization orgID. Every paged
+// list is read completely. The role search of IAM is not paged
+func (c *Client) ExportSnapshot(orgID string) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		Version:        SnapshotFormatVersion,
+		OrganizationID: orgID,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	propositions, err := allPages(func(count, page int) ([]Proposition, error) {
+		props, _, err := c.Propositions.GetPropositions(&GetPropositionsOptions{
+			OrganizationID: &orgID,
+			Count:          &count,
+			Page:           &page,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return *props, nil
+	}, func(p Proposition) string { return p.ID })
+	if err != nil {
+		return nil, fmt.Errorf("export propositions: %w", err)
+	}
+	for _, prop := range propositions {
+		exported := SnapshotProposition{Proposition: prop}
+		apps, err := allPages(func(count, page int) ([]*Application, error) {
+			apps, _, err := c.Applications.GetApplications(&GetApplicationsOptions{
+				PropositionID: String(prop.ID),
+				Count:         &count,
+				Page:          &page,
+			})
+			if errors.Is(err, ErrEmptyResults) {
+				return nil, nil
+			}
+			return apps, err
+		}, func(a *Application) string { return a.ID })
+		if err != nil {
+			return nil, fmt.Errorf("export applications: %w", err)
+		}
+		for _, app := range apps {
+			exportedApp, err := c.exportApplication(*app)
+			if err != nil {
+				return nil, err
+			}
+			exported.Applications = append(exported.Applications, *exportedApp)
+		}
+		snapshot.Propositions = append(snapshot.Propositions, exported)
+	}
+
+	roles, _, err := c.Roles.GetRoles(&GetRolesOptions{OrganizationID: &orgID})
+	if err != nil {
+		return nil, fmt.Errorf("export roles: %w", err)
+	}
+	for _, role := range *roles {
+		exported := SnapshotRole{Role: role}
+		permissions, _, err := c.Roles.GetRolePermissions(role)
+		if err != nil {
+			return nil, fmt.Errorf("export role permissions: %w", err)
+		}
+		exported.Permissions = *permissions
+		exported.SharingPolicies, err = allPages(func(count, page int) ([]RoleSharingPolicy, error) {
+			policies, _, err := c.Roles.ListSharingPolicies(role, &ListSharingPoliciesOptions{
+				RecordsPerPage: &count,
+				StartPage:      &page,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return *policies, nil
+		}, func(p RoleSharingPolicy) string { return p.InternalID })
+		if err != nil {
+			return nil, fmt.Errorf("export sharing policies: %w", err)
+		}
+		snapshot.Roles = append(snapshot.Roles, exported)
+	}
+
+	groups, err := allPages(func(count, page int) ([]GroupResource, error) {
+		groups, _, err := c.Groups.GetGroups(&GetGroupOptions{
+			OrganizationID: &orgID,
+			Count:          &count,
+			Page:           &page,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return *groups, nil
+	}, func(g GroupResource) string { return g.ID })
+	if err != nil {
+		return nil, fmt.Errorf("export groups: %w", err)
+	}
+	for _, resource := range groups {
+		group, _, err := c.Groups.GetGroupByID(resource.ID)
+		if err != nil {
+			return nil, fmt.Errorf("export group: %w", err)
+		}
+		exported := SnapshotGroup{Group: *group}
+		groupRoles, _, err := c.Groups.GetRoles(*group)
+		if err != nil {
+			return nil, fmt.Errorf("export group roles: %w", err)
+		}
+		for _, role := range *groupRoles {
+			exported.Roles = append(exported.Roles, role.ID)
+		}
+		snapshot.Groups = append(snapshot.Groups, exported)
+	}
+
+	passwordPolicies, _, err := c.PasswordPolicies.GetPasswordPolicies(&GetPasswordPolicyOptions{OrganizationID: &orgID})
+	if err != nil {
+		return nil, fmt.Errorf("export password policies: %w", err)
+	}
+	snapshot.PasswordPolicies = *passwordPolicies
+
+	mfaPolicies, _, err := c.MFAPolicies.GetMFAPolicies(MFAPolicyFilterOrganization(orgID))
+	if err != nil {
+		return nil, fmt.Errorf("export MFA policies: %w", err)
+	}
+	snapshot.MFAPolicies = *mfaPolicies
+
+	emailTemplates, _, err := c.EmailTemplates.GetTemplates(&GetEmailTemplatesOptions{OrganizationID: &orgID})
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("export email templates: %w", err)
+	}
+	if emailTemplates != nil {
+		snapshot.EmailTemplates = *emailTemplates
+	}
+
+	smsTemplates, _, err := c.SMSTemplates.GetSMSTemplates(SMSTemplateFilterOrg(orgID))
+	if err != nil {
+		return nil, fmt.Errorf("export SMS templates: %w", err)
+	}
+	snapshot.SMSTemplates = *smsTemplates
+
+	return snapshot, nil
+}
+
+func (c *Client) exportApplication(app Application) (*SnapshotApplication, error) {
+	exported := SnapshotApplication{Application: app}
+	services, err := allPages(func(count, page int) ([]Service, error) {
+		services, _, err := c.Services.GetServices(&GetServiceOptions{
+			ApplicationID: String(app.ID),
+			Count:         &count,
+			Page:          &page,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return *services, nil
+	}, func(s Service) string { return s.ID })
+	if err != nil {
+		return nil, fmt.Errorf("export services: %w", err)
+	}
+	for _, service := range services {
+		service.PrivateKey = ""
+		exported.Services = append(exported.Services, service)
+	}
+	clients, err := allPages(func(count, page int) ([]ApplicationClient, error) {
+		clients, _, err := c.Clients.GetClients(&GetClientsOptions{
+			ApplicationID: String(app.ID),
+			Count:         &count,
+			Page:          &page,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return *clients, nil
+	}, func(c ApplicationClient) string { return c.ID })
+	if err != nil {
+		return nil, fmt.Errorf("export clients: %w", err)
+	}
+	for _, client := range clients {
+		client.Password = ""
+		exported.Clients = append(exported.Clients, client)
+	}
+	return &exported, nil
+}
+
+// allPages reads pages of snapshotPageSize until a page is not full. A page
+// repeating an earlier entry means the server ignores paging, which would
+// silently truncate the snapshot
+func allPages[T any](fetch func(count, page int) ([]T, error), id func(T) string) ([]T, error) {
+	var all []T
+	seen := make(map[string]bool)
+	for page := 1; ; page++ {
+		entries, err := fetch(snapshotPageSize, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if key := id(entry); key != "" {
+				if seen[key] {
+					return nil, fmt.Errorf("%w: page %d repeats %s", ErrSnapshotTruncated, page, key)
+				}
+				seen[key] = true
+			}
+		}
+		all = append(all, entries...)
+		if len(entries) < snapshotPageSize {
+			return all, nil
+		}
+	}
+}
+
+// SnapshotImportOptions controls how a Snapshot is restored
+type SnapshotImportOptions struct {
+	// TargetOrganizationID is the organization to restore into
+	TargetOrganizationID string
+	// IDMapping holds additional source to target ID mappings, for example
+	// for organizations referenced by role sharing policies
+	IDMapping map[string]string
+	// GlobalReferenceID, when set, is used to derive new global reference IDs
+	// for propositions, applications and clients
+	GlobalReferenceID func(sourceGlobalReferenceID string) string
+}
+
+// SnapshotImportError describes a resource which could not be restored
+type SnapshotImportError struct {
+	Resource string
+	SourceID string
+	Err      error
+}
+
+func (e *SnapshotImportError) Error() string {
+	return fmt.Sprintf("import %s %s: %v", e.Resource, e.SourceID, e.Err)
+}
+
+func (e *SnapshotImportError) Unwrap() error { return e.Err }
+
+// SnapshotImportResult reports the outcome of ImportSnapshot
+type SnapshotImportResult struct {
+	// IDMap maps source IDs to the IDs of the restored resources
+	IDMap map[string]string
+	// ServicePrivateKeys holds the private keys of restored services by new service ID
+	ServicePrivateKeys map[string]string
+	// ClientPasswords holds the generated passwords of restored clients by new client ID
+	ClientPasswords map[string]string
+	Errors          []error
+}
+
+func (r *SnapshotImportResult) fail(resource, sourceID string, err error) {
+	r.Errors = append(r.Errors, &SnapshotImportError{Resource: resource, SourceID: sourceID, Err: err})
+}
+
+func (r *SnapshotImportResult) mapID(id string) string {
+	if mapped, ok := r.IDMap[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+// ImportSnapshot restores snapshot into the target organization. Resources are
+// created in dependency order and references are remapped to the new IDs.
+// Failures do not stop the import and are collected in the result
+func (c *Client) ImportSnapshot(snapshot Snapshot, opts SnapshotImportOptions) (*SnapshotImportResult, error) {
+	if opts.TargetOrganizationID == "" {
+		return nil, ErrMissingOrganization
+	}
+	result := &SnapshotImportResult{
+		IDMap:              map[string]string{snapshot.OrganizationID: opts.TargetOrganizationID},
+		ServicePrivateKeys: make(map[string]string),
+		ClientPasswords:    make(map[string]string),
+	}
+	for k, v := range opts.IDMapping {
+		result.IDMap[k] = v
+	}
+	targetOrgID := opts.TargetOrganizationID
+	globalReferenceID := func(id string) string {
+		if opts.GlobalReferenceID != nil {
+			return opts.GlobalReferenceID(id)
+		}
+		return id
+	}
+
+	for _, prop := range snapshot.Propositions {
+		created, _, err := c.Propositions.CreateProposition(Proposition{
+			Name:              prop.Proposition.Name,
+			Description:       prop.Proposition.Description,
+			OrganizationID:    targetOrgID,
+			GlobalReferenceID: globalReferenceID(prop.Proposition.GlobalReferenceID),
+		})
+		if err != nil {
+			result.fail("proposition", prop.Proposition.ID, err)
+			continue
+		}
+		result.IDMap[prop.Proposition.ID] = created.ID
+		for _, app := range prop.Applications {
+			c.importApplication(app, created.ID, globalReferenceID, result)
+		}
+	}
+
+	for _, role := range snapshot.Roles {
+		created, _, err := c.Roles.CreateRole(role.Role.Name, role.Role.Description, targetOrgID)
+		if err != nil {
+			result.fail("role", role.Role.ID, err)
+			continue
+		}
+		result.IDMap[role.Role.ID] = created.ID
+		if len(role.Permissions) > 0 {
+			if _, _, err := c.Roles.rolePermissionAction(*created, role.Permissions, "$assign-permission"); err != nil {
+				result.fail("role permissions", role.Role.ID, err)
+			}
+		}
+		for _, policy := range role.SharingPolicies {
+			if _, _, err := c.Roles.ApplySharingPolicy(*created, RoleSharingPolicy{
+				SharingPolicy:        policy.SharingPolicy,
+				Purpose:              policy.Purpose,
+				TargetOrganizationID: result.mapID(policy.TargetOrganizationID),
+			}); err != nil {
+				result.fail("sharing policy", policy.InternalID, err)
+			}
+		}
+	}
+
+	for _, group := range snapshot.Groups {
+		created, _, err := c.Groups.CreateGroup(Group{
+			Name:                 group.Group.Name,
+			Description:          group.Group.Description,
+			ManagingOrganization: targetOrgID,
+		})
+		if err != nil {
+			result.fail("group", group.Group.ID, err)
+			continue
+		}
+		result.IDMap[group.Group.ID] = created.ID
+		for _, roleID := range group.Roles {
+			if _, _, err := c.Groups.AssignRole(*created, Role{ID: result.mapID(roleID)}); err != nil {
+				result.fail("group role", roleID, err)
+			}
+		}
+	}
+
+	for _, policy := range snapshot.PasswordPolicies {
+		sourceID := policy.ID
+		policy.ID = ""
+		policy.Meta = nil
+		policy.ManagingOrganization = targetOrgID
+		created, _, err := c.PasswordPolicies.CreatePasswordPolicy(policy)
+		if err != nil {
+			result.fail("password policy", sourceID, err)
+			continue
+		}
+		result.IDMap[sourceID] = created.ID
+	}
+
+	for _, policy := range snapshot.MFAPolicies {
+		sourceID := policy.ID
+		policy.ID = ""
+		policy.Schemas = nil
+		policy.Meta = nil
+		policy.CreatedBy = nil
+		policy.ModifiedBy = nil
+		policy.Resource.Value = result.mapID(policy.Resource.Value)
+		policy.Resource.Ref = ""
+		created, _, err := c.MFAPolicies.CreateMFAPolicy(policy)
+		if err != nil {
+			result.fail("MFA policy", sourceID, err)
+			continue
+		}
+		result.IDMap[sourceID] = created.ID
+	}
+
+	for _, template := range snapshot.EmailTemplates {
+		sourceID := template.ID
+		template.ID = ""
+		template.Meta = nil
+		template.ManagingOrganization = targetOrgID
+		created, _, err := c.EmailTemplates.CreateTemplate(template)
+		if err != nil {
+			result.fail("email template", sourceID, err)
+			continue
+		}
+		result.IDMap[sourceID] = created.ID
+	}
+
+	for _, template := range snapshot.SMSTemplates {
+		sourceID := template.ID
+		template.ID = ""
+		template.Meta = nil
+		template.Organization.Value = targetOrgID
+		created, _, err := c.SMSTemplates.CreateSMSTemplate(template)
+		if err != nil {
+			result.fail("SMS template", sourceID, err)
+			continue
+		}
+		result.IDMap[sourceID] = created.ID
+	}
+
+	return result, nil
+}
+
+func (c *Client) importApplication(app SnapshotApplication, propositionID string, globalReferenceID func(string) string, result *SnapshotImportResult) {
+	created, _, err := c.Applications.CreateApplication(Application{
+		Name:              app.Application.Name,
+		Description:       app.Application.Description,
+		PropositionID:     propositionID,
+		GlobalReferenceID: globalReferenceID(app.Application.GlobalReferenceID),
+	})
+	if err != nil {
+		result.fail("application", app.Application.ID, err)
+		return
+	}
+	result.IDMap[app.Application.ID] = created.ID
+
+	for _, service := range app.Services {
+		createdService, _, err := c.Services.CreateService(Service{
+			Name:          service.Name,
+			Description:   service.Description,
+			ApplicationID: created.ID,
+			Validity:      service.Validity,
+		})
+		if err != nil {
+			result.fail("service", service.ID, err)
+			continue
+		}
+		result.IDMap[service.ID] = createdService.ID
+		result.ServicePrivateKeys[createdService.ID] = createdService.PrivateKey
+		if len(service.Scopes) > 0 {
+			if _, _, err := c.Services.AddScopes(*createdService, service.Scopes, service.DefaultScopes); err != nil {
+				result.fail("service scopes", service.ID, err)
+			}
+		}
+	}
+
+	for _, client := range app.Clients {
+		password, err := GenerateDevicePassword(snapshotClientPasswordLength)
+		if err != nil {
+			result.fail("client", client.ID, err)
+			continue
+		}
+		sourceID := client.ID
+		client.ID = ""
+		client.Meta = nil
+		client.Realms = nil
+		client.Password = password
+		client.ApplicationID = created.ID
+		client.GlobalReferenceID = globalReferenceID(client.GlobalReferenceID)
+		createdClient, _, err := c.Clients.CreateClient(client)
+		if err != nil {
+			result.fail("client", sourceID, err)
+			continue
+		}
+		result.IDMap[sourceID] = createdClient.ID
+		result.ClientPasswords[createdClient.ID] = password
+	}
+}
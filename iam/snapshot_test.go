@@ -0,0 +1,197 @@
+package iam
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func jsonHandler(body string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+func TestExportSnapshot(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	orgID := "bda40124-54fa-4967-b2fb-23dcc4e0ad1a"
+	propID := "a8b4b4d7-6b2e-4d5c-b1fb-0a0e4b5d4c11"
+	appID := "711171ab-d28c-4616-a314-f95584e280c3"
+	roleID := "ba8a8c43-6a4f-4b37-ae52-5f2a6f2e8d22"
+	groupID := "dbf1d779-ab9f-4c27-b4aa-ea75f9efbbc0"
+
+	muxIDM.HandleFunc("/authorize/identity/Proposition", jsonHandler(`{"total":1,"entry":[
+		{"id":"`+propID+`","name":"Prop","organizationId":"`+orgID+`","globalReferenceId":"prop-ref"}]}`))
+	muxIDM.HandleFunc("/authorize/identity/Application", jsonHandler(`{"total":1,"entry":[
+		{"id":"`+appID+`","name":"App","propositionId":"`+propID+`","globalReferenceId":"app-ref"}]}`))
+	muxIDM.HandleFunc("/authorize/identity/Service", jsonHandler(`{"total":1,"entry":[
+		{"id":"svc1","name":"service","applicationId":"`+appID+`","privateKey":"secret"}]}`))
+	muxIDM.HandleFunc("/authorize/identity/Client", jsonHandler(`{"total":1,"entry":[
+		{"id":"client1","clientId":"client","name":"Client","password":"secret","applicationId":"`+appID+`"}]}`))
+	muxIDM.HandleFunc("/authorize/identity/Role", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("groupId") != "" {
+			jsonHandler(`{"total":1,"entry":[{"id":"`+roleID+`","name":"ROLE"}]}`)(w, r)
+			return
+		}
+		jsonHandler(`{"total":1,"entry":[{"id":"`+roleID+`","name":"ROLE","managingOrganization":"`+orgID+`"}]}`)(w, r)
+	})
+	muxIDM.HandleFunc("/authorize/identity/Permission", jsonHandler(`{"total":2,"entry":[{"name":"GROUP.READ"},{"name":"GROUP.WRITE"}]}`))
+	muxIDM.HandleFunc("/authorize/identity/Role/"+roleID+"/$list-sharing-policies", jsonHandler(`{"total":0,"entry":[]}`))
+	muxIDM.HandleFunc("/authorize/identity/Group", jsonHandler(`{"total":1,"entry":[
+		{"resource":{"_id":"`+groupID+`","groupName":"Group","orgId":"`+orgID+`"}}]}`))
+	muxIDM.HandleFunc("/authorize/identity/Group/"+groupID, jsonHandler(`{"id":"`+groupID+`","name":"Group","managingOrganization":"`+orgID+`"}`))
+	muxIDM.HandleFunc("/authorize/identity/PasswordPolicy", jsonHandler(`{"total":0,"entry":[]}`))
+	muxIDM.HandleFunc("/authorize/scim/v2/MFAPolicies", jsonHandler(`{"totalResults":0,"Resources":[]}`))
+	muxIDM.HandleFunc("/authorize/identity/EmailTemplate", jsonHandler(`{"total":0,"entry":[]}`))
+	muxIDM.HandleFunc("/authorize/scim/v2/Configurations/SMSTemplate", jsonHandler(`{"totalResults":0,"Resources":[]}`))
+
+	snapshot, err := client.ExportSnapshot(orgID)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, SnapshotFormatVersion, snapshot.Version)
+	if assert.Len(t, snapshot.Propositions, 1) && assert.Len(t, snapshot.Propositions[0].Applications, 1) {
+		app := snapshot.Propositions[0].Applications[0]
+		if assert.Len(t, app.Services, 1) {
+			assert.Empty(t, app.Services[0].PrivateKey)
+		}
+		if assert.Len(t, app.Clients, 1) {
+			assert.Empty(t, app.Clients[0].Password)
+		}
+	}
+	if assert.Len(t, snapshot.Roles, 1) {
+		assert.Equal(t, []string{"GROUP.READ", "GROUP.WRITE"}, snapshot.Roles[0].Permissions)
+	}
+	if assert.Len(t, snapshot.Groups, 1) {
+		assert.Equal(t, []string{roleID}, snapshot.Groups[0].Roles)
+	}
+
+	var buf bytes.Buffer
+	if !assert.Nil(t, snapshot.Write(&buf)) {
+		return
+	}
+	read, err := ReadSnapshot(&buf)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, snapshot.Groups, read.Groups)
+
+	_, err = ReadSnapshot(strings.NewReader(`{"version": 99}`))
+	assert.True(t, errors.Is(err, ErrUnsupportedSnapshotVersion))
+}
+
+func TestImportSnapshot(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	sourceOrgID := "bda40124-54fa-4967-b2fb-23dcc4e0ad1a"
+	targetOrgID := "c7e1f2f4-8a2b-4c7e-9a6e-2b7f6e1d9a33"
+	newRoleID := "f0e0d0c0-0000-4000-8000-000000000001"
+	newGroupID := "f0e0d0c0-0000-4000-8000-000000000002"
+	var assignedPermissions []string
+	var assignedRoles []string
+
+	muxIDM.HandleFunc("/authorize/identity/Role", func(w http.ResponseWriter, r *http.Request) {
+		var role Role
+		_ = json.NewDecoder(r.Body).Decode(&role)
+		assert.Equal(t, targetOrgID, role.ManagingOrganization)
+		role.ID = newRoleID
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(role)
+	})
+	muxIDM.HandleFunc("/authorize/identity/Role/"+newRoleID+"/$assign-permission", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Permissions []string `json:"permissions"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		assignedPermissions = body.Permissions
+		jsonHandler(`{}`)(w, r)
+	})
+	muxIDM.HandleFunc("/authorize/identity/Group", func(w http.ResponseWriter, r *http.Request) {
+		var group Group
+		_ = json.NewDecoder(r.Body).Decode(&group)
+		assert.Equal(t, targetOrgID, group.ManagingOrganization)
+		group.ID = newGroupID
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(group)
+	})
+	muxIDM.HandleFunc("/authorize/identity/Group/"+newGroupID+"/$assign-role", func(w http.ResponseWriter, r *http.Request) {
+		var body groupRequest
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		assignedRoles = body.Roles
+		jsonHandler(`{}`)(w, r)
+	})
+
+	snapshot := Snapshot{
+		Version:        SnapshotFormatVersion,
+		OrganizationID: sourceOrgID,
+		Roles: []SnapshotRole{
+			{
+				Role:        Role{ID: "old-role", Name: "ROLE", ManagingOrganization: sourceOrgID},
+				Permissions: []string{"GROUP.READ"},
+			},
+		},
+		Groups: []SnapshotGroup{
+			{
+				Group: Group{ID: "old-group", Name: "Group", ManagingOrganization: sourceOrgID},
+				Roles: []string{"old-role"},
+			},
+		},
+	}
+
+	_, err := client.ImportSnapshot(snapshot, SnapshotImportOptions{})
+	assert.Equal(t, ErrMissingOrganization, err)
+
+	result, err := client.ImportSnapshot(snapshot, SnapshotImportOptions{TargetOrganizationID: targetOrgID})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, newRoleID, result.IDMap["old-role"])
+	assert.Equal(t, newGroupID, result.IDMap["old-group"])
+	assert.Equal(t, targetOrgID, result.IDMap[sourceOrgID])
+	assert.Equal(t, []string{"GROUP.READ"}, assignedPermissions)
+	assert.Equal(t, []string{newRoleID}, assignedRoles)
+}
+
+func TestSnapshotAllPages(t *testing.T) {
+	var requested []int
+	entries, err := allPages(func(count, page int) ([]string, error) {
+		requested = append(requested, page)
+		n := count
+		if page == 3 {
+			n = 1
+		}
+		ids := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			ids = append(ids, fmt.Sprintf("%d-%d", page, i))
+		}
+		return ids, nil
+	}, func(id string) string { return id })
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2*snapshotPageSize+1)
+	assert.Equal(t, []int{1, 2, 3}, requested)
+
+	// A server ignoring the page parameter returns the first page forever
+	_, err = allPages(func(count, page int) ([]string, error) {
+		ids := make([]string, 0, count)
+		for i := 0; i < count; i++ {
+			ids = append(ids, fmt.Sprintf("%d", i))
+		}
+		return ids, nil
+	}, func(id string) string { return id })
+	assert.True(t, errors.Is(err, ErrSnapshotTruncated))
+}
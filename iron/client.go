@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/philips-software/go-hsdp-api/internal"
 
@@ -36,6 +37,9 @@ type Config struct {
 	ProjectID   string        `cloud:"project_id" json:"project_id"`
 	Token       string        `cloud:"token" json:"token"`
 	UserID      string        `cloud:"user_id" json:"user_id"`
+	// PollInterval is the initial interval between task checks of Wait and
+	// StreamTask. Defaults to 5 seconds
+	PollInterval time.Duration `cloud:"-" json:"-"`
 }
 
 // ClusterInfo contains details on an Iron cluster
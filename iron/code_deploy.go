@@ -0,0 +1,148 @@
+package iron
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DeployOptions control DeployCode
+type DeployOptions struct {
+	// Credentials are stored before the image is registered, for private registries
+	Credentials *DockerCredentials
+}
+
+// Deployment is the outcome of DeployCode
+type Deployment struct {
+	// Code is the deployed revision
+	Code *Code
+	// Previous is the code before the deployment, nil for new code
+	Previous *Code
+	// PreviousImage is the image before the deployment, empty for new code
+	PreviousImage string
+	// PreviousRev is the revision before the deployment, 0 for new code
+	PreviousRev int
+	// Schedules are the active schedules of the code. Iron schedules refer to
+	// code by name and carry no revision, so they run the new revision from
+	// their next run on without being updated
+	Schedules []Schedule
+	// Tasks are the queued and running tasks of the code. Tasks already
+	// running keep the previous revision until they finish
+	Tasks []Task
+}
+
+// GetCodeByName gets the code with the given name
+func (c *CodesServices) GetCodeByName(name string) (*Code, *Response, error) {
+	codes, resp, err := c.GetCodes()
+	if err != nil {
+		return nil, resp, err
+	}
+	for _, code := range *codes {
+		if code.Name == name {
+			found := code
+			return &found, resp, nil
+		}
+	}
+	return nil, resp, ErrNotFound
+}
+
+// DeployCode logs in to the Docker registry when credentials are given, registers
+// the image of code and lists the schedules and tasks of the code. Schedules refer
+// to code by name so they pick up the new revision without being changed. When the
+// registration fails the previous code is registered again. When only listing the
+// schedules or tasks fails the deployment is kept and returned with the error
+func (c *CodesServices) DeployCode(code Code, options DeployOptions) (*Deployment, error) {
+	if code.Name == "" || code.Image == "" {
+		return nil, fmt.Errorf("%w: code needs a name and an image", ErrInvalidDeployment)
+	}
+	deployment := &Deployment{}
+	previous, _, err := c.GetCodeByName(code.Name)
+	switch {
+	case err == nil:
+		deployment.Previous = previous
+		deployment.PreviousImage = previous.Image
+		deployment.PreviousRev = previous.Rev
+	case !errors.Is(err, ErrNotFound):
+		return nil, err
+	}
+	if options.Credentials != nil {
+		ok, _, err := c.DockerLogin(*options.Credentials)
+		if err == nil && !ok {
+			err = fmt.Errorf("%w: docker login refused", ErrUnexpectedResponse)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("docker login: %w", err)
+		}
+	}
+	deployed, _, err := c.CreateOrUpdateCode(code)
+	if err == nil && (deployed == nil || deployed.Name != code.Name || deployed.Image != code.Image) {
+		err = fmt.Errorf("%w: image %s not registered", ErrUnexpectedResponse, code.Image)
+	}
+	if err != nil {
+		// A failed registration may still have created a revision
+		if deployed != nil && deployed.ID != "" {
+			deployment.Code = deployed
+			if rollbackErr := c.RollbackCode(deployment); rollbackErr != nil {
+				return nil, fmt.Errorf("register %s: %w (rollback failed: %v)", code.Image, err, rollbackErr)
+			}
+		}
+		return nil, fmt.Errorf("register %s: %w", code.Image, err)
+	}
+	deployment.Code = deployed
+
+	if err := c.affected(deployment); err != nil {
+		return deployment, err
+	}
+	return deployment, nil
+}
+
+// affected lists the active schedules and unfinished tasks of the deployed code
+func (c *CodesServices) affected(deployment *Deployment) error {
+	schedules, _, err := c.client.Schedules.GetSchedulesWithCode(deployment.Code.Name)
+	if err != nil {
+		return fmt.Errorf("listing schedules: %w", err)
+	}
+	for _, schedule := range *schedules {
+		if schedule.Status != ScheduleStatusCancelled {
+			deployment.Schedules = append(deployment.Schedules, schedule)
+		}
+	}
+	tasks, _, err := c.client.Tasks.GetTasks()
+	if err != nil {
+		return fmt.Errorf("listing tasks: %w", err)
+	}
+	for _, task := range *tasks {
+		if task.CodeName == deployment.Code.Name && !task.Finished() {
+			deployment.Tasks = append(deployment.Tasks, task)
+		}
+	}
+	return nil
+}
+
+// RollbackCode registers the previous code of the deployment again. Code that
+// was new with the deployment is deleted
+func (c *CodesServices) RollbackCode(deployment *Deployment) error {
+	if deployment == nil || deployment.Code == nil {
+		return fmt.Errorf("%w: nothing deployed", ErrInvalidDeployment)
+	}
+	if deployment.Previous == nil {
+		ok, _, err := c.DeleteCode(deployment.Code.ID)
+		if err == nil && !ok {
+			err = fmt.Errorf("%w: code %s not deleted", ErrUnexpectedResponse, deployment.Code.ID)
+		}
+		return err
+	}
+	// Fields set by Iron are assigned again on registration
+	previous := *deployment.Previous
+	previous.ID = ""
+	previous.CreatedAt = nil
+	previous.ProjectID = ""
+	previous.LatestChecksum = ""
+	previous.Rev = 0
+	previous.LatestHistoryID = ""
+	previous.LatestChange = nil
+	restored, _, err := c.CreateOrUpdateCode(previous)
+	if err == nil && (restored == nil || restored.Image != previous.Image) {
+		err = fmt.Errorf("%w: image %s not registered", ErrUnexpectedResponse, previous.Image)
+	}
+	return err
+}
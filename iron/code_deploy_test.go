@@ -0,0 +1,159 @@
+package iron_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/philips-software/go-hsdp-api/iron"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCodes serves a single code package that is updated on every registration
+type fakeCodes struct {
+	code       iron.Code
+	registered []string
+	logins     int
+	deleted    bool
+}
+
+func (f *fakeCodes) register(t *testing.T) {
+	muxIRON.HandleFunc(client.Path("projects", projectID, "codes"), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			codes := []iron.Code{}
+			if f.code.ID != "" && !f.deleted {
+				codes = append(codes, f.code)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"codes": codes})
+		case "POST":
+			_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			form, err := multipart.NewReader(r.Body, params["boundary"]).ReadForm(1 << 20)
+			if !assert.Nil(t, err) {
+				return
+			}
+			var code iron.Code
+			_ = json.Unmarshal([]byte(form.Value["data"][0]), &code)
+			f.registered = append(f.registered, code.Image)
+			f.code.ID = "code-1"
+			f.code.Name = code.Name
+			f.code.Image = code.Image
+			f.code.Rev++
+			f.deleted = false
+			_, _ = io.WriteString(w, `{"id":"code-1","msg":"Upload successful."}`)
+		}
+	})
+	muxIRON.HandleFunc(client.Path("projects", projectID, "codes", "code-1"), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			_ = json.NewEncoder(w).Encode(f.code)
+		case "DELETE":
+			f.deleted = true
+			_, _ = io.WriteString(w, `{"msg":"Deleted"}`)
+		}
+	})
+	muxIRON.HandleFunc(client.Path("projects", projectID, "credentials"), func(w http.ResponseWriter, r *http.Request) {
+		f.logins++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"msg":"Credentials added."}`)
+	})
+	muxIRON.HandleFunc(client.Path("projects", projectID, "schedules"), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"schedules": [
+  {"id": "s1", "code_name": "worker", "status": "scheduled"},
+  {"id": "s2", "code_name": "worker", "status": "cancelled"},
+  {"id": "s3", "code_name": "other", "status": "scheduled"}
+]}`)
+	})
+}
+
+func TestCodesServices_DeployCode(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	fake := &fakeCodes{code: iron.Code{ID: "code-1", Name: "worker", Image: "worker:1.0", Rev: 3}}
+	fake.register(t)
+	muxIRON.HandleFunc(client.Path("projects", projectID, "tasks"), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"tasks": [
+  {"id": "t1", "code_name": "worker", "status": "running"},
+  {"id": "t2", "code_name": "worker", "status": "complete"},
+  {"id": "t3", "code_name": "other", "status": "queued"}
+]}`)
+	})
+
+	deployment, err := client.Codes.DeployCode(iron.Code{Name: "worker", Image: "worker:1.1"}, iron.DeployOptions{
+		Credentials: &iron.DockerCredentials{
+			Email:         "ops@example.com",
+			Username:      "ops",
+			Password:      "secret",
+			ServerAddress: "registry.example.com",
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 1, fake.logins)
+	assert.Equal(t, "worker:1.1", deployment.Code.Image)
+	assert.Equal(t, "worker:1.0", deployment.PreviousImage)
+	assert.Equal(t, 3, deployment.PreviousRev)
+	if assert.NotNil(t, deployment.Previous) {
+		assert.Equal(t, "worker:1.0", deployment.Previous.Image)
+	}
+	if assert.Len(t, deployment.Schedules, 1) {
+		assert.Equal(t, "s1", deployment.Schedules[0].ID)
+	}
+	if assert.Len(t, deployment.Tasks, 1) {
+		assert.Equal(t, "t1", deployment.Tasks[0].ID)
+	}
+
+	err = client.Codes.RollbackCode(deployment)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"worker:1.1", "worker:1.0"}, fake.registered)
+	assert.Equal(t, "worker:1.0", fake.code.Image)
+
+	_, err = client.Codes.DeployCode(iron.Code{Name: "worker"}, iron.DeployOptions{})
+	assert.True(t, errors.Is(err, iron.ErrInvalidDeployment))
+	_, err = client.Codes.DeployCode(iron.Code{Name: "worker", Image: "worker:1.2"}, iron.DeployOptions{
+		Credentials: &iron.DockerCredentials{Username: "ops"},
+	})
+	assert.True(t, errors.Is(err, iron.ErrInvalidDockerCredentials))
+	assert.Len(t, fake.registered, 2, "nothing is registered after a failed login")
+}
+
+func TestCodesServices_DeployCodeRollback(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	fake := &fakeCodes{}
+	fake.register(t)
+	muxIRON.HandleFunc(client.Path("projects", projectID, "tasks"), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"tasks": [`)
+	})
+
+	// A failure to list the tasks keeps the deployment
+	deployment, err := client.Codes.DeployCode(iron.Code{Name: "worker", Image: "worker:1.0"}, iron.DeployOptions{})
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"worker:1.0"}, fake.registered)
+	assert.False(t, fake.deleted)
+	if !assert.NotNil(t, deployment) {
+		return
+	}
+	assert.Nil(t, deployment.Previous)
+	assert.Len(t, deployment.Schedules, 1)
+
+	// New code is deleted on rollback
+	err = client.Codes.RollbackCode(deployment)
+	assert.Nil(t, err)
+	assert.True(t, fake.deleted)
+
+	err = client.Codes.RollbackCode(&iron.Deployment{})
+	assert.True(t, errors.Is(err, iron.ErrInvalidDeployment))
+}
@@ -8,4 +8,9 @@ var (
 	ErrNotFound                 = errors.New("not found")
 	ErrInvalidDockerCredentials = errors.New("invalid docker credentials. all fields required")
 	ErrNoPublicKey              = errors.New("no public key present")
+	ErrUnexpectedResponse       = errors.New("unexpected response")
+	ErrTaskFailed               = errors.New("task failed")
+	ErrInvalidSchedule          = errors.New("invalid schedule")
+	ErrUnsupportedSchedule      = errors.New("unsupported schedule")
+	ErrInvalidDeployment        = errors.New("invalid deployment")
 )
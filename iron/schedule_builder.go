@@ -0,0 +1,328 @@
+package iron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Iron schedule limits
+const (
+	MinRunEvery    = time.Minute
+	MaxTimeout     = 24 * time.Hour
+	DefaultTimeout = time.Hour
+	MaxPayloadSize = 64 * 1024
+)
+
+// Schedule statuses
+const (
+	ScheduleStatusScheduled = "scheduled"
+	ScheduleStatusCancelled = "cancelled"
+)
+
+// ScheduleBuilder builds a validated Schedule for a code package. Iron runs
+// schedules at a fixed interval, so only cron expressions with a fixed interval
+// are accepted. All times are UTC. Errors are reported by Build
+type ScheduleBuilder struct {
+	codeName string
+	cron     string
+	every    time.Duration
+	startAt  time.Time
+	endAt    time.Time
+	runTimes int
+	timeout  time.Duration
+	cluster  ClusterInfo
+	payload  string
+	encrypt  bool
+}
+
+// NewScheduleBuilder returns a builder for a schedule of the code package
+func NewScheduleBuilder(codeName string) *ScheduleBuilder {
+	return &ScheduleBuilder{codeName: codeName, timeout: DefaultTimeout}
+}
+
+// Cron sets a cron expression with five fields, e.g. "*/15 * * * *" or "30 2 * * 1",
+// or a descriptor: @hourly, @daily, @midnight, @weekly or @every <duration>.
+// Supported are minute steps dividing an hour, hour steps dividing a day, and
+// hourly, daily and weekly runs at a fixed time
+func (b *ScheduleBuilder) Cron(expression string) *ScheduleBuilder {
+	b.cron = expression
+	return b
+}
+
+// Every sets the interval between runs
+func (b *ScheduleBuilder) Every(interval time.Duration) *ScheduleBuilder {
+	b.every = interval
+	return b
+}
+
+// StartAt sets the first run. With a cron expression the first run is the first
+// matching time at or after start. Defaults to now
+func (b *ScheduleBuilder) StartAt(start time.Time) *ScheduleBuilder {
+	b.startAt = start
+	return b
+}
+
+// EndAt sets the time after which the schedule no longer runs
+func (b *ScheduleBuilder) EndAt(end time.Time) *ScheduleBuilder {
+	b.endAt = end
+	return b
+}
+
+// RunTimes limits the number of runs
+func (b *ScheduleBuilder) RunTimes(n int) *ScheduleBuilder {
+	b.runTimes = n
+	return b
+}
+
+// Timeout sets the maximum duration of a run. Defaults to DefaultTimeout
+func (b *ScheduleBuilder) Timeout(timeout time.Duration) *ScheduleBuilder {
+	b.timeout = timeout
+	return b
+}
+
+// Cluster sets the cluster to run on
+func (b *ScheduleBuilder) Cluster(cluster ClusterInfo) *ScheduleBuilder {
+	b.cluster = cluster
+	return b
+}
+
+// Payload sets the payload of the tasks
+func (b *ScheduleBuilder) Payload(payload string) *ScheduleBuilder {
+	b.payload = payload
+	return b
+}
+
+// Encrypt encrypts the payload with the public key of the cluster
+func (b *ScheduleBuilder) Encrypt() *ScheduleBuilder {
+	b.encrypt = true
+	return b
+}
+
+// Build validates the settings and returns the schedule
+func (b *ScheduleBuilder) Build() (*Schedule, error) {
+	interval, start, err := b.timing()
+	if err != nil {
+		return nil, err
+	}
+	if b.codeName == "" {
+		return nil, fmt.Errorf("%w: missing code name", ErrInvalidSchedule)
+	}
+	if b.timeout <= 0 || b.timeout > MaxTimeout || b.timeout%time.Second != 0 {
+		return nil, fmt.Errorf("%w: timeout %s must be whole seconds up to %s", ErrInvalidSchedule, b.timeout, MaxTimeout)
+	}
+	if b.runTimes < 0 {
+		return nil, fmt.Errorf("%w: negative run times", ErrInvalidSchedule)
+	}
+	if !b.endAt.IsZero() && !b.endAt.After(start) {
+		return nil, fmt.Errorf("%w: end %s is not after start %s", ErrInvalidSchedule, b.endAt, start)
+	}
+	if b.encrypt && b.cluster.ClusterID == "" {
+		return nil, fmt.Errorf("%w: encryption requires a cluster", ErrInvalidSchedule)
+	}
+	payload := b.payload
+	if b.encrypt {
+		if payload, err = b.cluster.Encrypt([]byte(b.payload)); err != nil {
+			return nil, err
+		}
+	}
+	if len(payload) > MaxPayloadSize {
+		return nil, fmt.Errorf("%w: payload of %d bytes exceeds %d", ErrInvalidSchedule, len(payload), MaxPayloadSize)
+	}
+	schedule := &Schedule{
+		CodeName: b.codeName,
+		StartAt:  &start,
+		RunEvery: int(interval / time.Second),
+		RunTimes: b.runTimes,
+		Timeout:  int(b.timeout / time.Second),
+		Cluster:  b.cluster.ClusterID,
+		Payload:  payload,
+	}
+	if !b.endAt.IsZero() {
+		end := b.endAt.UTC()
+		schedule.EndAt = &end
+	}
+	return schedule, nil
+}
+
+// Preview returns up to n upcoming run times, taking RunTimes and EndAt into account
+func (b *ScheduleBuilder) Preview(n int) ([]time.Time, error) {
+	interval, start, err := b.timing()
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	if b.runTimes > 0 && b.runTimes < n {
+		n = b.runTimes
+	}
+	runs := make([]time.Time, 0, n)
+	for run := start; len(runs) < n; run = run.Add(interval) {
+		if !b.endAt.IsZero() && run.After(b.endAt) {
+			break
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// timing returns the interval and the first run
+func (b *ScheduleBuilder) timing() (time.Duration, time.Time, error) {
+	from := b.startAt
+	if from.IsZero() {
+		from = time.Now()
+	}
+	from = from.UTC()
+	if b.cron != "" && b.every != 0 {
+		return 0, from, fmt.Errorf("%w: set either a cron expression or an interval", ErrInvalidSchedule)
+	}
+	if b.cron == "" {
+		if err := validInterval(b.every); err != nil {
+			return 0, from, err
+		}
+		return b.every, from, nil
+	}
+	interval, offset, err := parseCron(b.cron)
+	if err != nil {
+		return 0, from, err
+	}
+	if offset < 0 {
+		// @every has no fixed phase
+		return interval, from, nil
+	}
+	// Runs are at offset + k*interval since the Unix epoch
+	since := from.Sub(time.Unix(0, 0).Add(offset))
+	periods := since / interval
+	if since%interval != 0 && since > 0 {
+		periods++
+	}
+	return interval, time.Unix(0, 0).Add(offset).Add(periods * interval).UTC(), nil
+}
+
+func validInterval(interval time.Duration) error {
+	if interval < MinRunEvery {
+		return fmt.Errorf("%w: interval %s is below the minimum of %s", ErrInvalidSchedule, interval, MinRunEvery)
+	}
+	if interval%time.Second != 0 {
+		return fmt.Errorf("%w: interval %s must be whole seconds", ErrInvalidSchedule, interval)
+	}
+	return nil
+}
+
+// parseCron translates a cron expression into an interval and the offset of the
+// runs from the Unix epoch, a Thursday at midnight UTC. A negative offset means
+// runs are not aligned
+func parseCron(expression string) (time.Duration, time.Duration, error) {
+	unsupported := func(reason string) error {
+		return fmt.Errorf("%w: %q %s", ErrUnsupportedSchedule, expression, reason)
+	}
+	switch expression {
+	case "@hourly":
+		expression = "0 * * * *"
+	case "@daily", "@midnight":
+		expression = "0 0 * * *"
+	case "@weekly":
+		expression = "0 0 * * 0"
+	}
+	if strings.HasPrefix(expression, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expression, "@every ")))
+		if err != nil {
+			return 0, 0, unsupported(err.Error())
+		}
+		if err := validInterval(interval); err != nil {
+			return 0, 0, err
+		}
+		return interval, -1, nil
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return 0, 0, unsupported("must have five fields")
+	}
+	minute, hour, dom, month, dow := fields[0], fields[1], fields[2], fields[3], fields[4]
+	if dom != "*" || month != "*" {
+		return 0, 0, unsupported("day of month and month must be *")
+	}
+	if step, ok := cronStep(minute); ok {
+		if hour != "*" || dow != "*" || 60%step != 0 {
+			return 0, 0, unsupported("has no fixed interval")
+		}
+		return time.Duration(step) * time.Minute, 0, nil
+	}
+	m, err := cronNumber(minute, 0, 59)
+	if err != nil {
+		return 0, 0, unsupported(err.Error())
+	}
+	offset := time.Duration(m) * time.Minute
+	if step, ok := cronStep(hour); ok {
+		if dow != "*" || 24%step != 0 {
+			return 0, 0, unsupported("has no fixed interval")
+		}
+		return time.Duration(step) * time.Hour, offset, nil
+	}
+	h, err := cronNumber(hour, 0, 23)
+	if err != nil {
+		return 0, 0, unsupported(err.Error())
+	}
+	offset += time.Duration(h) * time.Hour
+	if dow == "*" {
+		return 24 * time.Hour, offset, nil
+	}
+	d, err := cronNumber(dow, 0, 7)
+	if err != nil {
+		return 0, 0, unsupported(err.Error())
+	}
+	// The epoch is a Thursday, day 4
+	days := (d%7 - 4 + 7) % 7
+	return 7 * 24 * time.Hour, offset + time.Duration(days)*24*time.Hour, nil
+}
+
+// cronStep parses "*" and "*/n"
+func cronStep(field string) (int, bool) {
+	if field == "*" {
+		return 1, true
+	}
+	if !strings.HasPrefix(field, "*/") {
+		return 0, false
+	}
+	step, err := strconv.Atoi(strings.TrimPrefix(field, "*/"))
+	if err != nil || step <= 0 {
+		return 0, false
+	}
+	return step, true
+}
+
+func cronNumber(field string, min, max int) (int, error) {
+	n, err := strconv.Atoi(field)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("field %q must be a number from %d to %d", field, min, max)
+	}
+	return n, nil
+}
+
+// matches returns true when the existing schedule runs like the desired one
+func (b *ScheduleBuilder) matches(existing, desired Schedule) bool {
+	if existing.RunEvery != desired.RunEvery || existing.RunTimes != desired.RunTimes ||
+		existing.Timeout != desired.Timeout || existing.Cluster != desired.Cluster {
+		return false
+	}
+	// Encrypted payloads differ every time they are encrypted, so a changed
+	// payload cannot be told apart and encrypted schedules are always replaced
+	if b.encrypt || existing.Payload != desired.Payload {
+		return false
+	}
+	if (existing.EndAt == nil) != (desired.EndAt == nil) ||
+		(existing.EndAt != nil && !existing.EndAt.Equal(*desired.EndAt)) {
+		return false
+	}
+	// Interval schedules without a start time run whenever they were created
+	if b.startAt.IsZero() && (b.cron == "" || strings.HasPrefix(b.cron, "@every")) {
+		return true
+	}
+	if existing.StartAt == nil || desired.RunEvery == 0 {
+		return false
+	}
+	phase := desired.StartAt.Sub(*existing.StartAt) % (time.Duration(desired.RunEvery) * time.Second)
+	return phase == 0
+}
@@ -0,0 +1,239 @@
+package iron_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philips-software/go-hsdp-api/iron"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func at(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339, value)
+	return t
+}
+
+func TestScheduleBuilder_Preview(t *testing.T) {
+	for _, c := range []struct {
+		cron  string
+		start string
+		runs  []string
+	}{
+		{"*/15 * * * *", "2024-01-01T10:07:00Z", []string{"2024-01-01T10:15:00Z", "2024-01-01T10:30:00Z", "2024-01-01T10:45:00Z"}},
+		{"0 */6 * * *", "2024-01-01T10:00:00Z", []string{"2024-01-01T12:00:00Z", "2024-01-01T18:00:00Z", "2024-01-02T00:00:00Z"}},
+		{"@daily", "2024-01-01T00:00:00Z", []string{"2024-01-01T00:00:00Z", "2024-01-02T00:00:00Z", "2024-01-03T00:00:00Z"}},
+		{"30 2 * * 1", "2024-01-03T12:00:00Z", []string{"2024-01-08T02:30:00Z", "2024-01-15T02:30:00Z", "2024-01-22T02:30:00Z"}},
+		{"@every 90m", "2024-01-01T10:07:00Z", []string{"2024-01-01T10:07:00Z", "2024-01-01T11:37:00Z", "2024-01-01T13:07:00Z"}},
+	} {
+		runs, err := iron.NewScheduleBuilder("code").Cron(c.cron).StartAt(at(c.start)).Preview(3)
+		if !assert.Nil(t, err, c.cron) {
+			continue
+		}
+		expected := make([]time.Time, len(c.runs))
+		for i, r := range c.runs {
+			expected[i] = at(r)
+		}
+		assert.Equal(t, expected, runs, c.cron)
+	}
+
+	runs, err := iron.NewScheduleBuilder("code").Every(time.Hour).StartAt(at("2024-01-01T10:00:00Z")).RunTimes(2).Preview(5)
+	assert.Nil(t, err)
+	assert.Len(t, runs, 2)
+	runs, err = iron.NewScheduleBuilder("code").Every(time.Hour).StartAt(at("2024-01-01T10:00:00Z")).
+		EndAt(at("2024-01-01T12:30:00Z")).Preview(5)
+	assert.Nil(t, err)
+	assert.Len(t, runs, 3)
+	for _, n := range []int{0, -1} {
+		runs, err = iron.NewScheduleBuilder("code").Every(time.Hour).Preview(n)
+		assert.Nil(t, err)
+		assert.Empty(t, runs)
+	}
+}
+
+func TestScheduleBuilder_Build(t *testing.T) {
+	schedule, err := iron.NewScheduleBuilder("code").
+		Cron("0 3 * * *").
+		StartAt(at("2024-01-01T10:00:00Z")).
+		Timeout(30 * time.Minute).
+		Cluster(iron.ClusterInfo{ClusterID: "cluster"}).
+		Payload(`{"foo": "bar"}`).
+		Build()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "code", schedule.CodeName)
+	assert.Equal(t, at("2024-01-02T03:00:00Z"), *schedule.StartAt)
+	assert.Equal(t, 86400, schedule.RunEvery)
+	assert.Equal(t, 1800, schedule.Timeout)
+	assert.Equal(t, "cluster", schedule.Cluster)
+	assert.Equal(t, `{"foo": "bar"}`, schedule.Payload)
+
+	for _, c := range []struct {
+		builder *iron.ScheduleBuilder
+		err     error
+	}{
+		{iron.NewScheduleBuilder("code").Cron("0 9-17 * * *"), iron.ErrUnsupportedSchedule},
+		{iron.NewScheduleBuilder("code").Cron("*/7 * * * *"), iron.ErrUnsupportedSchedule},
+		{iron.NewScheduleBuilder("code").Cron("0 0 1 * *"), iron.ErrUnsupportedSchedule},
+		{iron.NewScheduleBuilder("code").Cron("@every 30s"), iron.ErrInvalidSchedule},
+		{iron.NewScheduleBuilder("code").Every(30 * time.Second), iron.ErrInvalidSchedule},
+		{iron.NewScheduleBuilder("code").Every(time.Hour).Cron("@hourly"), iron.ErrInvalidSchedule},
+		{iron.NewScheduleBuilder("code").Every(time.Hour).Timeout(48 * time.Hour), iron.ErrInvalidSchedule},
+		{iron.NewScheduleBuilder("").Every(time.Hour), iron.ErrInvalidSchedule},
+		{iron.NewScheduleBuilder("code").Every(time.Hour).Payload(strings.Repeat("x", iron.MaxPayloadSize+1)), iron.ErrInvalidSchedule},
+		{iron.NewScheduleBuilder("code").Every(time.Hour).Encrypt(), iron.ErrInvalidSchedule},
+		{iron.NewScheduleBuilder("code").Every(time.Hour).Cluster(iron.ClusterInfo{ClusterID: "c"}).Encrypt(), iron.ErrNoPublicKey},
+	} {
+		_, err := c.builder.Build()
+		assert.True(t, errors.Is(err, c.err), "%v", err)
+	}
+}
+
+func TestScheduleBuilder_Encrypt(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.Nil(t, err) {
+		return
+	}
+	public, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	cluster := iron.ClusterInfo{
+		ClusterID: "cluster",
+		Pubkey:    string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})),
+	}
+	schedule, err := iron.NewScheduleBuilder("code").Every(time.Hour).Cluster(cluster).Payload("secret").Encrypt().Build()
+	if !assert.Nil(t, err) {
+		return
+	}
+	private := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	payload, err := iron.DecryptPayload(private, schedule.Payload)
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", string(payload))
+}
+
+func TestSchedulesServices_ApplySchedule(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	schedules := []iron.Schedule{
+		{ID: "old", CodeName: "code", Status: iron.ScheduleStatusScheduled, RunEvery: 3600, Timeout: 3600},
+		{ID: "gone", CodeName: "code", Status: iron.ScheduleStatusCancelled, RunEvery: 86400, Timeout: 3600},
+		{ID: "other", CodeName: "other", Status: iron.ScheduleStatusScheduled, RunEvery: 60, Timeout: 3600},
+	}
+	var cancelled []string
+	created := 0
+	muxIRON.HandleFunc(client.Path("projects", projectID, "schedules"), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			var request struct {
+				Schedules []iron.Schedule `json:"schedules"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			created++
+			schedule := request.Schedules[0]
+			schedule.ID = fmt.Sprintf("new-%d", created)
+			schedule.Status = iron.ScheduleStatusScheduled
+			schedules = append(schedules, schedule)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"schedules": []iron.Schedule{schedule}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"schedules": schedules})
+	})
+	for _, id := range []string{"old", "new-1"} {
+		id := id
+		muxIRON.HandleFunc(client.Path("projects", projectID, "schedules", id, "cancel"), func(w http.ResponseWriter, r *http.Request) {
+			cancelled = append(cancelled, id)
+			for i := range schedules {
+				if schedules[i].ID == id {
+					schedules[i].Status = iron.ScheduleStatusCancelled
+				}
+			}
+			_, _ = io.WriteString(w, `{"msg": "Cancelled"}`)
+		})
+	}
+
+	builder := iron.NewScheduleBuilder("code").Cron("@daily")
+	result, err := client.Schedules.ApplySchedule(builder)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, result.Created)
+	assert.Equal(t, "new-1", result.Schedule.ID)
+	assert.Equal(t, []string{"old"}, result.Cancelled)
+
+	// Applying again keeps the schedule, even though the next run moved on
+	result, err = client.Schedules.ApplySchedule(builder.StartAt(time.Now().Add(72 * time.Hour)))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.False(t, result.Created)
+	assert.Equal(t, "new-1", result.Schedule.ID)
+	assert.Empty(t, result.Cancelled)
+
+	result, err = client.Schedules.ReplaceSchedule(builder)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, result.Created)
+	assert.Equal(t, "new-2", result.Schedule.ID)
+	assert.Equal(t, []string{"new-1"}, result.Cancelled)
+	assert.Equal(t, []string{"old", "new-1"}, cancelled)
+}
+
+func TestSchedulesServices_ApplyEncryptedSchedule(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.Nil(t, err) {
+		return
+	}
+	public, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	cluster := iron.ClusterInfo{
+		ClusterID: "cluster",
+		Pubkey:    string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})),
+	}
+	schedules := []iron.Schedule{
+		{ID: "old", CodeName: "code", Status: iron.ScheduleStatusScheduled, RunEvery: 3600, Timeout: 3600, Cluster: "cluster", Payload: "encrypted"},
+	}
+	var cancelled []string
+	muxIRON.HandleFunc(client.Path("projects", projectID, "schedules"), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			var request struct {
+				Schedules []iron.Schedule `json:"schedules"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			schedule := request.Schedules[0]
+			schedule.ID = "new"
+			schedule.Status = iron.ScheduleStatusScheduled
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"schedules": []iron.Schedule{schedule}})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"schedules": schedules})
+	})
+	muxIRON.HandleFunc(client.Path("projects", projectID, "schedules", "old", "cancel"), func(w http.ResponseWriter, r *http.Request) {
+		cancelled = append(cancelled, "old")
+		_, _ = io.WriteString(w, `{"msg": "Cancelled"}`)
+	})
+
+	// The payload may have changed, so the schedule is replaced even though the timing matches
+	builder := iron.NewScheduleBuilder("code").Every(time.Hour).Cluster(cluster).Payload("changed").Encrypt()
+	result, err := client.Schedules.ApplySchedule(builder)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, result.Created)
+	assert.Equal(t, "new", result.Schedule.ID)
+	assert.Equal(t, []string{"old"}, result.Cancelled)
+	assert.Equal(t, []string{"old"}, cancelled)
+}
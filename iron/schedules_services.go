@@ -1,6 +1,9 @@
 package iron
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type SchedulesServices struct {
 	client    *Client
@@ -146,3 +149,70 @@ func (s *SchedulesServices) CancelSchedule(scheduleID string) (bool, *Response,
 	}
 	return true, resp, nil
 }
+
+// ScheduleResult is the outcome of ApplySchedule
+type ScheduleResult struct {
+	// Schedule is the active schedule of the code package
+	Schedule *Schedule
+	// Created is true when the schedule was created
+	Created bool
+	// Cancelled holds the IDs of the schedules that were replaced
+	Cancelled []string
+}
+
+// ApplySchedule makes the schedule of the builder the only active schedule of
+// its code package. An active schedule with the same timing, cluster, timeout
+// and payload is kept, other active schedules of the code package are cancelled
+// after the new one is created. Schedules with an encrypted payload are always
+// replaced
+func (s *SchedulesServices) ApplySchedule(builder *ScheduleBuilder) (*ScheduleResult, error) {
+	return s.applySchedule(builder, false)
+}
+
+// ReplaceSchedule creates the schedule of the builder and cancels the other
+// active schedules of its code package
+func (s *SchedulesServices) ReplaceSchedule(builder *ScheduleBuilder) (*ScheduleResult, error) {
+	return s.applySchedule(builder, true)
+}
+
+func (s *SchedulesServices) applySchedule(builder *ScheduleBuilder, replace bool) (*ScheduleResult, error) {
+	desired, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	existing, _, err := s.GetSchedulesWithCode(desired.CodeName)
+	if err != nil {
+		return nil, err
+	}
+	result := &ScheduleResult{}
+	var stale []Schedule
+	for _, schedule := range *existing {
+		if schedule.Status == ScheduleStatusCancelled {
+			continue
+		}
+		if !replace && result.Schedule == nil && builder.matches(schedule, *desired) {
+			kept := schedule
+			result.Schedule = &kept
+			continue
+		}
+		stale = append(stale, schedule)
+	}
+	if result.Schedule == nil {
+		created, _, err := s.CreateSchedule(*desired)
+		if err != nil {
+			return nil, err
+		}
+		if created == nil || created.ID == "" {
+			return nil, fmt.Errorf("%w: schedule for %s not created", ErrUnexpectedResponse, desired.CodeName)
+		}
+		result.Schedule = created
+		result.Created = true
+	}
+	for _, schedule := range stale {
+		if _, _, err := s.CancelSchedule(schedule.ID); err != nil {
+			return result, fmt.Errorf("cancelling schedule %s: %w", schedule.ID, err)
+		}
+		result.Cancelled = append(result.Cancelled, schedule.ID)
+	}
+	return result, nil
+}
@@ -1,9 +1,24 @@
 package iron
 
 import (
+	"bytes"
+	"fmt"
+	"net/http"
 	"time"
 )
 
+// Task statuses
+const (
+	StatusQueued    = "queued"
+	StatusPreparing = "preparing"
+	StatusRunning   = "running"
+	StatusComplete  = "complete"
+	StatusError     = "error"
+	StatusCancelled = "cancelled"
+	StatusKilled    = "killed"
+	StatusTimeout   = "timeout"
+)
+
 type TasksServices struct {
 	client    *Client
 	projectID string
@@ -31,6 +46,32 @@ type Task struct {
 	LogSize       int        `json:"log_size,omitempty"`
 }
 
+// Finished returns true when the task reached a final status
+func (t Task) Finished() bool {
+	switch t.Status {
+	case StatusComplete, StatusError, StatusCancelled, StatusKilled, StatusTimeout:
+		return true
+	}
+	return false
+}
+
+// Succeeded returns true when the task completed successfully
+func (t Task) Succeeded() bool {
+	return t.Status == StatusComplete
+}
+
+// RunningTime returns how long the task ran. Duration is used when Iron
+// reported it, else the time between start and end
+func (t Task) RunningTime() time.Duration {
+	if t.Duration > 0 {
+		return time.Duration(t.Duration) * time.Millisecond
+	}
+	if t.StartTime != nil && t.EndTime != nil && t.EndTime.After(*t.StartTime) {
+		return t.EndTime.Sub(*t.StartTime)
+	}
+	return 0
+}
+
 // GetTasks gets the tasks of the project
 func (t *TasksServices) GetTasks() (*[]Task, *Response, error) {
 	page := 0
@@ -122,3 +163,28 @@ func (t *TasksServices) CancelTask(taskID string) (bool, *Response, error) {
 	}
 	return true, resp, nil
 }
+
+// GetTaskLog gets the log of the given task. Iron makes the log available
+// once the task runs, before that ErrNotFound is returned
+func (t *TasksServices) GetTaskLog(taskID string) ([]byte, *Response, error) {
+	req, err := t.client.newRequest(
+		"GET",
+		t.client.Path("projects", t.projectID, "tasks", taskID, "log"),
+		nil,
+		nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var log bytes.Buffer
+	resp, err := t.client.do(req, &log)
+	if err != nil {
+		return nil, resp, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return log.Bytes(), resp, nil
+	case http.StatusNotFound:
+		return nil, resp, ErrNotFound
+	}
+	return nil, resp, fmt.Errorf("%w: %d", ErrUnexpectedResponse, resp.StatusCode)
+}
@@ -0,0 +1,116 @@
+package iron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+const defaultPollInterval = 5 * time.Second
+
+// StreamOptions control StreamTask
+type StreamOptions struct {
+	// Log receives the task log as it grows
+	Log io.Writer
+	// OnStatus is called with the task whenever its status changes
+	OnStatus func(Task)
+}
+
+// Wait polls the task with backoff until it reached a final status and returns it.
+// Check Task.Succeeded for the outcome. When ctx is done the last seen task is
+// returned with the context error
+func (t *TasksServices) Wait(ctx context.Context, taskID string) (*Task, error) {
+	return t.poll(ctx, taskID, nil)
+}
+
+// StreamTask waits for the task like Wait while tailing its log to options.Log
+// and reporting status changes to options.OnStatus. A task that does not
+// complete successfully returns ErrTaskFailed, so CI pipelines can fail on it
+func (t *TasksServices) StreamTask(ctx context.Context, taskID string, options StreamOptions) (*Task, error) {
+	written := 0
+	status := ""
+	tail := func(task *Task) error {
+		if task.Status != status {
+			status = task.Status
+			if options.OnStatus != nil {
+				options.OnStatus(*task)
+			}
+		}
+		if options.Log == nil || task.Status == StatusQueued || task.Status == StatusPreparing {
+			return nil
+		}
+		// Iron serves the whole log so write what was not written before
+		log, _, err := t.GetTaskLog(taskID)
+		if errors.Is(err, ErrNotFound) && !task.Finished() {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(log) > written {
+			if _, err := options.Log.Write(log[written:]); err != nil {
+				return backoff.Permanent(err)
+			}
+			written = len(log)
+		}
+		return nil
+	}
+	task, err := t.poll(ctx, taskID, tail)
+	if err != nil {
+		return task, err
+	}
+	if !task.Succeeded() {
+		return task, fmt.Errorf("%w: %s %s: %s", ErrTaskFailed, taskID, task.Status, task.Msg)
+	}
+	return task, nil
+}
+
+// poll gets the task until it finished, calling onPoll with every version
+func (t *TasksServices) poll(ctx context.Context, taskID string, onPoll func(*Task) error) (*Task, error) {
+	interval := t.client.config.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	policy := backoff.NewExponentialBackOff()
+	policy.InitialInterval = interval
+	policy.MaxInterval = 4 * interval
+	policy.MaxElapsedTime = 0
+
+	var last *Task
+	operation := func() error {
+		task, resp, err := t.GetTask(taskID)
+		// Iron error bodies decode into a Task without error, so check the status
+		if resp != nil {
+			switch code := resp.StatusCode; {
+			case code == http.StatusNotFound:
+				return backoff.Permanent(fmt.Errorf("task %s: %w", taskID, ErrNotFound))
+			case code >= http.StatusBadRequest && code < http.StatusInternalServerError && code != http.StatusTooManyRequests:
+				return backoff.Permanent(fmt.Errorf("task %s: %w: status %d", taskID, ErrUnexpectedResponse, code))
+			case code >= http.StatusMultipleChoices:
+				return fmt.Errorf("task %s: %w: status %d", taskID, ErrUnexpectedResponse, code)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		last = task
+		if onPoll != nil {
+			if err := onPoll(task); err != nil {
+				return err
+			}
+		}
+		if !task.Finished() {
+			return fmt.Errorf("task %s is %s", taskID, task.Status)
+		}
+		return nil
+	}
+	if err := backoff.Retry(operation, backoff.WithContext(policy, ctx)); err != nil {
+		return last, err
+	}
+	return last, nil
+}
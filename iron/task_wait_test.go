@@ -0,0 +1,140 @@
+package iron_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philips-software/go-hsdp-api/iron"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTask serves a task advancing one status per request, with a log growing while it runs
+func fakeTask(t *testing.T, taskID string, statuses []string, logs []string) *iron.Client {
+	teardown := setup(t)
+	t.Cleanup(teardown)
+	polls := 0
+	muxIRON.HandleFunc(client.Path("projects", projectID, "tasks", taskID), func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[len(statuses)-1]
+		if polls < len(statuses) {
+			status = statuses[polls]
+		}
+		polls++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"id": "%s", "status": "%s", "msg": "exit code 1", "duration": 1500}`, taskID, status)
+	})
+	muxIRON.HandleFunc(client.Path("projects", projectID, "tasks", taskID, "log"), func(w http.ResponseWriter, r *http.Request) {
+		i := polls - 1
+		if i >= len(logs) {
+			i = len(logs) - 1
+		}
+		if i < 0 || logs[i] == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, logs[i])
+	})
+	c, err := iron.NewClient(&iron.Config{
+		BaseURL:      serverIRON.URL,
+		ProjectID:    projectID,
+		Token:        token,
+		PollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestTasksServices_GetTaskLog(t *testing.T) {
+	c := fakeTask(t, "task1", []string{"running"}, []string{"hello\n"})
+	_, _, _ = c.Tasks.GetTask("task1")
+
+	log, resp, err := c.Tasks.GetTaskLog("task1")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello\n", string(log))
+
+	_, _, err = c.Tasks.GetTaskLog("bogus")
+	assert.Equal(t, iron.ErrNotFound, err)
+}
+
+func TestTasksServices_Wait(t *testing.T) {
+	c := fakeTask(t, "task1", []string{"queued", "running", "complete"}, []string{""})
+
+	task, err := c.Tasks.Wait(context.Background(), "task1")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, task.Finished())
+	assert.True(t, task.Succeeded())
+	assert.Equal(t, 1500*time.Millisecond, task.RunningTime())
+
+	c = fakeTask(t, "task2", []string{"running"}, []string{""})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	task, err = c.Tasks.Wait(ctx, "task2")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	if assert.NotNil(t, task) {
+		assert.Equal(t, iron.StatusRunning, task.Status)
+	}
+}
+
+func TestTasksServices_WaitErrors(t *testing.T) {
+	c := fakeTask(t, "task1", []string{"running"}, []string{""})
+	jsonError := func(status int, msg string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_, _ = fmt.Fprintf(w, `{"msg": "%s"}`, msg)
+		}
+	}
+	muxIRON.HandleFunc(client.Path("projects", projectID, "tasks", "missing"), jsonError(http.StatusNotFound, "Task not found"))
+	muxIRON.HandleFunc(client.Path("projects", projectID, "tasks", "forbidden"), jsonError(http.StatusUnauthorized, "Invalid token"))
+
+	// Without a deadline the errors must end the wait
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := c.Tasks.Wait(ctx, "missing")
+	assert.True(t, errors.Is(err, iron.ErrNotFound), "%v", err)
+	_, err = c.Tasks.StreamTask(ctx, "forbidden", iron.StreamOptions{})
+	assert.True(t, errors.Is(err, iron.ErrUnexpectedResponse), "%v", err)
+	assert.Nil(t, ctx.Err())
+}
+
+func TestTasksServices_StreamTask(t *testing.T) {
+	c := fakeTask(t, "task1",
+		[]string{"queued", "running", "running", "error"},
+		[]string{"", "", "line 1\n", "line 1\nline 2\n"})
+
+	var log bytes.Buffer
+	var statuses []string
+	task, err := c.Tasks.StreamTask(context.Background(), "task1", iron.StreamOptions{
+		Log: &log,
+		OnStatus: func(task iron.Task) {
+			statuses = append(statuses, task.Status)
+		},
+	})
+	assert.True(t, errors.Is(err, iron.ErrTaskFailed))
+	assert.True(t, strings.Contains(err.Error(), "exit code 1"))
+	if assert.NotNil(t, task) {
+		assert.Equal(t, iron.StatusError, task.Status)
+	}
+	assert.Equal(t, "line 1\nline 2\n", log.String())
+	assert.Equal(t, []string{"queued", "running", "error"}, statuses)
+
+	c = fakeTask(t, "task2", []string{"running", "complete"}, []string{"done\n"})
+	task, err = c.Tasks.StreamTask(context.Background(), "task2", iron.StreamOptions{Log: &log})
+	assert.Nil(t, err)
+	assert.True(t, task.Succeeded())
+}
@@ -86,9 +86,16 @@ type Config struct {
 	SharedSecret string
 	IAMClient    *iam.Client
 	BaseURL      string
-	ProductKey   string
-	Debug        bool
-	DebugLog     string
+	// QueryURL is the base URL of the log query service, used by Query and the custom
+	// index calls. It defaults to the logquery service of Region and Environment
+	QueryURL   string
+	ProductKey string
+	Debug      bool
+	DebugLog   string
+	// Redactor, when set, removes sensitive data from every resource before it is sent
+	Redactor Redactor
+	// Validator, when set, checks and depending on its mode repairs every resource before it is sent
+	Validator *Validator
 }
 
 // Valid returns if all required config fields are present, false otherwise
@@ -123,6 +130,10 @@ type StoreResponse struct {
 	*http.Response
 	Message string
 	Failed  map[int]Resource
+	// Changes lists the modifications made by the configured Validator, by resource index
+	Changes map[int][]ValidationChange
+	// Redactions lists what the configured Redactor removed, by resource index
+	Redactions map[int][]Redaction
 }
 
 // CustomIndexBody describes the custom index request payload
@@ -163,6 +174,10 @@ func NewClient(httpClient *http.Client, config *Config) (*Client, error) {
 			if loggingService.URL != "" && config.BaseURL == "" {
 				config.BaseURL = loggingService.URL
 			}
+			queryService := c.Service("logquery")
+			if queryService.URL != "" && config.QueryURL == "" {
+				config.QueryURL = queryService.URL
+			}
 		}
 	}
 	if valid, err := config.Valid(); !valid {
@@ -242,11 +257,32 @@ func (c *Client) StoreResources(msgs []Resource, count int) (*StoreResponse, err
 		ProductKey:   c.config.ProductKey,
 	}
 	invalid := make(map[int]Resource)
+	changes := make(map[int][]ValidationChange)
+	redactions := make(map[int][]Redaction)
 
 	j := 0
 	for i := 0; i < count; i++ {
 		msg := msgs[i]
-		replaceScaryCharacters(&msg)
+		if c.config.Redactor != nil {
+			if r := c.config.Redactor.Redact(&msg); len(r) > 0 {
+				redactions[i] = r
+			}
+		}
+		if c.config.Validator != nil {
+			report := c.config.Validator.Validate(&msg)
+			if len(report.Changes) > 0 {
+				changes[i] = report.Changes
+			}
+			if err := report.Error(); err != nil {
+				msg.Error = err
+				invalid[i] = msg
+				continue
+			}
+		}
+		if c.config.Validator == nil {
+			// The Validator escapes these itself and records it
+			replaceScaryCharacters(&msg)
+		}
 		if !msg.Valid() {
 			invalid[i] = msg
 			continue
@@ -265,6 +301,7 @@ func (c *Client) StoreResources(msgs []Resource, count int) (*StoreResponse, err
 				StatusCode: http.StatusBadRequest,
 			},
 		}
+		resp.setReports(changes, redactions)
 		return &resp, ErrBatchErrors
 	}
 	b.Total = j
@@ -287,20 +324,38 @@ func (c *Client) StoreResources(msgs []Resource, count int) (*StoreResponse, err
 	req.Body = ioutil.NopCloser(bodyReader)
 	req.ContentLength = int64(bodyReader.Len())
 	req.Header.Set("Content-Type", "application/json")
+	if err := c.authorize(req); err != nil {
+		return nil, err
+	}
+	resp, err := c.performAndParseResponse(req, msgs)
+	if resp != nil {
+		resp.setReports(changes, redactions)
+	}
+	return resp, err
+}
+
+func (r *StoreResponse) setReports(changes map[int][]ValidationChange, redactions map[int][]Redaction) {
+	if len(changes) > 0 {
+		r.Changes = changes
+	}
+	if len(redactions) > 0 {
+		r.Redactions = redactions
+	}
+}
+
+// authorize sets the common headers and signs the request or adds the IAM bearer token
+func (c *Client) authorize(req *http.Request) error {
 	req.Header.Set("Api-Version", "1")
 	req.Header.Set("User-Agent", userAgent)
 	if c.httpSigner != nil {
-		if err := c.httpSigner.SignRequest(req); err != nil {
-			return nil, err
-		}
-	} else {
-		token, err := c.Token()
-		if err != nil {
-			req.Header.Set("X-Token-Error", fmt.Sprintf("%v", err))
-		}
-		req.Header.Set("Authorization", "Bearer "+token)
+		return c.httpSigner.SignRequest(req)
 	}
-	return c.performAndParseResponse(req, msgs)
+	token, err := c.Token()
+	if err != nil {
+		req.Header.Set("X-Token-Error", fmt.Sprintf("%v", err))
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
 }
 
 func (c *Client) performAndParseResponse(req *http.Request, msgs []Resource) (*StoreResponse, error) {
@@ -351,10 +406,14 @@ func replaceScaryCharacters(msg *Resource) {
 	if len(msg.Custom) == 0 {
 		return
 	}
-	stringCustom := strings.Replace(string(msg.Custom), "\\\\", "[bsl]", -1)
+	msg.Custom = []byte(escapeCustom(string(msg.Custom)))
+}
 
+// escapeCustom replaces the character sequences HSDP refuses in the custom payload
+func escapeCustom(custom string) string {
+	custom = strings.Replace(custom, "\\\\", "[bsl]", -1)
 	for s, r := range scaryMap {
-		stringCustom = strings.Replace(stringCustom, s, r, -1)
+		custom = strings.Replace(custom, s, r, -1)
 	}
-	msg.Custom = []byte(stringCustom)
+	return custom
 }
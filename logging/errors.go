@@ -8,7 +8,17 @@ var (
 	ErrMissingSharedKey              = errors.New("missing shared key")
 	ErrMissingSharedSecret           = errors.New("missing shared secret")
 	ErrMissingBaseURL                = errors.New("missing base URL")
+	ErrMissingQueryURL               = errors.New("missing query URL")
+	ErrForeignNextPage               = errors.New("next page is not on the query host")
 	ErrMissingProductKey             = errors.New("missing ProductKey")
 	ErrBatchErrors                   = errors.New("batch errors. check Invalid map for details")
 	ErrResponseError                 = errors.New("unexpected HSDP response error")
+	ErrMissingStorer                 = errors.New("missing storer")
+	ErrInvalidEventConfig            = errors.New("invalid event config")
+	ErrShipperClosed                 = errors.New("shipper is closed")
+	ErrQueueFull                     = errors.New("queue is full")
+	ErrMissingSpoolDir               = errors.New("missing spool directory")
+	ErrSpoolFull                     = errors.New("spool is full")
+	ErrSpoolClosed                   = errors.New("spool is closed")
+	ErrSpoolCorrupt                  = errors.New("spool record is corrupt")
 )
@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Severity values used by the log adapters
+const (
+	SeverityDebug   = "DEBUG"
+	SeverityInfo    = "INFO"
+	SeverityWarning = "WARNING"
+	SeverityError   = "ERROR"
+	SeverityFatal   = "FATAL"
+)
+
+const (
+	defaultEventCategory = "ApplicationLog"
+	defaultEventID       = "1"
+)
+
+type traceContextKey struct{}
+
+type traceContext struct {
+	traceID string
+	spanID  string
+}
+
+// ContextWithTrace returns a context carrying the given trace and span IDs
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, traceContext{traceID: traceID, spanID: spanID})
+}
+
+// TraceFromContext returns the trace and span IDs stored by ContextWithTrace
+func TraceFromContext(ctx context.Context) (traceID, spanID string) {
+	if ctx == nil {
+		return "", ""
+	}
+	if tc, ok := ctx.Value(traceContextKey{}).(traceContext); ok {
+		return tc.traceID, tc.spanID
+	}
+	return "", ""
+}
+
+// EventConfig holds the fields which are the same for every LogEvent
+// created by a log adapter. Empty Category, EventID and ServerName fields are
+// defaulted, the other fields are required by HSDP, see Validate
+type EventConfig struct {
+	ApplicationName     string
+	ApplicationInstance string
+	ApplicationVersion  string
+	ServiceName         string
+	Component           string
+	ServerName          string
+	Category            string
+	EventID             string
+	OriginatingUser     string
+	// TraceContext extracts trace and span IDs from a context. Defaults to TraceFromContext
+	TraceContext func(ctx context.Context) (traceID, spanID string)
+}
+
+// Validate checks that the fields HSDP requires and which have no default are
+// set, so every resource created from the config is accepted
+func (c EventConfig) Validate() error {
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"ApplicationName", c.ApplicationName},
+		{"ApplicationInstance", c.ApplicationInstance},
+		{"ApplicationVersion", c.ApplicationVersion},
+		{"ServiceName", c.ServiceName},
+		{"Component", c.Component},
+		{"OriginatingUser", c.OriginatingUser},
+	} {
+		if field.value == "" {
+			return fmt.Errorf("%w: %s", ErrInvalidEventConfig, field.name)
+		}
+	}
+	if bad := invalidCharacters(c.ApplicationVersion, true); bad != "" {
+		return fmt.Errorf("%w: ApplicationVersion contains %q", ErrInvalidEventConfig, bad)
+	}
+	return nil
+}
+
+// NewResource creates a LogEvent resource. Custom values which are errors are
+// stored as their message. The trace ID doubles as transaction ID when present
+func (c EventConfig) NewResource(ctx context.Context, t time.Time, severity, message string, custom map[string]interface{}) Resource {
+	resource := Resource{
+		ResourceType:        "LogEvent",
+		ID:                  uuid.New().String(),
+		ApplicationName:     c.ApplicationName,
+		ApplicationInstance: c.ApplicationInstance,
+		ApplicationVersion:  c.ApplicationVersion,
+		ServiceName:         c.ServiceName,
+		Component:           c.Component,
+		ServerName:          c.ServerName,
+		Category:            c.Category,
+		EventID:             c.EventID,
+		OriginatingUser:     c.OriginatingUser,
+		LogTime:             t.Format(TimeFormat),
+		Severity:            severity,
+		LogData:             LogData{Message: message},
+	}
+	if resource.Category == "" {
+		resource.Category = defaultEventCategory
+	}
+	if resource.EventID == "" {
+		resource.EventID = defaultEventID
+	}
+	if resource.ServerName == "" {
+		resource.ServerName, _ = os.Hostname()
+	}
+	traceContext := c.TraceContext
+	if traceContext == nil {
+		traceContext = TraceFromContext
+	}
+	if ctx != nil {
+		resource.TraceID, resource.SpanID = traceContext(ctx)
+	}
+	resource.TransactionID = resource.TraceID
+	if resource.TransactionID == "" {
+		resource.TransactionID = uuid.New().String()
+	}
+	if len(custom) > 0 {
+		resource.Custom = marshalCustom(custom)
+	}
+	return resource
+}
+
+func marshalCustom(custom map[string]interface{}) json.RawMessage {
+	data, _ := json.Marshal(normalizeCustom(custom))
+	return data
+}
+
+// normalizeCustom replaces errors by their message and values which cannot be encoded by their string form
+func normalizeCustom(custom map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{}, len(custom))
+	for k, v := range custom {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			fields[k] = normalizeCustom(value)
+			continue
+		case error:
+			v = value.Error()
+		}
+		if _, err := json.Marshal(v); err != nil {
+			v = fmt.Sprint(v)
+		}
+		fields[k] = v
+	}
+	return fields
+}
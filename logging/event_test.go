@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventConfigNewResource(t *testing.T) {
+	config := EventConfig{
+		ApplicationName:     "app",
+		ApplicationInstance: "instance",
+		ApplicationVersion:  "1.0.0",
+		ServiceName:         "service",
+		Component:           "component",
+		ServerName:          "server",
+		OriginatingUser:     "user",
+	}
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	ctx := ContextWithTrace(context.Background(), "trace", "span")
+
+	resource := config.NewResource(ctx, now, SeverityInfo, "hello", map[string]interface{}{
+		"count": 1,
+		"err":   errors.New("boom"),
+		"fn":    func() {},
+		"nested": map[string]interface{}{
+			"err": errors.New("inner"),
+		},
+	})
+	assert.True(t, resource.Valid())
+	assert.Equal(t, "LogEvent", resource.ResourceType)
+	assert.Equal(t, "2022-10-01T12:00:00.000Z", resource.LogTime)
+	assert.Equal(t, "trace", resource.TraceID)
+	assert.Equal(t, "span", resource.SpanID)
+	assert.Equal(t, "trace", resource.TransactionID)
+	assert.Equal(t, defaultEventCategory, resource.Category)
+	assert.Equal(t, defaultEventID, resource.EventID)
+	assert.Equal(t, "service", resource.ServiceName)
+
+	var custom map[string]interface{}
+	assert.Nil(t, json.Unmarshal(resource.Custom, &custom))
+	assert.Equal(t, "boom", custom["err"])
+	assert.Equal(t, float64(1), custom["count"])
+	assert.IsType(t, "", custom["fn"])
+	assert.Equal(t, map[string]interface{}{"err": "inner"}, custom["nested"])
+
+	resource = config.NewResource(context.Background(), now, SeverityInfo, "no trace", nil)
+	assert.True(t, resource.Valid())
+	assert.Empty(t, resource.TraceID)
+	assert.NotEmpty(t, resource.TransactionID)
+	assert.Nil(t, resource.Custom)
+
+	config.TraceContext = func(ctx context.Context) (string, string) {
+		return "custom-trace", "custom-span"
+	}
+	resource = config.NewResource(context.Background(), now, SeverityInfo, "custom", nil)
+	assert.Equal(t, "custom-trace", resource.TraceID)
+	assert.Equal(t, "custom-span", resource.SpanID)
+}
+
+func TestEventConfigValidate(t *testing.T) {
+	config := EventConfig{
+		ApplicationName:     "app",
+		ApplicationInstance: "instance",
+		ApplicationVersion:  "1.0.0",
+		ServiceName:         "service",
+		Component:           "component",
+		OriginatingUser:     "user",
+	}
+	assert.Nil(t, config.Validate())
+
+	missing := config
+	missing.OriginatingUser = ""
+	assert.True(t, errors.Is(missing.Validate(), ErrInvalidEventConfig))
+	restricted := config
+	restricted.ApplicationVersion = "1.0+build"
+	assert.True(t, errors.Is(restricted.Validate(), ErrInvalidEventConfig))
+	assert.True(t, errors.Is(EventConfig{}.Validate(), ErrInvalidEventConfig))
+}
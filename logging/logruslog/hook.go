@@ -0,0 +1,70 @@
+// Package logruslog provides a logrus hook which stores log entries as HSDP LogEvents
+package logruslog
+
+import (
+	"github.com/philips-software/go-hsdp-api/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// Hook is a logrus.Hook which stores entries as LogEvents
+type Hook struct {
+	storer logging.Storer
+	config logging.EventConfig
+	levels []logrus.Level
+}
+
+var _ logrus.Hook = &Hook{}
+
+// NewHook returns a hook storing entries of the given levels through storer.
+// Use a logging.Shipper as storer to batch the events. All levels are stored when none are given.
+// The config must pass logging.EventConfig.Validate
+func NewHook(storer logging.Storer, config logging.EventConfig, levels ...logrus.Level) (*Hook, error) {
+	if storer == nil {
+		return nil, logging.ErrMissingStorer
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if len(levels) == 0 {
+		levels = logrus.AllLevels
+	}
+	return &Hook{
+		storer: storer,
+		config: config,
+		levels: levels,
+	}, nil
+}
+
+// Severity maps a logrus level to a LogEvent severity
+func Severity(level logrus.Level) string {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return logging.SeverityFatal
+	case logrus.ErrorLevel:
+		return logging.SeverityError
+	case logrus.WarnLevel:
+		return logging.SeverityWarning
+	case logrus.InfoLevel:
+		return logging.SeverityInfo
+	}
+	return logging.SeverityDebug
+}
+
+// Levels returns the levels the hook fires for
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire stores the entry. Trace and span IDs are taken from the entry context
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	custom := make(map[string]interface{}, len(entry.Data)+1)
+	for k, v := range entry.Data {
+		custom[k] = v
+	}
+	if entry.HasCaller() {
+		custom["caller"] = entry.Caller.Function
+	}
+	resource := h.config.NewResource(entry.Context, entry.Time, Severity(entry.Level), entry.Message, custom)
+	_, err := h.storer.StoreResources([]logging.Resource{resource}, 1)
+	return err
+}
@@ -0,0 +1,71 @@
+package logruslog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/philips-software/go-hsdp-api/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type captureStorer struct {
+	resources []logging.Resource
+}
+
+func (c *captureStorer) StoreResources(msgs []logging.Resource, count int) (*logging.StoreResponse, error) {
+	c.resources = append(c.resources, msgs[:count]...)
+	return &logging.StoreResponse{}, nil
+}
+
+func TestHook(t *testing.T) {
+	storer := &captureStorer{}
+	hook, err := NewHook(storer, logging.EventConfig{
+		ApplicationName:     "app",
+		ApplicationInstance: "instance",
+		ApplicationVersion:  "1.0.0",
+		ServiceName:         "service",
+		Component:           "component",
+		OriginatingUser:     "user",
+	}, logrus.WarnLevel, logrus.ErrorLevel)
+	if !assert.Nil(t, err) {
+		return
+	}
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(hook)
+
+	ctx := logging.ContextWithTrace(context.Background(), "trace", "span")
+	logger.WithContext(ctx).Info("ignored")
+	logger.WithContext(ctx).WithField("user", "foo").Warn("almost full")
+
+	if !assert.Len(t, storer.resources, 1) {
+		return
+	}
+	resource := storer.resources[0]
+	assert.True(t, resource.Valid())
+	report := logging.NewValidator(logging.ValidationReject).Validate(&resource)
+	assert.True(t, report.Valid(), "%v", report.Violations)
+	assert.Equal(t, logging.SeverityWarning, resource.Severity)
+	assert.Equal(t, "almost full", resource.LogData.Message)
+	assert.Equal(t, "app", resource.ApplicationName)
+	assert.Equal(t, "trace", resource.TraceID)
+
+	var custom map[string]interface{}
+	assert.Nil(t, json.Unmarshal(resource.Custom, &custom))
+	assert.Equal(t, "foo", custom["user"])
+
+	_, err = NewHook(nil, logging.EventConfig{})
+	assert.Equal(t, logging.ErrMissingStorer, err)
+	_, err = NewHook(storer, logging.EventConfig{ApplicationName: "app"})
+	assert.True(t, errors.Is(err, logging.ErrInvalidEventConfig))
+}
+
+func TestSeverity(t *testing.T) {
+	assert.Equal(t, logging.SeverityDebug, Severity(logrus.TraceLevel))
+	assert.Equal(t, logging.SeverityInfo, Severity(logrus.InfoLevel))
+	assert.Equal(t, logging.SeverityFatal, Severity(logrus.PanicLevel))
+}
@@ -0,0 +1,217 @@
+// Package otel converts OpenTelemetry log records into HSDP LogEvents
+package otel
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/philips-software/go-hsdp-api/logging"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+const (
+	defaultBatchSize = 100
+	emptyMessage     = "<empty>"
+)
+
+// Resource attributes mapped onto LogEvent fields
+const (
+	AttributeServiceName       = "service.name"
+	AttributeServiceNamespace  = "service.namespace"
+	AttributeServiceInstanceID = "service.instance.id"
+	AttributeServiceVersion    = "service.version"
+	AttributeHostName          = "host.name"
+)
+
+// Exporter stores OTLP log records as LogEvents. Pass a *logging.Client as
+// storer to send directly to HSDP using HMAC or IAM authentication, or a
+// logging.Shipper to batch in the background
+type Exporter struct {
+	storer   logging.Storer
+	defaults logging.EventConfig
+
+	// BatchSize is the maximum number of LogEvents per StoreResources call
+	BatchSize int
+}
+
+// NewExporter returns an exporter. Fields in defaults are used when the
+// OTLP resource does not provide a value. The defaults must pass
+// logging.EventConfig.Validate so records without resource attributes are accepted
+func NewExporter(storer logging.Storer, defaults logging.EventConfig) (*Exporter, error) {
+	if storer == nil {
+		return nil, logging.ErrMissingStorer
+	}
+	if err := defaults.Validate(); err != nil {
+		return nil, err
+	}
+	return &Exporter{
+		storer:    storer,
+		defaults:  defaults,
+		BatchSize: defaultBatchSize,
+	}, nil
+}
+
+// Export converts and stores the log records
+func (e *Exporter) Export(ctx context.Context, logs []*logspb.ResourceLogs) error {
+	resources := e.Convert(logs)
+	batchSize := e.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	for start := 0; start < len(resources); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := start + batchSize
+		if end > len(resources) {
+			end = len(resources)
+		}
+		if _, err := e.storer.StoreResources(resources[start:end], end-start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Convert maps OTLP log records to LogEvent resources
+func (e *Exporter) Convert(logs []*logspb.ResourceLogs) []logging.Resource {
+	var resources []logging.Resource
+	for _, rl := range logs {
+		config := e.defaults
+		config.TraceContext = nil
+		for _, attr := range rl.GetResource().GetAttributes() {
+			value := attr.GetValue().GetStringValue()
+			if value == "" {
+				continue
+			}
+			switch attr.GetKey() {
+			case AttributeServiceName:
+				config.ServiceName = value
+			case AttributeServiceNamespace:
+				config.ApplicationName = value
+			case AttributeServiceInstanceID:
+				config.ApplicationInstance = value
+			case AttributeServiceVersion:
+				config.ApplicationVersion = value
+			case AttributeHostName:
+				config.ServerName = value
+			}
+		}
+		for _, sl := range rl.GetScopeLogs() {
+			scopeConfig := config
+			if name := sl.GetScope().GetName(); name != "" {
+				scopeConfig.Component = name
+			}
+			for _, record := range sl.GetLogRecords() {
+				resources = append(resources, convertRecord(scopeConfig, record))
+			}
+		}
+	}
+	return resources
+}
+
+func convertRecord(config logging.EventConfig, record *logspb.LogRecord) logging.Resource {
+	ts := record.GetTimeUnixNano()
+	if ts == 0 {
+		ts = record.GetObservedTimeUnixNano()
+	}
+	t := time.Now()
+	if ts != 0 {
+		t = time.Unix(0, int64(ts))
+	}
+	t = t.UTC()
+
+	ctx := context.Background()
+	if traceID := record.GetTraceId(); len(traceID) > 0 {
+		ctx = logging.ContextWithTrace(ctx, hex.EncodeToString(traceID), hex.EncodeToString(record.GetSpanId()))
+	}
+	message := anyValueString(record.GetBody())
+	if message == "" {
+		message = emptyMessage
+	}
+	var custom map[string]interface{}
+	if attrs := record.GetAttributes(); len(attrs) > 0 {
+		custom = make(map[string]interface{}, len(attrs))
+		for _, attr := range attrs {
+			custom[attr.GetKey()] = anyValue(attr.GetValue())
+		}
+	}
+	return config.NewResource(ctx, t, Severity(record.GetSeverityNumber(), record.GetSeverityText()), message, custom)
+}
+
+// Severity maps an OTLP severity to a LogEvent severity. The severity text is
+// used when no severity number is set
+func Severity(number logspb.SeverityNumber, text string) string {
+	switch {
+	case number == logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED:
+	case number < logspb.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return logging.SeverityDebug
+	case number < logspb.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return logging.SeverityInfo
+	case number < logspb.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return logging.SeverityWarning
+	case number < logspb.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return logging.SeverityError
+	default:
+		return logging.SeverityFatal
+	}
+	switch strings.ToUpper(text) {
+	case "TRACE", "DEBUG":
+		return logging.SeverityDebug
+	case "WARN", "WARNING":
+		return logging.SeverityWarning
+	case "ERROR":
+		return logging.SeverityError
+	case "FATAL", "CRITICAL":
+		return logging.SeverityFatal
+	}
+	return logging.SeverityInfo
+}
+
+func anyValueString(value *commonpb.AnyValue) string {
+	switch v := anyValue(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+func anyValue(value *commonpb.AnyValue) interface{} {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return v.BytesValue
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]interface{}, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, anyValue(item))
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		values := make(map[string]interface{}, len(v.KvlistValue.GetValues()))
+		for _, kv := range v.KvlistValue.GetValues() {
+			values[kv.GetKey()] = anyValue(kv.GetValue())
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/philips-software/go-hsdp-api/logging"
+	"github.com/stretchr/testify/assert"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+type captureStorer struct {
+	batches [][]logging.Resource
+	err     error
+}
+
+func (c *captureStorer) StoreResources(msgs []logging.Resource, count int) (*logging.StoreResponse, error) {
+	c.batches = append(c.batches, append([]logging.Resource(nil), msgs[:count]...))
+	return &logging.StoreResponse{}, c.err
+}
+
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func testLogs() []*logspb.ResourceLogs {
+	return []*logspb.ResourceLogs{
+		{
+			Resource: &resourcepb.Resource{
+				Attributes: []*commonpb.KeyValue{
+					stringAttr(AttributeServiceName, "checkout"),
+					stringAttr(AttributeServiceInstanceID, "instance-1"),
+					stringAttr(AttributeHostName, "host.example.com"),
+				},
+			},
+			ScopeLogs: []*logspb.ScopeLogs{
+				{
+					Scope: &commonpb.InstrumentationScope{Name: "checkout/api"},
+					LogRecords: []*logspb.LogRecord{
+						{
+							TimeUnixNano:   uint64(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC).UnixNano()),
+							SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_WARN,
+							Body:           &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "payment slow"}},
+							Attributes: []*commonpb.KeyValue{
+								stringAttr("order", "42"),
+								{Key: "attempt", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 3}}},
+							},
+							TraceId: []byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c},
+							SpanId:  []byte{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x74},
+						},
+						{
+							SeverityText: "error",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func testDefaults() logging.EventConfig {
+	return logging.EventConfig{
+		ApplicationName:     "shop",
+		ApplicationInstance: "default-instance",
+		ApplicationVersion:  "1.0.0",
+		ServiceName:         "default-service",
+		Component:           "default-component",
+		OriginatingUser:     "otel",
+	}
+}
+
+func TestExporter(t *testing.T) {
+	storer := &captureStorer{}
+	exporter, err := NewExporter(storer, testDefaults())
+	if !assert.Nil(t, err) {
+		return
+	}
+	exporter.BatchSize = 1
+	assert.Nil(t, exporter.Export(context.Background(), testLogs()))
+	if !assert.Len(t, storer.batches, 2) {
+		return
+	}
+	resource := storer.batches[0][0]
+	assert.True(t, resource.Valid())
+	report := logging.NewValidator(logging.ValidationReject).Validate(&resource)
+	assert.True(t, report.Valid(), "%v", report.Violations)
+	assert.Equal(t, "checkout", resource.ServiceName)
+	assert.Equal(t, "shop", resource.ApplicationName)
+	assert.Equal(t, "instance-1", resource.ApplicationInstance)
+	assert.Equal(t, "host.example.com", resource.ServerName)
+	assert.Equal(t, "checkout/api", resource.Component)
+	assert.Equal(t, logging.SeverityWarning, resource.Severity)
+	assert.Equal(t, "payment slow", resource.LogData.Message)
+	assert.Equal(t, "2022-10-01T12:00:00.000Z", resource.LogTime)
+	assert.Equal(t, "5b8efff798038103d269b633813fc60c", resource.TraceID)
+	assert.Equal(t, "eee19b7ec3c1b174", resource.SpanID)
+
+	var custom map[string]interface{}
+	assert.Nil(t, json.Unmarshal(resource.Custom, &custom))
+	assert.Equal(t, "42", custom["order"])
+	assert.Equal(t, float64(3), custom["attempt"])
+
+	resource = storer.batches[1][0]
+	assert.True(t, resource.Valid())
+	assert.Equal(t, logging.SeverityError, resource.Severity)
+	assert.Equal(t, emptyMessage, resource.LogData.Message)
+
+	_, err = NewExporter(nil, logging.EventConfig{})
+	assert.Equal(t, logging.ErrMissingStorer, err)
+	_, err = NewExporter(storer, logging.EventConfig{ApplicationName: "shop"})
+	assert.True(t, errors.Is(err, logging.ErrInvalidEventConfig))
+}
+
+func TestReceiver(t *testing.T) {
+	storer := &captureStorer{}
+	exporter, _ := NewExporter(storer, testDefaults())
+	receiver, err := NewReceiver(exporter)
+	if !assert.Nil(t, err) {
+		return
+	}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	body, _ := proto.Marshal(&logspb.LogsData{ResourceLogs: testLogs()})
+	resp, err := http.Post(server.URL+ReceiverPath, contentTypeProtobuf, bytes.NewReader(body))
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+	assert.Len(t, storer.batches, 1)
+
+	jsonBody := `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"node-app"}}]},
+		"scopeLogs":[{"logRecords":[{"timeUnixNano":"1664625600000000000","severityNumber":9,
+		"body":{"stringValue":"hello"},"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174"}]}]}]}`
+	resp, err = http.Post(server.URL+ReceiverPath, "application/json", bytes.NewBufferString(jsonBody))
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+	if assert.Len(t, storer.batches, 2) {
+		resource := storer.batches[1][0]
+		assert.Equal(t, "node-app", resource.ServiceName)
+		assert.Equal(t, logging.SeverityInfo, resource.Severity)
+		assert.Equal(t, "5b8efff798038103d269b633813fc60c", resource.TraceID)
+	}
+
+	resp, err = http.Post(server.URL+ReceiverPath, "text/plain", bytes.NewBufferString("hello"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+
+	storer.err = logging.ErrResponseError
+	resp, err = http.Post(server.URL+ReceiverPath, contentTypeProtobuf, bytes.NewReader(body))
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+}
@@ -0,0 +1,140 @@
+package otel
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/philips-software/go-hsdp-api/logging"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// ReceiverPath is the OTLP/HTTP path for logs
+	ReceiverPath = "/v1/logs"
+
+	contentTypeProtobuf   = "application/x-protobuf"
+	contentTypeJSON       = "application/json"
+	defaultMaxRequestSize = 4 * 1024 * 1024
+)
+
+// Receiver is an OTLP/HTTP logs endpoint which forwards received records
+// through an Exporter. Both binary protobuf and JSON encoded requests are accepted
+type Receiver struct {
+	exporter *Exporter
+
+	// MaxRequestSize is the maximum size of a decompressed request body
+	MaxRequestSize int64
+}
+
+var _ http.Handler = &Receiver{}
+
+// NewReceiver returns a receiver which exports through exporter. Mount it on ReceiverPath
+func NewReceiver(exporter *Exporter) (*Receiver, error) {
+	if exporter == nil {
+		return nil, logging.ErrMissingStorer
+	}
+	return &Receiver{
+		exporter:       exporter,
+		MaxRequestSize: defaultMaxRequestSize,
+	}, nil
+}
+
+// ServeHTTP handles an OTLP ExportLogsServiceRequest
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if contentType != contentTypeProtobuf && contentType != contentTypeJSON {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	var body io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, r.MaxRequestSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > r.MaxRequestSize {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	// ExportLogsServiceRequest is wire compatible with LogsData
+	var logs logspb.LogsData
+	if contentType == contentTypeJSON {
+		err = unmarshalJSON(data, &logs)
+	} else {
+		err = proto.Unmarshal(data, &logs)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := r.exporter.Export(req.Context(), logs.GetResourceLogs()); err != nil {
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, logging.ErrBatchErrors) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	// An empty ExportLogsServiceResponse
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if contentType == contentTypeJSON {
+		_, _ = w.Write([]byte("{}"))
+	}
+}
+
+// unmarshalJSON decodes OTLP JSON, which encodes trace and span IDs as hex instead of base64
+func unmarshalJSON(data []byte, logs *logspb.LogsData) error {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	fixed, err := json.Marshal(hexToBase64(doc))
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(fixed, logs)
+}
+
+func hexToBase64(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if s, ok := val.(string); ok && (key == "traceId" || key == "spanId") {
+				if raw, err := hex.DecodeString(s); err == nil {
+					v[key] = base64.StdEncoding.EncodeToString(raw)
+				}
+				continue
+			}
+			v[key] = hexToBase64(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = hexToBase64(val)
+		}
+	}
+	return value
+}
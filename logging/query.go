@@ -0,0 +1,234 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	queryPath       = "/core/log/LogEvent"
+	customIndexPath = "/core/log/settings/customIndex"
+)
+
+// Custom index field types
+const (
+	CustomIndexFieldTypeString  = "string"
+	CustomIndexFieldTypeInteger = "integer"
+	CustomIndexFieldTypeBoolean = "boolean"
+	CustomIndexFieldTypeDate    = "date"
+)
+
+// ErrNoMorePages is returned by NextPage when the last page was reached
+var ErrNoMorePages = errors.New("no more pages")
+
+// QueryOptions describes a LogEvent search. Empty fields are not used for filtering
+type QueryOptions struct {
+	StartTime       time.Time
+	EndTime         time.Time
+	ApplicationName string
+	ServiceName     string
+	Component       string
+	Severity        string
+	TransactionID   string
+	TraceID         string
+	// Custom filters on indexed custom fields, see CreateCustomIndex
+	Custom map[string]string
+	// Count is the page size
+	Count int
+}
+
+// QueryResponse is a single page of LogEvents
+type QueryResponse struct {
+	Total     int
+	Resources []Resource
+	// NextPage is the URL of the next page, empty on the last page
+	NextPage string
+}
+
+type queryBundle struct {
+	ResourceType string `json:"resourceType"`
+	Total        int    `json:"total"`
+	Link         []struct {
+		Relation string `json:"relation"`
+		URL      string `json:"url"`
+	} `json:"link"`
+	Entry []Element `json:"entry"`
+}
+
+func (o QueryOptions) values() url.Values {
+	v := url.Values{}
+	if !o.StartTime.IsZero() {
+		v.Add("logTime", "ge"+o.StartTime.UTC().Format(TimeFormat))
+	}
+	if !o.EndTime.IsZero() {
+		v.Add("logTime", "le"+o.EndTime.UTC().Format(TimeFormat))
+	}
+	for key, value := range map[string]string{
+		"applicationName": o.ApplicationName,
+		"serviceName":     o.ServiceName,
+		"component":       o.Component,
+		"severity":        o.Severity,
+		"transactionId":   o.TransactionID,
+		"traceId":         o.TraceID,
+	} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	for field, value := range o.Custom {
+		v.Set("custom."+strings.TrimPrefix(field, "custom."), value)
+	}
+	if o.Count > 0 {
+		v.Set("_count", strconv.Itoa(o.Count))
+	}
+	return v
+}
+
+// Query searches LogEvents and returns the first page of results
+func (c *Client) Query(opt QueryOptions) (*QueryResponse, *http.Response, error) {
+	u, err := c.endpoint(queryPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	u.RawQuery = opt.values().Encode()
+	return c.query(u)
+}
+
+// NextPage returns the page following page. ErrNoMorePages is returned after the last page
+func (c *Client) NextPage(page *QueryResponse) (*QueryResponse, *http.Response, error) {
+	if page == nil || page.NextPage == "" {
+		return nil, nil, ErrNoMorePages
+	}
+	u, err := url.Parse(page.NextPage)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Requests are signed, so never follow a link to another host
+	base, err := c.endpoint("")
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme != base.Scheme || u.Host != base.Host {
+		return nil, nil, fmt.Errorf("%w: %s", ErrForeignNextPage, page.NextPage)
+	}
+	return c.query(u)
+}
+
+// QueryAll searches LogEvents and calls fn for every result, following pages
+// until all results are processed or fn returns an error
+func (c *Client) QueryAll(opt QueryOptions, fn func(resource Resource) error) error {
+	page, _, err := c.Query(opt)
+	for err == nil {
+		for _, resource := range page.Resources {
+			if err := fn(resource); err != nil {
+				return err
+			}
+		}
+		page, _, err = c.NextPage(page)
+	}
+	if errors.Is(err, ErrNoMorePages) {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) query(u *url.URL) (*QueryResponse, *http.Response, error) {
+	var bundle queryBundle
+	resp, err := c.request(http.MethodGet, u, nil, &bundle)
+	if err != nil {
+		return nil, resp, err
+	}
+	page := &QueryResponse{Total: bundle.Total}
+	for _, e := range bundle.Entry {
+		page.Resources = append(page.Resources, e.Resource)
+	}
+	for _, link := range bundle.Link {
+		if link.Relation == "next" {
+			page.NextPage = link.URL
+		}
+	}
+	return page, resp, nil
+}
+
+// CreateCustomIndex adds fields to the custom index so they can be used in queries
+func (c *Client) CreateCustomIndex(body CustomIndexBody) (*http.Response, error) {
+	if len(body) == 0 {
+		return nil, ErrNothingToPost
+	}
+	u, err := c.endpoint(customIndexPath)
+	if err != nil {
+		return nil, err
+	}
+	return c.request(http.MethodPost, u, body, nil)
+}
+
+// GetCustomIndex returns the fields of the custom index
+func (c *Client) GetCustomIndex() (*CustomIndexBody, *http.Response, error) {
+	u, err := c.endpoint(customIndexPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var body CustomIndexBody
+	resp, err := c.request(http.MethodGet, u, nil, &body)
+	if err != nil {
+		return nil, resp, err
+	}
+	return &body, resp, nil
+}
+
+// endpoint returns the URL of path on the log query service
+func (c *Client) endpoint(path string) (*url.URL, error) {
+	if c.config.QueryURL == "" {
+		return nil, ErrMissingQueryURL
+	}
+	return url.Parse(strings.TrimSuffix(c.config.QueryURL, "/") + path)
+}
+
+// request performs an authorized JSON request and decodes a successful response
+// into v. Other statuses result in an ErrorResponse
+func (c *Client) request(method string, u *url.URL, body interface{}, v interface{}) (*http.Response, error) {
+	req := &http.Request{
+		Method:     method,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
+		req.ContentLength = int64(len(bodyBytes))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := c.authorize(req); err != nil {
+		return nil, err
+	}
+	var serverResponse bytes.Buffer
+	resp, err := c.do(req, &serverResponse)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp, &ErrorResponse{Response: resp, Message: serverResponse.String()}
+	}
+	if v != nil && serverResponse.Len() > 0 {
+		if err := json.Unmarshal(serverResponse.Bytes(), v); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
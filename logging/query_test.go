@@ -0,0 +1,191 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	signer "github.com/philips-software/go-hsdp-signer"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupQuery(t *testing.T) (*http.ServeMux, *Client, func()) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	c, err := NewClient(nil, &Config{
+		SharedKey:    sharedKey,
+		SharedSecret: sharedSecret,
+		ProductKey:   productKey,
+		BaseURL:      "https://ingest.example.com",
+		QueryURL:     server.URL,
+	})
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return mux, c, server.Close
+}
+
+func validSignature(w http.ResponseWriter, r *http.Request) bool {
+	s, _ := signer.New(sharedKey, sharedSecret)
+	if ok, _ := s.ValidateRequest(r); !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func TestQuery(t *testing.T) {
+	mux, c, teardown := setupQuery(t)
+	defer teardown()
+
+	var serverURL string
+	mux.HandleFunc("/core/log/LogEvent", func(w http.ResponseWriter, r *http.Request) {
+		if !assert.Equal(t, http.MethodGet, r.Method) || !validSignature(w, r) {
+			return
+		}
+		q := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		if q.Get("page") == "2" {
+			_, _ = io.WriteString(w, `{"resourceType":"Bundle","total":2,"entry":[{"resource":{"id":"2","logData":{"message":"second"}}}]}`)
+			return
+		}
+		assert.Equal(t, []string{"ge2022-10-01T12:00:00.000Z", "le2022-10-01T13:00:00.000Z"}, q["logTime"])
+		assert.Equal(t, "checkout", q.Get("applicationName"))
+		assert.Equal(t, "ERROR", q.Get("severity"))
+		assert.Equal(t, "tx-1", q.Get("transactionId"))
+		assert.Equal(t, "42", q.Get("custom.order"))
+		assert.Equal(t, "1", q.Get("_count"))
+		_, _ = io.WriteString(w, fmt.Sprintf(`{"resourceType":"Bundle","total":2,
+			"link":[{"relation":"next","url":"%s/core/log/LogEvent?page=2"}],
+			"entry":[{"resource":{"id":"1","logData":{"message":"first"}}}]}`, serverURL))
+	})
+	serverURL = c.config.QueryURL
+
+	start := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	opt := QueryOptions{
+		StartTime:       start,
+		EndTime:         start.Add(time.Hour),
+		ApplicationName: "checkout",
+		Severity:        "ERROR",
+		TransactionID:   "tx-1",
+		Custom:          map[string]string{"order": "42"},
+		Count:           1,
+	}
+	page, resp, err := c.Query(opt)
+	if !assert.Nil(t, err) || !assert.NotNil(t, resp) {
+		return
+	}
+	assert.Equal(t, 2, page.Total)
+	if assert.Len(t, page.Resources, 1) {
+		assert.Equal(t, "first", page.Resources[0].LogData.Message)
+	}
+	assert.NotEmpty(t, page.NextPage)
+
+	var messages []string
+	err = c.QueryAll(opt, func(r Resource) error {
+		messages = append(messages, r.LogData.Message)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first", "second"}, messages)
+
+	_, _, err = c.NextPage(&QueryResponse{})
+	assert.Equal(t, ErrNoMorePages, err)
+
+	// Signed requests are never sent to another host
+	for _, next := range []string{
+		"https://evil.example.com/core/log/LogEvent?page=2",
+		c.config.BaseURL + "/core/log/LogEvent?page=2",
+	} {
+		_, _, err = c.NextPage(&QueryResponse{NextPage: next})
+		assert.True(t, errors.Is(err, ErrForeignNextPage), next)
+	}
+}
+
+func TestQueryURL(t *testing.T) {
+	c, err := NewClient(nil, &Config{
+		SharedKey:    sharedKey,
+		SharedSecret: sharedSecret,
+		ProductKey:   productKey,
+		Region:       "us-east",
+		Environment:  "client-test",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "https://logquery-client-test.us-east.philips-healthsuite.com", c.config.QueryURL)
+	assert.NotEqual(t, c.config.BaseURL, c.config.QueryURL)
+
+	c, err = NewClient(nil, &Config{
+		SharedKey:    sharedKey,
+		SharedSecret: sharedSecret,
+		ProductKey:   productKey,
+		BaseURL:      "https://ingest.example.com",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, _, err = c.Query(QueryOptions{})
+	assert.Equal(t, ErrMissingQueryURL, err)
+}
+
+func TestCustomIndex(t *testing.T) {
+	mux, c, teardown := setupQuery(t)
+	defer teardown()
+
+	var stored CustomIndexBody
+	mux.HandleFunc("/core/log/settings/customIndex", func(w http.ResponseWriter, r *http.Request) {
+		if !validSignature(w, r) {
+			return
+		}
+		switch r.Method {
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&stored); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(stored)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	body := CustomIndexBody{
+		{Fieldname: "order", Fieldtype: CustomIndexFieldTypeString},
+	}
+	resp, err := c.CreateCustomIndex(body)
+	if assert.Nil(t, err) && assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+	index, _, err := c.GetCustomIndex()
+	if assert.Nil(t, err) && assert.NotNil(t, index) {
+		assert.Equal(t, body, *index)
+	}
+
+	_, err = c.CreateCustomIndex(CustomIndexBody{})
+	assert.Equal(t, ErrNothingToPost, err)
+}
+
+func TestQueryError(t *testing.T) {
+	mux, c, teardown := setupQuery(t)
+	defer teardown()
+
+	mux.HandleFunc("/core/log/LogEvent", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"issue":[{"diagnostics":"bad query"}]}`)
+	})
+	_, resp, err := c.Query(QueryOptions{})
+	if assert.NotNil(t, err) && assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.IsType(t, &ErrorResponse{}, err)
+	}
+}
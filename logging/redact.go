@@ -0,0 +1,357 @@
+package logging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Built-in detector names
+const (
+	DetectorEmail          = "email"
+	DetectorPhone          = "phone"
+	DetectorSSN            = "ssn"
+	DetectorBSN            = "bsn"
+	DetectorNHSNumber      = "nhs"
+	DetectorFHIRIdentifier = "fhirIdentifier"
+	DetectorCustomPath     = "customPath"
+)
+
+const (
+	defaultRedactionTag = "redactions"
+	// wrappedCustomField holds a Custom payload that is not a JSON object once it is tagged
+	wrappedCustomField = "value"
+)
+
+// redactedFields are the free text fields scanned besides LogData.Message and Custom
+var redactedFields = []struct {
+	name  string
+	value func(r *Resource) *string
+}{
+	{name: "originatingUser", value: func(r *Resource) *string { return &r.OriginatingUser }},
+	{name: "applicationName", value: func(r *Resource) *string { return &r.ApplicationName }},
+	{name: "applicationInstance", value: func(r *Resource) *string { return &r.ApplicationInstance }},
+	{name: "serviceName", value: func(r *Resource) *string { return &r.ServiceName }},
+	{name: "serverName", value: func(r *Resource) *string { return &r.ServerName }},
+	{name: "component", value: func(r *Resource) *string { return &r.Component }},
+	{name: "category", value: func(r *Resource) *string { return &r.Category }},
+}
+
+// Redaction records what was removed from a resource field
+type Redaction struct {
+	Field    string `json:"field"`
+	Detector string `json:"detector"`
+	Count    int    `json:"count"`
+}
+
+// Redactor removes sensitive data from a resource before it is sent
+type Redactor interface {
+	Redact(resource *Resource) []Redaction
+}
+
+// Detector finds sensitive values in text. Validate, when set, filters out
+// matches that fit the pattern but are not real identifiers, e.g. on a checksum
+type Detector struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Validate func(match string) bool
+}
+
+// EmailDetector detects e-mail addresses
+func EmailDetector() Detector {
+	return Detector{
+		Name:    DetectorEmail,
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	}
+}
+
+// PhoneDetector detects international and North American formatted phone numbers
+func PhoneDetector() Detector {
+	return Detector{
+		Name:    DetectorPhone,
+		Pattern: regexp.MustCompile(`\+\d{1,3}[\s.-]?\(?\d{1,4}\)?(?:[\s.-]?\d{2,4}){2,4}\b|\(?\b\d{3}\)?[\s.-]\d{3}[\s.-]\d{4}\b`),
+	}
+}
+
+// SSNDetector detects US social security numbers
+func SSNDetector() Detector {
+	return Detector{
+		Name:    DetectorSSN,
+		Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+		Validate: func(match string) bool {
+			area, group, serial := match[0:3], match[4:6], match[7:11]
+			return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+		},
+	}
+}
+
+// BSNDetector detects Dutch citizen service numbers using the eleven test
+func BSNDetector() Detector {
+	return Detector{
+		Name:    DetectorBSN,
+		Pattern: regexp.MustCompile(`\b\d{9}\b`),
+		Validate: func(match string) bool {
+			sum := 0
+			for i := 0; i < 8; i++ {
+				sum += int(match[i]-'0') * (9 - i)
+			}
+			sum -= int(match[8] - '0')
+			return sum != 0 && sum%11 == 0
+		},
+	}
+}
+
+// NHSNumberDetector detects UK NHS numbers using the modulus 11 check digit
+func NHSNumberDetector() Detector {
+	return Detector{
+		Name:    DetectorNHSNumber,
+		Pattern: regexp.MustCompile(`\b\d{3}[ -]?\d{3}[ -]?\d{4}\b`),
+		Validate: func(match string) bool {
+			digits := strings.NewReplacer(" ", "", "-", "").Replace(match)
+			sum := 0
+			for i := 0; i < 9; i++ {
+				sum += int(digits[i]-'0') * (10 - i)
+			}
+			check := 11 - sum%11
+			if check == 11 {
+				check = 0
+			}
+			return check != 10 && check == int(digits[9]-'0')
+		},
+	}
+}
+
+// FHIRIdentifierDetector detects references to person resources and system|value identifier tokens
+func FHIRIdentifierDetector() Detector {
+	return Detector{
+		Name:    DetectorFHIRIdentifier,
+		Pattern: regexp.MustCompile(`\b(?:Patient|Person|RelatedPerson|Practitioner)/[A-Za-z0-9\-.]{1,64}\b|(?:https?://|urn:oid:|urn:uuid:)[^\s|"]+\|[A-Za-z0-9\-.]+`),
+	}
+}
+
+// DefaultDetectors returns all built-in detectors. Detectors with checksums run
+// before the phone detector so their matches are attributed correctly
+func DefaultDetectors() []Detector {
+	return []Detector{
+		EmailDetector(),
+		FHIRIdentifierDetector(),
+		SSNDetector(),
+		NHSNumberDetector(),
+		BSNDetector(),
+		PhoneDetector(),
+	}
+}
+
+// RedactionPipeline is a Redactor which scans LogData.Message, the free text fields
+// such as OriginatingUser and all string values in Custom with its detectors and
+// blanks out configured Custom paths. Redactions are tagged in Custom so it can be
+// shown what was removed. A Custom payload which is not a JSON object is moved
+// under "value" to make room for the tag
+type RedactionPipeline struct {
+	Detectors []Detector
+	// CustomPaths are dotted JSON paths into Custom whose values are always redacted,
+	// e.g. "patient.name" or "$.visits.*.ssn". Arrays are traversed transparently
+	CustomPaths []string
+	// DecodeBase64Message redacts base64 encoded messages after decoding them
+	DecodeBase64Message bool
+	// TagField is the Custom field listing the redactions. Set to "-" to disable tagging
+	TagField string
+}
+
+var _ Redactor = &RedactionPipeline{}
+
+// NewRedactionPipeline returns a pipeline using detectors, or the default detectors when none are given
+func NewRedactionPipeline(detectors ...Detector) *RedactionPipeline {
+	if len(detectors) == 0 {
+		detectors = DefaultDetectors()
+	}
+	return &RedactionPipeline{
+		Detectors: detectors,
+		TagField:  defaultRedactionTag,
+	}
+}
+
+// AddPattern adds a user defined detector
+func (p *RedactionPipeline) AddPattern(name, expr string) error {
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	p.Detectors = append(p.Detectors, Detector{Name: name, Pattern: pattern})
+	return nil
+}
+
+// AddCustomPath adds a JSON path into Custom whose value is always redacted
+func (p *RedactionPipeline) AddCustomPath(path string) {
+	p.CustomPaths = append(p.CustomPaths, path)
+}
+
+// Redact removes sensitive data from resource and returns what was redacted
+func (p *RedactionPipeline) Redact(resource *Resource) []Redaction {
+	counts := make(map[Redaction]int)
+	count := func(field, detector string, n int) {
+		counts[Redaction{Field: field, Detector: detector}] += n
+	}
+
+	message := resource.LogData.Message
+	var encoded bool
+	if p.DecodeBase64Message {
+		if decoded, err := base64.StdEncoding.DecodeString(message); err == nil && utf8.Valid(decoded) {
+			message = string(decoded)
+			encoded = true
+		}
+	}
+	if redacted, changed := p.redactText(message, func(detector string, n int) { count("logData.message", detector, n) }); changed {
+		if encoded {
+			redacted = base64.StdEncoding.EncodeToString([]byte(redacted))
+		}
+		resource.LogData.Message = redacted
+	}
+	for _, f := range redactedFields {
+		value := f.value(resource)
+		if redacted, changed := p.redactText(*value, func(detector string, n int) { count(f.name, detector, n) }); changed {
+			*value = redacted
+		}
+	}
+
+	if len(resource.Custom) > 0 {
+		var custom interface{}
+		if err := json.Unmarshal(resource.Custom, &custom); err == nil {
+			changed := false
+			for _, path := range p.CustomPaths {
+				segments := strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, "$"), "."), ".")
+				if n := redactPath(custom, segments); n > 0 {
+					count("custom."+strings.Join(segments, "."), DetectorCustomPath, n)
+					changed = true
+				}
+			}
+			custom = p.redactValue(custom, "custom", count, &changed)
+			if changed {
+				if data, err := json.Marshal(custom); err == nil {
+					resource.Custom = data
+				}
+			}
+		}
+	}
+
+	redactions := make([]Redaction, 0, len(counts))
+	for key, n := range counts {
+		key.Count = n
+		redactions = append(redactions, key)
+	}
+	sort.Slice(redactions, func(i, j int) bool {
+		if redactions[i].Field != redactions[j].Field {
+			return redactions[i].Field < redactions[j].Field
+		}
+		return redactions[i].Detector < redactions[j].Detector
+	})
+	if len(redactions) > 0 {
+		p.tag(resource, redactions)
+	}
+	return redactions
+}
+
+func (p *RedactionPipeline) redactText(text string, count func(detector string, n int)) (string, bool) {
+	changed := false
+	for _, d := range p.Detectors {
+		n := 0
+		text = d.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.Validate != nil && !d.Validate(match) {
+				return match
+			}
+			n++
+			return redactionPlaceholder(d.Name)
+		})
+		if n > 0 {
+			count(d.Name, n)
+			changed = true
+		}
+	}
+	return text, changed
+}
+
+func (p *RedactionPipeline) redactValue(value interface{}, field string, count func(field, detector string, n int), changed *bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		redacted, ok := p.redactText(v, func(detector string, n int) { count(field, detector, n) })
+		if ok {
+			*changed = true
+			return redacted
+		}
+	case map[string]interface{}:
+		for key, val := range v {
+			if field == "custom" && key == p.TagField {
+				continue
+			}
+			v[key] = p.redactValue(val, field+"."+key, count, changed)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = p.redactValue(val, field, count, changed)
+		}
+	}
+	return value
+}
+
+// tag adds the redactions to the Custom payload
+func (p *RedactionPipeline) tag(resource *Resource, redactions []Redaction) {
+	if p.TagField == "-" {
+		return
+	}
+	tagField := p.TagField
+	if tagField == "" {
+		tagField = defaultRedactionTag
+	}
+	custom := make(map[string]interface{})
+	if len(resource.Custom) > 0 {
+		if err := json.Unmarshal(resource.Custom, &custom); err != nil {
+			// Arrays, scalars and invalid JSON are kept as they are next to the tag
+			var value interface{} = string(resource.Custom)
+			if json.Valid(resource.Custom) {
+				value = resource.Custom
+			}
+			custom = map[string]interface{}{wrappedCustomField: value}
+		}
+	}
+	custom[tagField] = redactions
+	if data, err := json.Marshal(custom); err == nil {
+		resource.Custom = data
+	}
+}
+
+// redactPath replaces the values at the path segments and returns the number of replaced values
+func redactPath(value interface{}, segments []string) int {
+	switch v := value.(type) {
+	case []interface{}:
+		n := 0
+		for _, item := range v {
+			n += redactPath(item, segments)
+		}
+		return n
+	case map[string]interface{}:
+		if len(segments) == 0 {
+			return 0
+		}
+		n := 0
+		for key, val := range v {
+			if segments[0] != "*" && segments[0] != key {
+				continue
+			}
+			if len(segments) == 1 {
+				v[key] = redactionPlaceholder(DetectorCustomPath)
+				n++
+				continue
+			}
+			n += redactPath(val, segments[1:])
+		}
+		return n
+	}
+	return 0
+}
+
+func redactionPlaceholder(detector string) string {
+	return "[REDACTED:" + detector + "]"
+}
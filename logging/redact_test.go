@@ -0,0 +1,150 @@
+package logging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactionPipelineDetectors(t *testing.T) {
+	pipeline := NewRedactionPipeline()
+
+	r := validResource
+	r.LogData.Message = "mail john.doe@example.com or call +31 20 123 4567, ssn 123-45-6789, " +
+		"bsn 111222333, nhs 943 476 5919, ref Patient/abc-123 id https://fhir.nl/fhir/NamingSystem/bsn|999999990"
+	redactions := pipeline.Redact(&r)
+
+	message := r.LogData.Message
+	for _, secret := range []string{"john.doe", "123 4567", "123-45-6789", "111222333", "943 476 5919", "abc-123", "999999990"} {
+		assert.NotContains(t, message, secret)
+	}
+	detectors := make(map[string]int)
+	for _, red := range redactions {
+		assert.Equal(t, "logData.message", red.Field)
+		detectors[red.Detector] = red.Count
+	}
+	assert.Equal(t, map[string]int{
+		DetectorEmail:          1,
+		DetectorPhone:          1,
+		DetectorSSN:            1,
+		DetectorBSN:            1,
+		DetectorNHSNumber:      1,
+		DetectorFHIRIdentifier: 2,
+	}, detectors)
+
+	var custom map[string]interface{}
+	assert.Nil(t, json.Unmarshal(r.Custom, &custom))
+	assert.Len(t, custom[defaultRedactionTag], 6)
+
+	// Numbers failing the checksums are left alone
+	r = validResource
+	r.LogData.Message = "order 123456789 took 1234567890 ms"
+	assert.Empty(t, pipeline.Redact(&r))
+	assert.Equal(t, "order 123456789 took 1234567890 ms", r.LogData.Message)
+	assert.Nil(t, r.Custom)
+}
+
+func TestRedactionPipelineCustom(t *testing.T) {
+	pipeline := NewRedactionPipeline(EmailDetector())
+	pipeline.AddCustomPath("$.patient.name")
+	pipeline.AddCustomPath("visits.*.room")
+	assert.Nil(t, pipeline.AddPattern("mrn", `MRN-\d+`))
+	assert.NotNil(t, pipeline.AddPattern("broken", `(`))
+	pipeline.TagField = "-"
+
+	r := validResource
+	r.LogData.Message = "admitted MRN-42"
+	r.Custom = []byte(`{"patient":{"name":"Jane","contact":"jane@example.com"},"visits":[{"ward":{"room":"12"}},{"ward":{"room":"14"}}]}`)
+	redactions := pipeline.Redact(&r)
+
+	assert.Equal(t, "admitted [REDACTED:mrn]", r.LogData.Message)
+	var custom map[string]interface{}
+	assert.Nil(t, json.Unmarshal(r.Custom, &custom))
+	assert.NotContains(t, custom, defaultRedactionTag)
+	patient := custom["patient"].(map[string]interface{})
+	assert.Equal(t, "[REDACTED:customPath]", patient["name"])
+	assert.Equal(t, "[REDACTED:email]", patient["contact"])
+	assert.NotContains(t, string(r.Custom), `"12"`)
+	assert.Equal(t, []Redaction{
+		{Field: "custom.patient.contact", Detector: DetectorEmail, Count: 1},
+		{Field: "custom.patient.name", Detector: DetectorCustomPath, Count: 1},
+		{Field: "custom.visits.*.room", Detector: DetectorCustomPath, Count: 2},
+		{Field: "logData.message", Detector: "mrn", Count: 1},
+	}, redactions)
+}
+
+func TestRedactionPipelineBase64(t *testing.T) {
+	pipeline := NewRedactionPipeline()
+	pipeline.DecodeBase64Message = true
+
+	r := validResource
+	r.LogData.Message = base64.StdEncoding.EncodeToString([]byte("user jane@example.com logged in"))
+	redactions := pipeline.Redact(&r)
+	assert.Len(t, redactions, 1)
+	decoded, err := base64.StdEncoding.DecodeString(r.LogData.Message)
+	assert.Nil(t, err)
+	assert.Equal(t, "user [REDACTED:email] logged in", string(decoded))
+}
+
+func TestStoreResourcesWithRedactor(t *testing.T) {
+	teardown, err := setup(t, &Config{
+		SharedKey:    sharedKey,
+		SharedSecret: sharedSecret,
+		ProductKey:   productKey,
+		BaseURL:      "http://foo",
+		Redactor:     NewRedactionPipeline(),
+	}, "POST", http.StatusCreated, "")
+	if teardown != nil {
+		defer teardown()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := validResource
+	r.Custom = []byte(`{"email":"jane@example.com"}`)
+	resp, err := client.StoreResources([]Resource{r}, 1)
+	if !assert.Nil(t, err) || !assert.NotNil(t, resp) {
+		return
+	}
+	if assert.Len(t, resp.Redactions[0], 1) {
+		assert.Equal(t, "custom.email", resp.Redactions[0][0].Field)
+	}
+	// The caller's resource is not modified
+	assert.True(t, strings.Contains(string(r.Custom), "jane@example.com"))
+}
+
+func TestRedactionPipelineTagsNonObjectCustom(t *testing.T) {
+	pipeline := NewRedactionPipeline()
+	r := validResource
+	r.Custom = []byte(`["mail jane@example.com", 42]`)
+	redactions := pipeline.Redact(&r)
+	assert.Len(t, redactions, 1)
+
+	var custom struct {
+		Value      []interface{} `json:"value"`
+		Redactions []Redaction   `json:"redactions"`
+	}
+	if assert.Nil(t, json.Unmarshal(r.Custom, &custom)) {
+		assert.Equal(t, []interface{}{"mail [REDACTED:email]", float64(42)}, custom.Value)
+		assert.Equal(t, redactions, custom.Redactions)
+	}
+}
+
+func TestRedactionPipelineFreeTextFields(t *testing.T) {
+	pipeline := NewRedactionPipeline()
+	r := validResource
+	r.OriginatingUser = "jane@example.com"
+	r.ServerName = "host-for-john@example.com"
+	redactions := pipeline.Redact(&r)
+	assert.Equal(t, []Redaction{
+		{Field: "originatingUser", Detector: DetectorEmail, Count: 1},
+		{Field: "serverName", Detector: DetectorEmail, Count: 1},
+	}, redactions)
+	assert.Equal(t, "[REDACTED:email]", r.OriginatingUser)
+	assert.Contains(t, string(r.Custom), `"originatingUser"`)
+}
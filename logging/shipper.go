@@ -0,0 +1,424 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// OverflowPolicy determines what happens when the Shipper queue is full
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the resource being queued
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards the oldest queued resource to make room
+	DropOldest
+	// Block waits until there is room in the queue
+	Block
+)
+
+const (
+	defaultShipperQueueSize      = 1000
+	defaultShipperMaxBatchSize   = 100
+	defaultShipperMaxBatchBytes  = 1024 * 1024
+	defaultShipperMaxBatchAge    = 5 * time.Second
+	defaultShipperConcurrency    = 2
+	defaultShipperMaxRetries     = 5
+	defaultShipperInitialBackoff = 500 * time.Millisecond
+	defaultShipperMaxBackoff     = 30 * time.Second
+)
+
+// ShipperConfig configures a Shipper. Zero values are replaced by defaults
+type ShipperConfig struct {
+	// QueueSize is the number of resources that can be queued
+	QueueSize int
+	// MaxBatchSize is the maximum number of resources per StoreResources call
+	MaxBatchSize int
+	// MaxBatchBytes is the maximum JSON encoded size of a batch
+	MaxBatchBytes int
+	// MaxBatchAge is the maximum time a resource waits before its batch is flushed
+	MaxBatchAge time.Duration
+	// Concurrency is the maximum number of concurrent StoreResources calls
+	Concurrency int
+	// MaxRetries is the number of retries for transient failures
+	MaxRetries int
+	// InitialBackoff and MaxBackoff bound the retry backoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Overflow is the policy applied when the queue is full
+	Overflow OverflowPolicy
+	// OnError is called with resources which could not be stored
+	OnError func(failed []Resource, err error)
+}
+
+// ShipperStats holds the counters of a Shipper
+type ShipperStats struct {
+	Queued   int64
+	Sent     int64
+	Dropped  int64
+	Rejected int64
+	Failed   int64
+	Retries  int64
+}
+
+type shippedResource struct {
+	resource Resource
+	size     int
+}
+
+// Shipper ships resources to a Storer in the background. Resources are
+// batched and flushed on size, byte size or age. Transient failures are
+// retried with backoff and entries rejected by HSDP are split off the batch
+type Shipper struct {
+	storer Storer
+	config ShipperConfig
+
+	queue   chan shippedResource
+	flushes chan chan struct{}
+	stopped chan struct{}
+	slots   chan struct{}
+	// closing releases blocked senders, shutdown tells run to finish once no
+	// sender can queue anymore
+	closing  chan struct{}
+	shutdown chan struct{}
+
+	inFlight  sync.WaitGroup
+	closeOnce sync.Once
+	closeMu   sync.RWMutex
+	closed    bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	queued   int64
+	sent     int64
+	dropped  int64
+	rejected int64
+	failed   int64
+	retries  int64
+}
+
+var _ Storer = &Shipper{}
+
+// NewShipper starts a Shipper which stores resources through storer
+func NewShipper(storer Storer, config ShipperConfig) (*Shipper, error) {
+	if storer == nil {
+		return nil, ErrMissingStorer
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultShipperQueueSize
+	}
+	if config.MaxBatchSize <= 0 {
+		config.MaxBatchSize = defaultShipperMaxBatchSize
+	}
+	if config.MaxBatchBytes <= 0 {
+		config.MaxBatchBytes = defaultShipperMaxBatchBytes
+	}
+	if config.MaxBatchAge <= 0 {
+		config.MaxBatchAge = defaultShipperMaxBatchAge
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultShipperConcurrency
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = defaultShipperMaxRetries
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaultShipperInitialBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultShipperMaxBackoff
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Shipper{
+		storer:   storer,
+		config:   config,
+		queue:    make(chan shippedResource, config.QueueSize),
+		flushes:  make(chan chan struct{}),
+		stopped:  make(chan struct{}),
+		slots:    make(chan struct{}, config.Concurrency),
+		closing:  make(chan struct{}),
+		shutdown: make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	go s.run()
+	return s, nil
+}
+
+// Send queues a resource for shipping. The overflow policy is applied when the queue is full
+func (s *Shipper) Send(resource Resource) error {
+	s.closeMu.RLock()
+	defer s.closeMu.RUnlock()
+	if s.closed {
+		return ErrShipperClosed
+	}
+	item := shippedResource{resource: resource, size: resourceSize(resource)}
+	for {
+		select {
+		case s.queue <- item:
+			atomic.AddInt64(&s.queued, 1)
+			return nil
+		default:
+		}
+		switch s.config.Overflow {
+		case Block:
+			select {
+			case s.queue <- item:
+				atomic.AddInt64(&s.queued, 1)
+				return nil
+			case <-s.closing:
+				atomic.AddInt64(&s.dropped, 1)
+				return ErrShipperClosed
+			}
+		case DropOldest:
+			select {
+			case <-s.queue:
+				atomic.AddInt64(&s.dropped, 1)
+			default:
+			}
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+			return ErrQueueFull
+		}
+	}
+}
+
+// StoreResources queues count resources for shipping. It implements Storer so a
+// Shipper can be used wherever a Client is expected. The returned response is empty
+func (s *Shipper) StoreResources(msgs []Resource, count int) (*StoreResponse, error) {
+	var firstErr error
+	for i := 0; i < count && i < len(msgs); i++ {
+		if err := s.Send(msgs[i]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return &StoreResponse{}, firstErr
+}
+
+// Flush ships all queued resources and waits until they are stored or ctx expires
+func (s *Shipper) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case s.flushes <- done:
+	case <-s.shutdown:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting resources, ships everything queued and waits until done
+// or ctx expires. When ctx expires pending retries are abandoned and Close returns
+// without waiting for StoreResources calls still in progress
+func (s *Shipper) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		// Senders blocked on a full queue hold the read lock until released
+		close(s.closing)
+		s.closeMu.Lock()
+		s.closed = true
+		s.closeMu.Unlock()
+		close(s.shutdown)
+	})
+	select {
+	case <-s.stopped:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the shipper counters
+func (s *Shipper) Stats() ShipperStats {
+	return ShipperStats{
+		Queued:   atomic.LoadInt64(&s.queued),
+		Sent:     atomic.LoadInt64(&s.sent),
+		Dropped:  atomic.LoadInt64(&s.dropped),
+		Rejected: atomic.LoadInt64(&s.rejected),
+		Failed:   atomic.LoadInt64(&s.failed),
+		Retries:  atomic.LoadInt64(&s.retries),
+	}
+}
+
+func (s *Shipper) run() {
+	defer close(s.stopped)
+	defer s.cancel()
+
+	var batch []Resource
+	batchBytes := 0
+	// age fires MaxBatchAge after the first resource of the batch was added
+	var age *time.Timer
+	var ageC <-chan time.Time
+
+	dispatch := func() {
+		if age != nil {
+			age.Stop()
+			age, ageC = nil, nil
+		}
+		if len(batch) == 0 {
+			return
+		}
+		s.dispatch(batch)
+		batch = nil
+		batchBytes = 0
+	}
+	add := func(item shippedResource) {
+		if len(batch) > 0 && batchBytes+item.size > s.config.MaxBatchBytes {
+			dispatch()
+		}
+		if len(batch) == 0 {
+			age = time.NewTimer(s.config.MaxBatchAge)
+			ageC = age.C
+		}
+		batch = append(batch, item.resource)
+		batchBytes += item.size
+		if len(batch) >= s.config.MaxBatchSize {
+			dispatch()
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case item := <-s.queue:
+				add(item)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case item := <-s.queue:
+			add(item)
+		case <-ageC:
+			dispatch()
+		case <-s.shutdown:
+			drain()
+			dispatch()
+			s.inFlight.Wait()
+			return
+		case done := <-s.flushes:
+			drain()
+			dispatch()
+			go func() {
+				s.inFlight.Wait()
+				close(done)
+			}()
+		}
+	}
+}
+
+func (s *Shipper) dispatch(batch []Resource) {
+	s.slots <- struct{}{}
+	s.inFlight.Add(1)
+	go func() {
+		defer func() {
+			<-s.slots
+			s.inFlight.Done()
+		}()
+		s.ship(batch)
+	}()
+}
+
+// ship stores a batch, retrying transient failures and splitting off rejected entries
+func (s *Shipper) ship(batch []Resource) {
+	for len(batch) > 0 {
+		var resp *StoreResponse
+		var err error
+		retries := 0
+		operation := func() error {
+			if retries > 0 {
+				atomic.AddInt64(&s.retries, 1)
+			}
+			retries++
+			resp, err = s.storer.StoreResources(batch, len(batch))
+			if err == nil && resp != nil && resp.Response != nil && resp.StatusCode >= http.StatusBadRequest {
+				err = ErrResponseError
+			}
+			if err != nil && isTransient(resp, err) {
+				return err
+			}
+			return nil
+		}
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = s.config.InitialBackoff
+		b.MaxInterval = s.config.MaxBackoff
+		b.MaxElapsedTime = 0
+		_ = backoff.Retry(operation, backoff.WithContext(backoff.WithMaxRetries(b, uint64(s.config.MaxRetries)), s.ctx))
+
+		switch {
+		case err == nil:
+			atomic.AddInt64(&s.sent, int64(len(batch)))
+			return
+		case isTransient(resp, err):
+			s.fail(batch, err, &s.failed)
+			return
+		case resp != nil && len(resp.Failed) > 0:
+			// The complete batch was refused; drop the rejected entries and resend the rest
+			var rejected, remaining []Resource
+			for i, r := range batch {
+				if failed, ok := resp.Failed[i]; ok {
+					rejected = append(rejected, failed)
+					continue
+				}
+				remaining = append(remaining, r)
+			}
+			s.fail(rejected, err, &s.rejected)
+			batch = remaining
+		case len(batch) > 1:
+			// Unknown permanent failure; split to isolate the offending entries
+			half := len(batch) / 2
+			s.ship(batch[:half])
+			batch = batch[half:]
+		default:
+			s.fail(batch, err, &s.rejected)
+			return
+		}
+	}
+}
+
+func (s *Shipper) fail(resources []Resource, err error, counter *int64) {
+	atomic.AddInt64(counter, int64(len(resources)))
+	if s.config.OnError != nil && len(resources) > 0 {
+		s.config.OnError(resources, err)
+	}
+}
+
+// isTransient returns true for failures which are worth retrying
+func isTransient(resp *StoreResponse, err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrBatchErrors) {
+		return false
+	}
+	if resp == nil || resp.Response == nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+func resourceSize(resource Resource) int {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
@@ -0,0 +1,256 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStorer struct {
+	sync.Mutex
+	batches [][]Resource
+	store   func(call int, msgs []Resource) (*StoreResponse, error)
+}
+
+func (f *fakeStorer) StoreResources(msgs []Resource, count int) (*StoreResponse, error) {
+	f.Lock()
+	call := len(f.batches)
+	batch := make([]Resource, count)
+	copy(batch, msgs[:count])
+	f.batches = append(f.batches, batch)
+	f.Unlock()
+	if f.store != nil {
+		return f.store(call, batch)
+	}
+	return &StoreResponse{Response: &http.Response{StatusCode: http.StatusCreated}}, nil
+}
+
+func (f *fakeStorer) calls() [][]Resource {
+	f.Lock()
+	defer f.Unlock()
+	return append([][]Resource(nil), f.batches...)
+}
+
+func shipperResource(id string) Resource {
+	r := validResource
+	r.ID = id
+	return r
+}
+
+func TestShipperBatchSize(t *testing.T) {
+	storer := &fakeStorer{}
+	shipper, err := NewShipper(storer, ShipperConfig{MaxBatchSize: 2, MaxBatchAge: time.Hour})
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		assert.Nil(t, shipper.Send(shipperResource(id)))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, shipper.Close(ctx))
+
+	calls := storer.calls()
+	assert.Len(t, calls, 2)
+	stats := shipper.Stats()
+	assert.Equal(t, int64(3), stats.Queued)
+	assert.Equal(t, int64(3), stats.Sent)
+	assert.Equal(t, ErrShipperClosed, shipper.Send(shipperResource("4")))
+}
+
+func TestShipperBatchAge(t *testing.T) {
+	storer := &fakeStorer{}
+	shipper, err := NewShipper(storer, ShipperConfig{MaxBatchAge: 20 * time.Millisecond})
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer shipper.Close(context.Background())
+
+	_, err = shipper.StoreResources([]Resource{shipperResource("1")}, 1)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return shipper.Stats().Sent == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestShipperCloseDeadlineWithBlockedStorer(t *testing.T) {
+	release := make(chan struct{})
+	storer := &fakeStorer{store: func(call int, msgs []Resource) (*StoreResponse, error) {
+		<-release
+		return &StoreResponse{Response: &http.Response{StatusCode: http.StatusCreated}}, nil
+	}}
+	defer close(release)
+	shipper, err := NewShipper(storer, ShipperConfig{
+		QueueSize:    1,
+		MaxBatchSize: 1,
+		Concurrency:  1,
+		Overflow:     Block,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	// One batch in the storer, one waiting for a slot and one queued
+	for _, id := range []string{"1", "2", "3"} {
+		assert.Nil(t, shipper.Send(shipperResource(id)))
+	}
+	assert.Eventually(t, func() bool {
+		return len(storer.calls()) == 1 && len(shipper.queue) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- shipper.Send(shipperResource("4"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	assert.Equal(t, context.DeadlineExceeded, shipper.Close(ctx))
+	assert.Less(t, time.Since(started), time.Second)
+	select {
+	case err := <-blocked:
+		assert.Equal(t, ErrShipperClosed, err)
+	case <-time.After(time.Second):
+		t.Error("Send is still blocked after Close")
+	}
+}
+
+func TestShipperRetry(t *testing.T) {
+	storer := &fakeStorer{
+		store: func(call int, msgs []Resource) (*StoreResponse, error) {
+			if call < 2 {
+				return &StoreResponse{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}, ErrResponseError
+			}
+			return &StoreResponse{Response: &http.Response{StatusCode: http.StatusCreated}}, nil
+		},
+	}
+	shipper, err := NewShipper(storer, ShipperConfig{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Nil(t, shipper.Send(shipperResource("1")))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, shipper.Flush(ctx))
+
+	stats := shipper.Stats()
+	assert.Equal(t, int64(1), stats.Sent)
+	assert.Equal(t, int64(2), stats.Retries)
+	assert.Len(t, storer.calls(), 3)
+	assert.Nil(t, shipper.Close(ctx))
+}
+
+func TestShipperRejectedEntries(t *testing.T) {
+	var mu sync.Mutex
+	var rejected []Resource
+	storer := &fakeStorer{
+		store: func(call int, msgs []Resource) (*StoreResponse, error) {
+			for i, m := range msgs {
+				if m.ID == "bad" {
+					return &StoreResponse{
+						Response: &http.Response{StatusCode: http.StatusBadRequest},
+						Failed:   map[int]Resource{i: m},
+					}, ErrBatchErrors
+				}
+			}
+			return &StoreResponse{Response: &http.Response{StatusCode: http.StatusCreated}}, nil
+		},
+	}
+	shipper, err := NewShipper(storer, ShipperConfig{
+		MaxBatchAge: time.Hour,
+		OnError: func(failed []Resource, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			rejected = append(rejected, failed...)
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, id := range []string{"1", "bad", "2"} {
+		assert.Nil(t, shipper.Send(shipperResource(id)))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, shipper.Close(ctx))
+
+	stats := shipper.Stats()
+	assert.Equal(t, int64(2), stats.Sent)
+	assert.Equal(t, int64(1), stats.Rejected)
+	assert.Equal(t, int64(0), stats.Retries)
+	if assert.Len(t, rejected, 1) {
+		assert.Equal(t, "bad", rejected[0].ID)
+	}
+	calls := storer.calls()
+	if assert.Len(t, calls, 2) {
+		assert.Len(t, calls[1], 2)
+	}
+}
+
+func TestShipperSplitsPermanentFailures(t *testing.T) {
+	storer := &fakeStorer{
+		store: func(call int, msgs []Resource) (*StoreResponse, error) {
+			for _, m := range msgs {
+				if m.ID == "bad" {
+					return &StoreResponse{Response: &http.Response{StatusCode: http.StatusBadRequest}}, ErrResponseError
+				}
+			}
+			return &StoreResponse{Response: &http.Response{StatusCode: http.StatusCreated}}, nil
+		},
+	}
+	shipper, err := NewShipper(storer, ShipperConfig{MaxBatchAge: time.Hour})
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, id := range []string{"1", "2", "bad", "3"} {
+		assert.Nil(t, shipper.Send(shipperResource(id)))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, shipper.Close(ctx))
+
+	stats := shipper.Stats()
+	assert.Equal(t, int64(3), stats.Sent)
+	assert.Equal(t, int64(1), stats.Rejected)
+}
+
+func TestShipperOverflow(t *testing.T) {
+	block := make(chan struct{})
+	storer := &fakeStorer{
+		store: func(call int, msgs []Resource) (*StoreResponse, error) {
+			<-block
+			return &StoreResponse{Response: &http.Response{StatusCode: http.StatusCreated}}, nil
+		},
+	}
+	shipper, err := NewShipper(storer, ShipperConfig{
+		QueueSize:    1,
+		MaxBatchSize: 1,
+		Concurrency:  1,
+		Overflow:     DropNewest,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	var full bool
+	for i := 0; i < 10 && !full; i++ {
+		full = shipper.Send(shipperResource("1")) == ErrQueueFull
+	}
+	assert.True(t, full)
+	assert.True(t, shipper.Stats().Dropped > 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, shipper.Flush(ctx))
+
+	close(block)
+	assert.Nil(t, shipper.Close(context.Background()))
+}
+
+func TestShipperMissingStorer(t *testing.T) {
+	_, err := NewShipper(nil, ShipperConfig{})
+	assert.Equal(t, ErrMissingStorer, err)
+}
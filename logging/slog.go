@@ -0,0 +1,152 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler is a slog.Handler which stores records as LogEvents
+type SlogHandler struct {
+	storer Storer
+	config EventConfig
+	level  slog.Leveler
+	attrs  map[string]interface{}
+	groups []string
+}
+
+var _ slog.Handler = &SlogHandler{}
+
+// NewSlogHandler returns a slog.Handler storing records of at least level through storer.
+// Use a Shipper as storer to batch the events. A nil level defaults to slog.LevelInfo.
+// The config must pass EventConfig.Validate
+func NewSlogHandler(storer Storer, config EventConfig, level slog.Leveler) (*SlogHandler, error) {
+	if storer == nil {
+		return nil, ErrMissingStorer
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &SlogHandler{
+		storer: storer,
+		config: config,
+		level:  level,
+		attrs:  make(map[string]interface{}),
+	}, nil
+}
+
+// SlogSeverity maps a slog level to a LogEvent severity
+func SlogSeverity(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return SeverityDebug
+	case level < slog.LevelWarn:
+		return SeverityInfo
+	case level < slog.LevelError:
+		return SeverityWarning
+	}
+	return SeverityError
+}
+
+// Enabled reports whether records of level are stored
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle stores the record
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	custom := copyFields(h.attrs)
+	target := groupFields(custom, h.groups)
+	record.Attrs(func(attr slog.Attr) bool {
+		addSlogAttr(target, attr)
+		return true
+	})
+	resource := h.config.NewResource(ctx, record.Time, SlogSeverity(record.Level), record.Message, custom)
+	_, err := h.storer.StoreResources([]Resource{resource}, 1)
+	return err
+}
+
+// WithAttrs returns a handler which adds attrs to every record
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := h.clone()
+	target := groupFields(clone.attrs, clone.groups)
+	for _, attr := range attrs {
+		addSlogAttr(target, attr)
+	}
+	return clone
+}
+
+// WithGroup returns a handler which nests subsequent attributes under name
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := h.clone()
+	clone.groups = append(clone.groups, name)
+	return clone
+}
+
+func (h *SlogHandler) clone() *SlogHandler {
+	clone := *h
+	clone.attrs = copyFields(h.attrs)
+	clone.groups = append([]string(nil), h.groups...)
+	return &clone
+}
+
+// groupFields returns the nested map for groups, creating it when needed
+func groupFields(fields map[string]interface{}, groups []string) map[string]interface{} {
+	for _, g := range groups {
+		next, ok := fields[g].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			fields[g] = next
+		}
+		fields = next
+	}
+	return fields
+}
+
+// copyFields deep copies nested field maps so handlers do not share state
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if m, ok := v.(map[string]interface{}); ok {
+			v = copyFields(m)
+		}
+		c[k] = v
+	}
+	return c
+}
+
+func addSlogAttr(fields map[string]interface{}, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if value.Kind() == slog.KindGroup {
+		group := value.Group()
+		if len(group) == 0 {
+			return
+		}
+		target := fields
+		if attr.Key != "" {
+			target = groupFields(fields, []string{attr.Key})
+		}
+		for _, a := range group {
+			addSlogAttr(target, a)
+		}
+		return
+	}
+	switch value.Kind() {
+	case slog.KindTime:
+		fields[attr.Key] = value.Time().Format(TimeFormat)
+	case slog.KindDuration:
+		fields[attr.Key] = value.Duration().String()
+	default:
+		fields[attr.Key] = value.Any()
+	}
+}
@@ -0,0 +1,67 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	storer := &fakeStorer{}
+	handler, err := NewSlogHandler(storer, EventConfig{
+		ApplicationName:     "app",
+		ApplicationInstance: "instance",
+		ApplicationVersion:  "1.0.0",
+		ServiceName:         "service",
+		Component:           "component",
+		OriginatingUser:     "user",
+	}, slog.LevelInfo)
+	if !assert.Nil(t, err) {
+		return
+	}
+	logger := slog.New(handler).With("app", "test").WithGroup("request")
+
+	ctx := ContextWithTrace(context.Background(), "trace", "span")
+	logger.DebugContext(ctx, "ignored")
+	logger.WarnContext(ctx, "slow request", "path", "/foo", slog.Group("timing", "ms", 120))
+
+	calls := storer.calls()
+	if !assert.Len(t, calls, 1) {
+		return
+	}
+	resource := calls[0][0]
+	assert.True(t, resource.Valid())
+	report := NewValidator(ValidationReject).Validate(&resource)
+	assert.True(t, report.Valid(), "%v", report.Violations)
+	assert.Equal(t, SeverityWarning, resource.Severity)
+	assert.Equal(t, "slow request", resource.LogData.Message)
+	assert.Equal(t, "trace", resource.TraceID)
+	assert.Equal(t, "span", resource.SpanID)
+	assert.Equal(t, "service", resource.ServiceName)
+
+	var custom map[string]interface{}
+	assert.Nil(t, json.Unmarshal(resource.Custom, &custom))
+	assert.Equal(t, "test", custom["app"])
+	assert.Equal(t, map[string]interface{}{
+		"path":   "/foo",
+		"timing": map[string]interface{}{"ms": float64(120)},
+	}, custom["request"])
+
+	_, err = NewSlogHandler(nil, EventConfig{}, nil)
+	assert.Equal(t, ErrMissingStorer, err)
+	_, err = NewSlogHandler(storer, EventConfig{ServiceName: "service"}, nil)
+	assert.True(t, errors.Is(err, ErrInvalidEventConfig))
+}
+
+func TestSlogSeverity(t *testing.T) {
+	assert.Equal(t, SeverityDebug, SlogSeverity(slog.LevelDebug))
+	assert.Equal(t, SeverityInfo, SlogSeverity(slog.LevelInfo))
+	assert.Equal(t, SeverityWarning, SlogSeverity(slog.LevelWarn))
+	assert.Equal(t, SeverityError, SlogSeverity(slog.LevelError))
+}
@@ -0,0 +1,660 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// SpoolOverflowPolicy determines what happens when the spool reaches its maximum size
+type SpoolOverflowPolicy int
+
+const (
+	// SpoolReject refuses new resources with ErrSpoolFull
+	SpoolReject SpoolOverflowPolicy = iota
+	// SpoolDropOldest discards the oldest segment to make room
+	SpoolDropOldest
+)
+
+const (
+	defaultSpoolMaxSize        = 64 * 1024 * 1024
+	defaultSpoolSegmentSize    = 4 * 1024 * 1024
+	defaultSpoolInitialBackoff = time.Second
+	defaultSpoolMaxBackoff     = time.Minute
+
+	spoolSegmentPrefix = "segment-"
+	spoolSegmentSuffix = ".log"
+	spoolCursorFile    = "cursor.json"
+	spoolHeaderSize    = 8
+)
+
+// SpoolConfig configures a Spool. Zero values are replaced by defaults
+type SpoolConfig struct {
+	// Dir is the directory holding the segment files
+	Dir string
+	// MaxSize caps the total size of all segment files in bytes
+	MaxSize int64
+	// SegmentSize is the size after which a new segment file is started
+	SegmentSize int64
+	// Overflow is the policy applied when MaxSize is reached
+	Overflow SpoolOverflowPolicy
+	// NoSync skips the fsync after every append, trading durability for speed
+	NoSync bool
+	// InitialBackoff and MaxBackoff bound the delay between delivery attempts
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// OnError is called with resources which are permanently rejected. Resources
+	// are nil when segments are dropped or skipped without being read
+	OnError func(failed []Resource, err error)
+}
+
+// SpoolStats holds the state and counters of a Spool. Resource counters
+// only cover the lifetime of the Spool, not earlier runs
+type SpoolStats struct {
+	Segments  int
+	Size      int64
+	Pending   int
+	Appended  int64
+	Delivered int64
+	Dropped   int64
+	Rejected  int64
+	Retries   int64
+}
+
+type spoolSegment struct {
+	id        uint64
+	size      int64
+	records   int
+	resources int
+}
+
+type spoolCursor struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+
+	records   int
+	resources int
+}
+
+// Spool is a write-ahead log in front of a Storer. Every batch is appended to
+// a segment file on disk before it is delivered, in order, from the background.
+// Undelivered batches are replayed when a Spool is opened on the same directory
+type Spool struct {
+	storer Storer
+	config SpoolConfig
+
+	mu         sync.Mutex
+	segments   []*spoolSegment
+	active     *os.File
+	cursor     spoolCursor
+	pending    int
+	size       int64
+	generation int
+	closing    bool
+	drained    chan struct{}
+
+	wake    chan struct{}
+	stopped chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	appended  int64
+	delivered int64
+	dropped   int64
+	rejected  int64
+	retries   int64
+}
+
+var _ Storer = &Spool{}
+
+// NewSpool opens or creates the spool in config.Dir and starts delivering
+// pending batches to storer
+func NewSpool(storer Storer, config SpoolConfig) (*Spool, error) {
+	if storer == nil {
+		return nil, ErrMissingStorer
+	}
+	if config.Dir == "" {
+		return nil, ErrMissingSpoolDir
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaultSpoolMaxSize
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = defaultSpoolSegmentSize
+	}
+	if config.SegmentSize > config.MaxSize/4 {
+		config.SegmentSize = config.MaxSize / 4
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaultSpoolInitialBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultSpoolMaxBackoff
+	}
+	if err := os.MkdirAll(config.Dir, 0700); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Spool{
+		storer:  storer,
+		config:  config,
+		drained: make(chan struct{}),
+		wake:    make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	if err := s.open(); err != nil {
+		cancel()
+		return nil, err
+	}
+	s.markDrained()
+	go s.run()
+	return s, nil
+}
+
+// StoreResources appends count resources as a single batch to the spool. The
+// batch is durable once this returns without error and is delivered later
+func (s *Spool) StoreResources(msgs []Resource, count int) (*StoreResponse, error) {
+	if count > len(msgs) {
+		count = len(msgs)
+	}
+	if count <= 0 {
+		return nil, ErrNothingToPost
+	}
+	batch := msgs[:count]
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, spoolHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[spoolHeaderSize:], payload)
+	size := int64(len(record))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return nil, ErrSpoolClosed
+	}
+	if s.size+size > s.config.MaxSize && s.config.Overflow == SpoolDropOldest {
+		for s.size+size > s.config.MaxSize && len(s.segments) > 1 {
+			if err := s.dropOldest(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if s.size+size > s.config.MaxSize {
+		return nil, ErrSpoolFull
+	}
+	last := s.segments[len(s.segments)-1]
+	if last.size > 0 && last.size+size > s.config.SegmentSize {
+		if err := s.rotate(); err != nil {
+			return nil, err
+		}
+		last = s.segments[len(s.segments)-1]
+	}
+	if _, err := s.active.Write(record); err != nil {
+		// Cut off the partial record so later appends stay readable
+		_ = s.active.Truncate(last.size)
+		return nil, err
+	}
+	if !s.config.NoSync {
+		if err := s.active.Sync(); err != nil {
+			return nil, err
+		}
+	}
+	last.size += size
+	last.records++
+	last.resources += count
+	s.size += size
+	if s.pending == 0 {
+		s.drained = make(chan struct{})
+	}
+	s.pending++
+	atomic.AddInt64(&s.appended, int64(count))
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return &StoreResponse{}, nil
+}
+
+// Flush waits until all spooled batches are delivered or ctx expires
+func (s *Spool) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	drained := s.drained
+	s.mu.Unlock()
+	select {
+	case <-drained:
+		return nil
+	case <-s.stopped:
+		return ErrSpoolClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting batches and waits until spooled batches are delivered
+// or ctx expires. Batches which are not delivered stay on disk for the next run
+func (s *Spool) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	var err error
+	select {
+	case <-s.stopped:
+	case <-ctx.Done():
+		err = ctx.Err()
+		s.cancel()
+		<-s.stopped
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active != nil {
+		if closeErr := s.active.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		s.active = nil
+	}
+	return err
+}
+
+// Stats returns the current state of the spool
+func (s *Spool) Stats() SpoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SpoolStats{
+		Segments:  len(s.segments),
+		Size:      s.size,
+		Pending:   s.pending,
+		Appended:  atomic.LoadInt64(&s.appended),
+		Delivered: atomic.LoadInt64(&s.delivered),
+		Dropped:   atomic.LoadInt64(&s.dropped),
+		Rejected:  atomic.LoadInt64(&s.rejected),
+		Retries:   atomic.LoadInt64(&s.retries),
+	}
+}
+
+// open scans the segment files, truncates torn records and restores the cursor
+func (s *Spool) open() error {
+	entries, err := ioutil.ReadDir(s.config.Dir)
+	if err != nil {
+		return err
+	}
+	var ids []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, spoolSegmentPrefix) || !strings.HasSuffix(name, spoolSegmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, spoolSegmentPrefix), spoolSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	if data, err := ioutil.ReadFile(filepath.Join(s.config.Dir, spoolCursorFile)); err == nil {
+		if err := json.Unmarshal(data, &s.cursor); err != nil {
+			return fmt.Errorf("spool cursor: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, id := range ids {
+		if id < s.cursor.Segment {
+			// Fully delivered in an earlier run
+			if err := os.Remove(s.segmentPath(id)); err != nil {
+				return err
+			}
+			continue
+		}
+		segment, err := s.scanSegment(id)
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, segment)
+		s.size += segment.size
+	}
+	if len(s.segments) == 0 || s.segments[0].id != s.cursor.Segment {
+		s.cursor = spoolCursor{}
+		if len(s.segments) > 0 {
+			s.cursor.Segment = s.segments[0].id
+		}
+	}
+	if len(s.segments) == 0 {
+		id := s.cursor.Segment
+		if id == 0 {
+			id = 1
+		}
+		s.cursor.Segment = id
+		s.segments = append(s.segments, &spoolSegment{id: id})
+	}
+	if err := s.countCursor(); err != nil {
+		return err
+	}
+	for _, segment := range s.segments {
+		s.pending += segment.records
+	}
+	s.pending -= s.cursor.records
+
+	last := s.segments[len(s.segments)-1]
+	s.active, err = os.OpenFile(s.segmentPath(last.id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	return err
+}
+
+// scanSegment counts the valid records of a segment and truncates anything after them
+func (s *Spool) scanSegment(id uint64) (*spoolSegment, error) {
+	path := s.segmentPath(id)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	segment := &spoolSegment{id: id}
+	r := bufio.NewReader(f)
+	for {
+		batch, n, err := readSpoolRecord(r, s.config.MaxSize)
+		if err != nil {
+			break
+		}
+		segment.size += n
+		segment.records++
+		segment.resources += len(batch)
+	}
+	info, err := f.Stat()
+	_ = f.Close()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > segment.size {
+		if err := os.Truncate(path, segment.size); err != nil {
+			return nil, err
+		}
+	}
+	return segment, nil
+}
+
+// countCursor determines how many records and resources precede the cursor offset
+func (s *Spool) countCursor() error {
+	if s.cursor.Offset == 0 {
+		return nil
+	}
+	f, err := os.Open(s.segmentPath(s.cursor.Segment))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var offset int64
+	for offset < s.cursor.Offset {
+		batch, n, err := readSpoolRecord(r, s.config.MaxSize)
+		if err != nil {
+			break
+		}
+		offset += n
+		s.cursor.records++
+		s.cursor.resources += len(batch)
+	}
+	s.cursor.Offset = offset
+	return nil
+}
+
+func (s *Spool) segmentPath(id uint64) string {
+	return filepath.Join(s.config.Dir, fmt.Sprintf("%s%020d%s", spoolSegmentPrefix, id, spoolSegmentSuffix))
+}
+
+// rotate starts a new active segment. Callers must hold s.mu
+func (s *Spool) rotate() error {
+	last := s.segments[len(s.segments)-1]
+	next := &spoolSegment{id: last.id + 1}
+	f, err := os.OpenFile(s.segmentPath(next.id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if err := s.active.Close(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.active = f
+	s.segments = append(s.segments, next)
+	return nil
+}
+
+// dropOldest discards the segment at the cursor. Callers must hold s.mu
+func (s *Spool) dropOldest() error {
+	oldest := s.segments[0]
+	if err := os.Remove(s.segmentPath(oldest.id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lost := oldest.resources - s.cursor.resources
+	s.pending -= oldest.records - s.cursor.records
+	s.size -= oldest.size
+	s.segments = s.segments[1:]
+	s.generation++
+	s.markDrained()
+	atomic.AddInt64(&s.dropped, int64(lost))
+	if s.config.OnError != nil && lost > 0 {
+		s.config.OnError(nil, fmt.Errorf("%w: dropped %d resources", ErrSpoolFull, lost))
+	}
+	return s.moveCursor(spoolCursor{Segment: s.segments[0].id})
+}
+
+// moveCursor persists the cursor. Callers must hold s.mu
+func (s *Spool) moveCursor(cursor spoolCursor) error {
+	s.cursor = cursor
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.config.Dir, spoolCursorFile)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if !s.config.NoSync {
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *Spool) run() {
+	defer close(s.stopped)
+	for {
+		s.mu.Lock()
+		segment := s.segments[0]
+		cursor := s.cursor
+		generation := s.generation
+		if cursor.Offset >= segment.size && len(s.segments) > 1 {
+			// Segment is fully delivered
+			_ = os.Remove(s.segmentPath(segment.id))
+			s.size -= segment.size
+			s.segments = s.segments[1:]
+			_ = s.moveCursor(spoolCursor{Segment: s.segments[0].id})
+			s.mu.Unlock()
+			continue
+		}
+		if s.pending == 0 {
+			closing := s.closing
+			s.mu.Unlock()
+			if closing {
+				return
+			}
+			select {
+			case <-s.wake:
+				continue
+			case <-s.ctx.Done():
+				return
+			}
+		}
+		s.mu.Unlock()
+
+		batch, n, err := s.readAt(segment.id, cursor.Offset)
+		if err != nil {
+			// Unreadable records are skipped up to the end of the segment
+			s.mu.Lock()
+			if generation == s.generation && s.cursor.Segment == segment.id {
+				s.pending -= segment.records - s.cursor.records
+				s.cursor.records = segment.records
+				s.cursor.resources = segment.resources
+				s.cursor.Offset = segment.size
+				_ = s.moveCursor(s.cursor)
+				s.markDrained()
+			}
+			s.mu.Unlock()
+			if s.config.OnError != nil {
+				s.config.OnError(nil, err)
+			}
+			continue
+		}
+		if !s.deliver(batch) {
+			return
+		}
+		s.mu.Lock()
+		if generation == s.generation {
+			next := s.cursor
+			next.Offset += n
+			next.records++
+			next.resources += len(batch)
+			_ = s.moveCursor(next)
+			s.pending--
+			s.markDrained()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// markDrained signals Flush callers when nothing is pending. Callers must hold s.mu
+func (s *Spool) markDrained() {
+	if s.pending > 0 {
+		return
+	}
+	select {
+	case <-s.drained:
+	default:
+		close(s.drained)
+	}
+}
+
+func (s *Spool) readAt(id uint64, offset int64) ([]Resource, int64, error) {
+	f, err := os.Open(s.segmentPath(id))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	return readSpoolRecord(bufio.NewReader(f), s.config.MaxSize)
+}
+
+// deliver stores a batch, retrying transient failures until it succeeds, is
+// rejected or the spool is stopped. It returns false when the spool is stopped
+func (s *Spool) deliver(batch []Resource) bool {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = s.config.InitialBackoff
+	b.MaxInterval = s.config.MaxBackoff
+	b.MaxElapsedTime = 0
+	for len(batch) > 0 {
+		resp, err := s.storer.StoreResources(batch, len(batch))
+		if err == nil && resp != nil && resp.Response != nil && resp.StatusCode >= http.StatusBadRequest {
+			err = ErrResponseError
+		}
+		switch {
+		case err == nil:
+			atomic.AddInt64(&s.delivered, int64(len(batch)))
+			return true
+		case isTransient(resp, err):
+			atomic.AddInt64(&s.retries, 1)
+			select {
+			case <-time.After(b.NextBackOff()):
+			case <-s.ctx.Done():
+				return false
+			}
+		case errors.Is(err, ErrBatchErrors) && resp != nil && len(resp.Failed) > 0:
+			var rejected, remaining []Resource
+			for i, r := range batch {
+				if failed, ok := resp.Failed[i]; ok {
+					rejected = append(rejected, failed)
+					continue
+				}
+				remaining = append(remaining, r)
+			}
+			s.reject(rejected, err)
+			batch = remaining
+		default:
+			s.reject(batch, err)
+			return true
+		}
+	}
+	return true
+}
+
+func (s *Spool) reject(resources []Resource, err error) {
+	atomic.AddInt64(&s.rejected, int64(len(resources)))
+	if s.config.OnError != nil && len(resources) > 0 {
+		s.config.OnError(resources, err)
+	}
+}
+
+// readSpoolRecord reads a length prefixed, checksummed batch
+func readSpoolRecord(r io.Reader, maxSize int64) ([]Resource, int64, error) {
+	var header [spoolHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, ErrSpoolCorrupt
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if int64(length) > maxSize {
+		return nil, 0, ErrSpoolCorrupt
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, ErrSpoolCorrupt
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, ErrSpoolCorrupt
+	}
+	var batch []Resource
+	if err := json.Unmarshal(payload, &batch); err != nil {
+		return nil, 0, ErrSpoolCorrupt
+	}
+	return batch, int64(spoolHeaderSize + len(payload)), nil
+}
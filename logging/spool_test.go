@@ -0,0 +1,201 @@
+package logging
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func spoolConfig(t *testing.T) SpoolConfig {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return SpoolConfig{
+		Dir:            dir,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}
+}
+
+func unavailable(int, []Resource) (*StoreResponse, error) {
+	return &StoreResponse{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}, ErrResponseError
+}
+
+func spoolIDs(calls [][]Resource) []string {
+	var ids []string
+	for _, batch := range calls {
+		for _, r := range batch {
+			ids = append(ids, r.ID)
+		}
+	}
+	return ids
+}
+
+func TestSpoolDelivers(t *testing.T) {
+	config := spoolConfig(t)
+	config.SegmentSize = 1
+	storer := &fakeStorer{}
+	spool, err := NewSpool(storer, config)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		_, err := spool.StoreResources([]Resource{shipperResource(id)}, 1)
+		assert.Nil(t, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, spool.Flush(ctx))
+	assert.Equal(t, []string{"1", "2", "3"}, spoolIDs(storer.calls()))
+
+	stats := spool.Stats()
+	assert.Equal(t, int64(3), stats.Appended)
+	assert.Equal(t, int64(3), stats.Delivered)
+	assert.Equal(t, 0, stats.Pending)
+	assert.Nil(t, spool.Close(ctx))
+
+	_, err = spool.StoreResources([]Resource{shipperResource("4")}, 1)
+	assert.Equal(t, ErrSpoolClosed, err)
+
+	segments, _ := filepath.Glob(filepath.Join(config.Dir, spoolSegmentPrefix+"*"))
+	assert.Len(t, segments, 1)
+}
+
+func TestSpoolReplay(t *testing.T) {
+	config := spoolConfig(t)
+	config.SegmentSize = 1
+	down := &fakeStorer{store: unavailable}
+	spool, err := NewSpool(down, config)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		_, err := spool.StoreResources([]Resource{shipperResource(id)}, 1)
+		assert.Nil(t, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, spool.Close(ctx))
+	assert.True(t, spool.Stats().Retries > 0)
+
+	// Simulate a crash in the middle of an append
+	segments, _ := filepath.Glob(filepath.Join(config.Dir, spoolSegmentPrefix+"*"))
+	if !assert.Len(t, segments, 3) {
+		return
+	}
+	f, err := os.OpenFile(segments[2], os.O_WRONLY|os.O_APPEND, 0600)
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, _ = f.Write([]byte{0, 0, 1, 0, 1, 2})
+	_ = f.Close()
+
+	up := &fakeStorer{}
+	spool, err = NewSpool(up, config)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, 3, spool.Stats().Pending)
+	_, err = spool.StoreResources([]Resource{shipperResource("4")}, 1)
+	assert.Nil(t, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, spool.Flush(ctx))
+	assert.Equal(t, []string{"1", "2", "3", "4"}, spoolIDs(up.calls()))
+	assert.Nil(t, spool.Close(ctx))
+}
+
+func TestSpoolOverflow(t *testing.T) {
+	config := spoolConfig(t)
+	config.MaxSize = 4096
+	config.SegmentSize = 1
+	spool, err := NewSpool(&fakeStorer{store: unavailable}, config)
+	if !assert.Nil(t, err) {
+		return
+	}
+	var full bool
+	for i := 0; i < 100 && !full; i++ {
+		_, err = spool.StoreResources([]Resource{shipperResource("1")}, 1)
+		full = err == ErrSpoolFull
+	}
+	assert.True(t, full)
+	assert.True(t, spool.Stats().Size <= config.MaxSize)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_ = spool.Close(ctx)
+
+	config = spoolConfig(t)
+	config.MaxSize = 4096
+	config.SegmentSize = 1
+	config.Overflow = SpoolDropOldest
+	spool, err = NewSpool(&fakeStorer{store: unavailable}, config)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for i := 0; i < 100; i++ {
+		_, err = spool.StoreResources([]Resource{shipperResource("1")}, 1)
+		assert.Nil(t, err)
+	}
+	stats := spool.Stats()
+	assert.True(t, stats.Dropped > 0)
+	assert.True(t, stats.Size <= config.MaxSize)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_ = spool.Close(ctx)
+}
+
+func TestSpoolRejectedEntries(t *testing.T) {
+	var mu sync.Mutex
+	var rejected []Resource
+	config := spoolConfig(t)
+	config.OnError = func(failed []Resource, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		rejected = append(rejected, failed...)
+	}
+	storer := &fakeStorer{
+		store: func(call int, msgs []Resource) (*StoreResponse, error) {
+			if call == 0 {
+				return &StoreResponse{Failed: map[int]Resource{1: msgs[1]}}, ErrBatchErrors
+			}
+			return &StoreResponse{}, nil
+		},
+	}
+	spool, err := NewSpool(storer, config)
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = spool.StoreResources([]Resource{shipperResource("1"), shipperResource("bad"), shipperResource("2")}, 3)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, spool.Close(ctx))
+
+	stats := spool.Stats()
+	assert.Equal(t, int64(2), stats.Delivered)
+	assert.Equal(t, int64(1), stats.Rejected)
+	if assert.Len(t, rejected, 1) {
+		assert.Equal(t, "bad", rejected[0].ID)
+	}
+	calls := storer.calls()
+	if assert.Len(t, calls, 2) {
+		assert.Equal(t, []string{"1", "2"}, spoolIDs(calls[1:]))
+	}
+}
+
+func TestSpoolMissingConfig(t *testing.T) {
+	_, err := NewSpool(nil, SpoolConfig{})
+	assert.Equal(t, ErrMissingStorer, err)
+	_, err = NewSpool(&fakeStorer{}, SpoolConfig{})
+	assert.Equal(t, ErrMissingSpoolDir, err)
+}
@@ -0,0 +1,436 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// ValidationMode determines how a Validator handles rule violations
+type ValidationMode int
+
+const (
+	// ValidationReject reports violations and never changes a resource
+	ValidationReject ValidationMode = iota
+	// ValidationSanitize repairs characters, formats and missing generated fields.
+	// Length violations are still reported
+	ValidationSanitize
+	// ValidationTruncate sanitizes and also truncates fields which are too long
+	ValidationTruncate
+)
+
+// Validation rules
+const (
+	RuleRequired   = "required"
+	RuleMaxLength  = "maxLength"
+	RuleCharacters = "characters"
+	RuleFormat     = "format"
+	RuleEnum       = "enum"
+	RuleMaxSize    = "maxSize"
+	// RuleRemoved is used when a payload is too large to be truncated and is dropped
+	RuleRemoved = "removed"
+)
+
+// CustomTruncatedKey lists the keys of a Custom payload which were shortened
+// or dropped by ValidationTruncate
+const CustomTruncatedKey = "_truncated"
+
+const (
+	defaultMaxFieldLength = 256
+	defaultMaxMessageSize = 1024 * 1024
+	defaultMaxCustomSize  = 10 * 1024
+	defaultReplacement    = "_"
+)
+
+// ErrValidation is wrapped by errors returned from ValidationReport.Error
+var ErrValidation = errors.New("resource validation failed")
+
+var (
+	severities = []string{SeverityDebug, SeverityInfo, SeverityWarning, SeverityError, SeverityFatal}
+
+	severityAliases = map[string]string{
+		"TRACE":         SeverityDebug,
+		"VERBOSE":       SeverityDebug,
+		"INFORMATION":   SeverityInfo,
+		"INFORMATIONAL": SeverityInfo,
+		"NOTICE":        SeverityInfo,
+		"WARN":          SeverityWarning,
+		"ERR":           SeverityError,
+		"CRITICAL":      SeverityFatal,
+		"PANIC":         SeverityFatal,
+	}
+
+	logTimeRegexp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d{1,9})?(Z|[+-]\d{2}:\d{2})$`)
+
+	logTimeLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999Z0700",
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+		time.RFC1123Z,
+		time.RFC1123,
+	}
+
+	// restrictedCharacters are rejected by HSDP in the applicationVersion field
+	restrictedCharacters = "&+;=?@|<>()"
+)
+
+// ValidationViolation describes a rule a resource field does not meet
+type ValidationViolation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationChange records a modification made by a Validator
+type ValidationChange struct {
+	Field  string `json:"field"`
+	Rule   string `json:"rule"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// ValidationReport is the outcome of validating a single resource
+type ValidationReport struct {
+	Violations []ValidationViolation `json:"violations,omitempty"`
+	Changes    []ValidationChange    `json:"changes,omitempty"`
+}
+
+// Valid returns true if no violations remain
+func (r ValidationReport) Valid() bool {
+	return len(r.Violations) == 0
+}
+
+// Error returns nil for a valid report or an error wrapping ErrValidation listing the violations
+func (r ValidationReport) Error() error {
+	if r.Valid() {
+		return nil
+	}
+	messages := make([]string, 0, len(r.Violations))
+	for _, v := range r.Violations {
+		messages = append(messages, v.Message)
+	}
+	return fmt.Errorf("%w: %s", ErrValidation, strings.Join(messages, "; "))
+}
+
+// Validator checks resources against the HSDP LogEvent rules
+type Validator struct {
+	Mode ValidationMode
+	// Replacement is used for characters which are not allowed
+	Replacement string
+	// MaxFieldLength is the maximum length in bytes of the identifying string fields
+	MaxFieldLength int
+	// MaxMessageSize is the maximum size in bytes of LogData.Message
+	MaxMessageSize int
+	// MaxCustomSize is the maximum size in bytes of the Custom payload
+	MaxCustomSize int
+	// OnChange, when set, is called for every resource the validator changed
+	OnChange func(resource Resource, changes []ValidationChange)
+}
+
+// NewValidator returns a validator with the default HSDP limits
+func NewValidator(mode ValidationMode) *Validator {
+	return &Validator{
+		Mode:           mode,
+		Replacement:    defaultReplacement,
+		MaxFieldLength: defaultMaxFieldLength,
+		MaxMessageSize: defaultMaxMessageSize,
+		MaxCustomSize:  defaultMaxCustomSize,
+	}
+}
+
+type validatedField struct {
+	name       string
+	value      func(r *Resource) *string
+	required   bool
+	restricted bool
+	generate   func() string
+}
+
+var validatedFields = []validatedField{
+	{name: "resourceType", value: func(r *Resource) *string { return &r.ResourceType }, required: true, generate: func() string { return "LogEvent" }},
+	{name: "id", value: func(r *Resource) *string { return &r.ID }, required: true, generate: func() string { return uuid.New().String() }},
+	{name: "applicationName", value: func(r *Resource) *string { return &r.ApplicationName }, required: true},
+	{name: "eventId", value: func(r *Resource) *string { return &r.EventID }, required: true},
+	{name: "category", value: func(r *Resource) *string { return &r.Category }, required: true},
+	{name: "component", value: func(r *Resource) *string { return &r.Component }, required: true},
+	{name: "transactionId", value: func(r *Resource) *string { return &r.TransactionID }, required: true, generate: func() string { return uuid.New().String() }},
+	{name: "serviceName", value: func(r *Resource) *string { return &r.ServiceName }, required: true},
+	{name: "applicationInstance", value: func(r *Resource) *string { return &r.ApplicationInstance }, required: true},
+	{name: "applicationVersion", value: func(r *Resource) *string { return &r.ApplicationVersion }, required: true, restricted: true},
+	{name: "originatingUser", value: func(r *Resource) *string { return &r.OriginatingUser }, required: true},
+	{name: "serverName", value: func(r *Resource) *string { return &r.ServerName }, required: true},
+	{name: "traceId", value: func(r *Resource) *string { return &r.TraceID }},
+	{name: "spanId", value: func(r *Resource) *string { return &r.SpanID }},
+}
+
+// Validate checks the resource and, depending on the mode, repairs it in place.
+// All changes are listed in the report and passed to OnChange
+func (v *Validator) Validate(resource *Resource) ValidationReport {
+	var report ValidationReport
+	repair := v.Mode == ValidationSanitize || v.Mode == ValidationTruncate
+	truncate := v.Mode == ValidationTruncate
+	replacement := v.Replacement
+	if replacement == "" {
+		replacement = defaultReplacement
+	}
+
+	change := func(field, rule string, target *string, after string) {
+		report.Changes = append(report.Changes, ValidationChange{Field: field, Rule: rule, Before: *target, After: after})
+		*target = after
+	}
+	violate := func(field, rule, format string, args ...interface{}) {
+		report.Violations = append(report.Violations, ValidationViolation{
+			Field:   field,
+			Rule:    rule,
+			Message: field + " " + fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, f := range validatedFields {
+		value := f.value(resource)
+		if *value == "" {
+			if f.required && repair && f.generate != nil {
+				change(f.name, RuleRequired, value, f.generate())
+			} else if f.required {
+				violate(f.name, RuleRequired, "is blank")
+			}
+			continue
+		}
+		if bad := invalidCharacters(*value, f.restricted); bad != "" {
+			if repair {
+				change(f.name, RuleCharacters, value, replaceCharacters(*value, f.restricted, replacement))
+			} else {
+				violate(f.name, RuleCharacters, "contains characters which are not allowed: %q", bad)
+			}
+		}
+		if v.MaxFieldLength > 0 && len(*value) > v.MaxFieldLength {
+			if truncate {
+				change(f.name, RuleMaxLength, value, truncateUTF8(*value, v.MaxFieldLength))
+			} else {
+				violate(f.name, RuleMaxLength, "exceeds %d bytes", v.MaxFieldLength)
+			}
+		}
+	}
+	if resource.ResourceType != "" && resource.ResourceType != "LogEvent" {
+		if repair {
+			change("resourceType", RuleEnum, &resource.ResourceType, "LogEvent")
+		} else {
+			violate("resourceType", RuleEnum, "must be LogEvent")
+		}
+	}
+
+	v.validateLogTime(resource, repair, change, violate)
+	v.validateSeverity(resource, repair, change, violate)
+
+	if resource.LogData.Message == "" {
+		violate("logData.message", RuleRequired, "is blank")
+	} else if v.MaxMessageSize > 0 && len(resource.LogData.Message) > v.MaxMessageSize {
+		if truncate {
+			change("logData.message", RuleMaxSize, &resource.LogData.Message, truncateUTF8(resource.LogData.Message, v.MaxMessageSize))
+		} else {
+			violate("logData.message", RuleMaxSize, "exceeds %d bytes", v.MaxMessageSize)
+		}
+	}
+
+	if len(resource.Custom) > 0 {
+		v.validateCustom(resource, repair, truncate, &report, violate)
+	}
+
+	if len(report.Changes) > 0 && v.OnChange != nil {
+		v.OnChange(*resource, report.Changes)
+	}
+	return report
+}
+
+// validateCustom checks that Custom is a JSON object within MaxCustomSize. The
+// character sequences HSDP refuses are escaped before the size is checked
+func (v *Validator) validateCustom(resource *Resource, repair, truncate bool, report *ValidationReport, violate func(string, string, string, ...interface{})) {
+	var custom map[string]interface{}
+	if err := json.Unmarshal(resource.Custom, &custom); err != nil {
+		violate("custom", RuleFormat, "is not a JSON object: %v", err)
+		return
+	}
+	if escaped := escapeCustom(string(resource.Custom)); escaped != string(resource.Custom) {
+		if !repair {
+			violate("custom", RuleCharacters, "contains character sequences which are not allowed")
+			return
+		}
+		report.Changes = append(report.Changes, ValidationChange{Field: "custom", Rule: RuleCharacters, Before: string(resource.Custom), After: escaped})
+		resource.Custom = []byte(escaped)
+	}
+	if v.MaxCustomSize > 0 && len(resource.Custom) > v.MaxCustomSize {
+		if !truncate {
+			violate("custom", RuleMaxSize, "exceeds %d bytes", v.MaxCustomSize)
+			return
+		}
+		before := string(resource.Custom)
+		if truncated, ok := truncateCustom(custom, v.MaxCustomSize); ok {
+			report.Changes = append(report.Changes, ValidationChange{Field: "custom", Rule: RuleMaxSize, Before: before, After: string(truncated)})
+			resource.Custom = truncated
+			return
+		}
+		report.Changes = append(report.Changes, ValidationChange{Field: "custom", Rule: RuleRemoved, Before: before})
+		resource.Custom = nil
+	}
+}
+
+// truncateCustom shortens or drops the largest top-level values of custom until
+// the escaped payload fits in max bytes. The affected keys are listed under
+// CustomTruncatedKey. It returns false when not even the marker fits
+func truncateCustom(custom map[string]interface{}, max int) (json.RawMessage, bool) {
+	var truncated []string
+	mark := func(key string) {
+		for _, k := range truncated {
+			if k == key {
+				return
+			}
+		}
+		truncated = append(truncated, key)
+	}
+	for {
+		if len(truncated) > 0 {
+			custom[CustomTruncatedKey] = truncated
+		}
+		data, err := encodeCustom(custom)
+		if err != nil {
+			return nil, false
+		}
+		if len(data) <= max {
+			return data, true
+		}
+		key, size := "", 0
+		for k, value := range custom {
+			if k == CustomTruncatedKey {
+				continue
+			}
+			encoded, _ := encodeCustom(value)
+			if len(encoded) > size || (len(encoded) == size && k < key) {
+				key, size = k, len(encoded)
+			}
+		}
+		if key == "" {
+			return nil, false
+		}
+		mark(key)
+		excess := len(data) - max
+		if s, ok := custom[key].(string); ok && len(s) > excess {
+			custom[key] = truncateUTF8(s, len(s)-excess)
+			continue
+		}
+		delete(custom, key)
+	}
+}
+
+// encodeCustom marshals value without HTML escaping and escapes the sequences HSDP refuses
+func encodeCustom(value interface{}) ([]byte, error) {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return []byte(escapeCustom(strings.TrimSuffix(b.String(), "\n"))), nil
+}
+
+func (v *Validator) validateLogTime(resource *Resource, repair bool, change func(string, string, *string, string), violate func(string, string, string, ...interface{})) {
+	if resource.LogTime == "" {
+		violate("logTime", RuleRequired, "is blank")
+		return
+	}
+	if logTimeRegexp.MatchString(resource.LogTime) {
+		if _, err := time.Parse(time.RFC3339Nano, resource.LogTime); err == nil {
+			return
+		}
+	}
+	if repair {
+		for _, layout := range logTimeLayouts {
+			if t, err := time.Parse(layout, resource.LogTime); err == nil {
+				change("logTime", RuleFormat, &resource.LogTime, t.Format(TimeFormat))
+				return
+			}
+		}
+	}
+	violate("logTime", RuleFormat, "is not a valid timestamp: %q", resource.LogTime)
+}
+
+func (v *Validator) validateSeverity(resource *Resource, repair bool, change func(string, string, *string, string), violate func(string, string, string, ...interface{})) {
+	if resource.Severity == "" {
+		violate("severity", RuleRequired, "is blank")
+		return
+	}
+	// Severities match in any case, the repairing modes canonicalise them
+	for _, s := range severities {
+		if strings.EqualFold(resource.Severity, s) {
+			if repair && resource.Severity != s {
+				change("severity", RuleEnum, &resource.Severity, s)
+			}
+			return
+		}
+	}
+	normalized := strings.ToUpper(strings.TrimSpace(resource.Severity))
+	if alias, ok := severityAliases[normalized]; ok {
+		normalized = alias
+	}
+	for _, s := range severities {
+		if normalized == s && repair {
+			change("severity", RuleEnum, &resource.Severity, s)
+			return
+		}
+	}
+	violate("severity", RuleEnum, "must be one of %s", strings.Join(severities, ", "))
+}
+
+// isInvalidRune reports control characters, invalid UTF-8 and, for restricted fields, the characters HSDP rejects
+func isInvalidRune(value string, i int, r rune, restricted bool) bool {
+	if r == utf8.RuneError {
+		if _, size := utf8.DecodeRuneInString(value[i:]); size == 1 {
+			return true
+		}
+	}
+	if unicode.IsControl(r) {
+		return true
+	}
+	return restricted && strings.ContainsRune(restrictedCharacters, r)
+}
+
+func invalidCharacters(value string, restricted bool) string {
+	var bad strings.Builder
+	for i, r := range value {
+		if isInvalidRune(value, i, r, restricted) {
+			bad.WriteRune(r)
+		}
+	}
+	return bad.String()
+}
+
+func replaceCharacters(value string, restricted bool, replacement string) string {
+	var b strings.Builder
+	for i, r := range value {
+		if isInvalidRune(value, i, r, restricted) {
+			b.WriteString(replacement)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// truncateUTF8 shortens s to at most max bytes without splitting a character
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
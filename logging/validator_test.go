@@ -0,0 +1,236 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dirtyResource() Resource {
+	r := validResource
+	r.ApplicationVersion = "1.0.0+build(42)"
+	r.Severity = "warn"
+	r.LogTime = "2017-10-15 01:53:20"
+	r.TransactionID = ""
+	r.Component = "PHS\x07"
+	return r
+}
+
+func changedFields(changes []ValidationChange) []string {
+	var fields []string
+	for _, c := range changes {
+		fields = append(fields, c.Field)
+	}
+	return fields
+}
+
+func TestValidatorReject(t *testing.T) {
+	validator := NewValidator(ValidationReject)
+
+	r := validResource
+	report := validator.Validate(&r)
+	assert.True(t, report.Valid())
+	assert.Nil(t, report.Error())
+	assert.Empty(t, report.Changes)
+
+	r = dirtyResource()
+	before := r
+	report = validator.Validate(&r)
+	assert.False(t, report.Valid())
+	assert.Empty(t, report.Changes)
+	assert.Equal(t, before, r)
+	assert.True(t, errors.Is(report.Error(), ErrValidation))
+
+	var rules []string
+	for _, v := range report.Violations {
+		rules = append(rules, v.Field+":"+v.Rule)
+	}
+	assert.ElementsMatch(t, []string{
+		"transactionId:required",
+		"component:characters",
+		"applicationVersion:characters",
+		"logTime:format",
+		"severity:enum",
+	}, rules)
+}
+
+func TestValidatorSanitize(t *testing.T) {
+	var notified []ValidationChange
+	validator := NewValidator(ValidationSanitize)
+	validator.OnChange = func(resource Resource, changes []ValidationChange) {
+		notified = changes
+	}
+
+	r := dirtyResource()
+	report := validator.Validate(&r)
+	assert.True(t, report.Valid(), "%v", report.Error())
+	assert.Equal(t, "1.0.0_build_42_", r.ApplicationVersion)
+	assert.Equal(t, SeverityWarning, r.Severity)
+	assert.Equal(t, "2017-10-15T01:53:20.000Z", r.LogTime)
+	assert.Equal(t, "PHS_", r.Component)
+	assert.NotEmpty(t, r.TransactionID)
+	assert.ElementsMatch(t, []string{"transactionId", "component", "applicationVersion", "logTime", "severity"}, changedFields(report.Changes))
+	assert.Equal(t, report.Changes, notified)
+	for _, c := range report.Changes {
+		if c.Field == "applicationVersion" {
+			assert.Equal(t, "1.0.0+build(42)", c.Before)
+		}
+	}
+
+	r = validResource
+	r.LogData.Message = strings.Repeat("x", 20)
+	validator.MaxMessageSize = 10
+	report = validator.Validate(&r)
+	assert.False(t, report.Valid())
+	assert.Equal(t, RuleMaxSize, report.Violations[0].Rule)
+
+	r = validResource
+	r.Severity = "SOMETIMES"
+	report = validator.Validate(&r)
+	assert.False(t, report.Valid())
+	assert.Equal(t, "SOMETIMES", r.Severity)
+}
+
+func TestValidatorTruncate(t *testing.T) {
+	validator := NewValidator(ValidationTruncate)
+	validator.MaxFieldLength = 8
+	validator.MaxMessageSize = 5
+	validator.MaxCustomSize = 10
+
+	r := validResource
+	r.ServerName = "héééééé.example.com"
+	r.LogData.Message = "hello world"
+	r.Custom = []byte(`{"field":"a long value"}`)
+	report := validator.Validate(&r)
+	assert.True(t, report.Valid(), "%v", report.Error())
+	assert.Equal(t, "hello", r.LogData.Message)
+	assert.Equal(t, "hééé", r.ServerName)
+	assert.Nil(t, r.Custom, "not even the marker fits in 10 bytes")
+	for _, c := range report.Changes {
+		if c.Field == "custom" {
+			assert.Equal(t, RuleRemoved, c.Rule)
+			assert.Equal(t, `{"field":"a long value"}`, c.Before)
+			assert.Empty(t, c.After)
+		}
+	}
+}
+
+func TestValidatorTruncateCustom(t *testing.T) {
+	validator := NewValidator(ValidationTruncate)
+	validator.MaxCustomSize = 120
+
+	r := validResource
+	r.Custom = []byte(`{"id":"42","note":"` + strings.Repeat("n", 100) + `","nested":{"list":[1,2,3]},"query":"a&b"}`)
+	before := string(r.Custom)
+	report := validator.Validate(&r)
+	assert.True(t, report.Valid(), "%v", report.Error())
+	assert.LessOrEqual(t, len(r.Custom), 120)
+
+	var custom map[string]interface{}
+	if !assert.Nil(t, json.Unmarshal(r.Custom, &custom)) {
+		return
+	}
+	assert.Equal(t, "42", custom["id"])
+	assert.Equal(t, "a[amp]b", custom["query"])
+	assert.Equal(t, []interface{}{"note"}, custom[CustomTruncatedKey])
+	assert.True(t, strings.HasPrefix(custom["note"].(string), "nnn"))
+	assert.Less(t, len(custom["note"].(string)), 100)
+	assert.NotNil(t, custom["nested"])
+
+	var change *ValidationChange
+	for i := range report.Changes {
+		if report.Changes[i].Rule == RuleMaxSize {
+			change = &report.Changes[i]
+		}
+	}
+	if assert.NotNil(t, change) {
+		assert.Equal(t, "custom", change.Field)
+		assert.Equal(t, string(r.Custom), change.After)
+		assert.NotEqual(t, before, change.Before)
+	}
+}
+
+func TestValidatorSeverityCase(t *testing.T) {
+	for _, severity := range []string{"Info", "info", "Warning", "eRRoR"} {
+		r := validResource
+		r.Severity = severity
+		report := NewValidator(ValidationReject).Validate(&r)
+		assert.True(t, report.Valid(), "%s: %v", severity, report.Error())
+		assert.Equal(t, severity, r.Severity)
+
+		report = NewValidator(ValidationSanitize).Validate(&r)
+		assert.True(t, report.Valid())
+		assert.Equal(t, strings.ToUpper(severity), r.Severity)
+		if assert.Len(t, report.Changes, 1) {
+			assert.Equal(t, "severity", report.Changes[0].Field)
+			assert.Equal(t, severity, report.Changes[0].Before)
+		}
+	}
+
+	r := validResource
+	r.Severity = "Warn"
+	report := NewValidator(ValidationReject).Validate(&r)
+	assert.False(t, report.Valid(), "aliases are only repaired")
+}
+
+func TestStoreResourcesWithValidator(t *testing.T) {
+	teardown, err := setup(t, &Config{
+		SharedKey:    sharedKey,
+		SharedSecret: sharedSecret,
+		ProductKey:   productKey,
+		BaseURL:      "http://foo",
+		Validator:    NewValidator(ValidationSanitize),
+	}, "POST", http.StatusCreated, "")
+	if teardown != nil {
+		defer teardown()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.StoreResources([]Resource{validResource, dirtyResource()}, 2)
+	if !assert.Nil(t, err) || !assert.NotNil(t, resp) {
+		return
+	}
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.NotContains(t, resp.Changes, 0)
+	assert.Len(t, resp.Changes[1], 5)
+
+	client.config.Validator = NewValidator(ValidationReject)
+	resp, err = client.StoreResources([]Resource{validResource, dirtyResource()}, 2)
+	assert.Equal(t, ErrBatchErrors, err)
+	if assert.NotNil(t, resp) && assert.Contains(t, resp.Failed, 1) {
+		assert.True(t, errors.Is(resp.Failed[1].Error, ErrValidation))
+	}
+}
+
+func TestValidatorCustomCharacters(t *testing.T) {
+	r := validResource
+	r.Custom = []byte(`{"query":"a=1&b=2;"}`)
+	report := NewValidator(ValidationReject).Validate(&r)
+	if assert.Len(t, report.Violations, 1) {
+		assert.Equal(t, RuleCharacters, report.Violations[0].Rule)
+	}
+	assert.Equal(t, `{"query":"a=1&b=2;"}`, string(r.Custom))
+
+	report = NewValidator(ValidationSanitize).Validate(&r)
+	assert.True(t, report.Valid())
+	if assert.Len(t, report.Changes, 1) {
+		assert.Equal(t, "custom", report.Changes[0].Field)
+		assert.Equal(t, `{"query":"a=1&b=2;"}`, report.Changes[0].Before)
+	}
+	assert.Equal(t, `{"query":"a=1[amp]b=2[sc]"}`, string(r.Custom))
+
+	// The size limit applies to the escaped payload
+	r.Custom = []byte(`{"q":";;;;"}`)
+	validator := NewValidator(ValidationSanitize)
+	validator.MaxCustomSize = 16
+	report = validator.Validate(&r)
+	if assert.Len(t, report.Violations, 1) {
+		assert.Equal(t, RuleMaxSize, report.Violations[0].Rule)
+	}
+}
@@ -0,0 +1,124 @@
+// Package zaplog provides a zap core which stores log entries as HSDP LogEvents
+package zaplog
+
+import (
+	"context"
+
+	"github.com/philips-software/go-hsdp-api/logging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const contextKey = "hsdp.context"
+
+// Core is a zapcore.Core which stores entries as LogEvents
+type Core struct {
+	zapcore.LevelEnabler
+	storer logging.Storer
+	config logging.EventConfig
+	fields []zapcore.Field
+	ctx    context.Context
+}
+
+var _ zapcore.Core = &Core{}
+
+// NewCore returns a core storing entries enabled by enabler through storer.
+// Use a logging.Shipper as storer to batch the events. The config must pass
+// logging.EventConfig.Validate
+func NewCore(storer logging.Storer, config logging.EventConfig, enabler zapcore.LevelEnabler) (*Core, error) {
+	if storer == nil {
+		return nil, logging.ErrMissingStorer
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if enabler == nil {
+		enabler = zapcore.InfoLevel
+	}
+	return &Core{
+		LevelEnabler: enabler,
+		storer:       storer,
+		config:       config,
+	}, nil
+}
+
+// Context returns a field which passes ctx to the core. Trace and span IDs
+// are taken from it. The field is ignored by other cores
+func Context(ctx context.Context) zap.Field {
+	return zap.Field{Key: contextKey, Type: zapcore.SkipType, Interface: ctx}
+}
+
+// Severity maps a zap level to a LogEvent severity
+func Severity(level zapcore.Level) string {
+	switch {
+	case level < zapcore.InfoLevel:
+		return logging.SeverityDebug
+	case level < zapcore.WarnLevel:
+		return logging.SeverityInfo
+	case level < zapcore.ErrorLevel:
+		return logging.SeverityWarning
+	case level < zapcore.DPanicLevel:
+		return logging.SeverityError
+	}
+	return logging.SeverityFatal
+}
+
+// With returns a core which adds fields to every entry
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+	clone.ctx = contextOf(fields, c.ctx)
+	return &clone
+}
+
+// Check adds the core to ce when the entry level is enabled
+func (c *Core) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+// Write stores the entry
+func (c *Core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	custom := enc.Fields
+	if entry.LoggerName != "" {
+		custom["logger"] = entry.LoggerName
+	}
+	if entry.Caller.Defined {
+		custom["caller"] = entry.Caller.TrimmedPath()
+	}
+	if entry.Stack != "" {
+		custom["stacktrace"] = entry.Stack
+	}
+	resource := c.config.NewResource(contextOf(fields, c.ctx), entry.Time, Severity(entry.Level), entry.Message, custom)
+	_, err := c.storer.StoreResources([]logging.Resource{resource}, 1)
+	return err
+}
+
+// Sync flushes the storer when it supports flushing, e.g. a logging.Shipper
+func (c *Core) Sync() error {
+	if flusher, ok := c.storer.(interface{ Flush(context.Context) error }); ok {
+		return flusher.Flush(context.Background())
+	}
+	return nil
+}
+
+func contextOf(fields []zapcore.Field, ctx context.Context) context.Context {
+	for _, f := range fields {
+		if f.Key != contextKey || f.Type != zapcore.SkipType {
+			continue
+		}
+		if fieldCtx, ok := f.Interface.(context.Context); ok {
+			ctx = fieldCtx
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,90 @@
+package zaplog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/philips-software/go-hsdp-api/logging"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type captureStorer struct {
+	sync.Mutex
+	resources []logging.Resource
+	flushed   int
+}
+
+func (c *captureStorer) StoreResources(msgs []logging.Resource, count int) (*logging.StoreResponse, error) {
+	c.Lock()
+	defer c.Unlock()
+	c.resources = append(c.resources, msgs[:count]...)
+	return &logging.StoreResponse{}, nil
+}
+
+func (c *captureStorer) Flush(_ context.Context) error {
+	c.Lock()
+	defer c.Unlock()
+	c.flushed++
+	return nil
+}
+
+func TestCore(t *testing.T) {
+	storer := &captureStorer{}
+	core, err := NewCore(storer, logging.EventConfig{
+		ApplicationName:     "app",
+		ApplicationInstance: "instance",
+		ApplicationVersion:  "1.0.0",
+		ServiceName:         "service",
+		Component:           "component",
+		OriginatingUser:     "user",
+	}, zapcore.InfoLevel)
+	if !assert.Nil(t, err) {
+		return
+	}
+	ctx := logging.ContextWithTrace(context.Background(), "trace", "span")
+	logger := zap.New(core).Named("test").With(zap.String("app", "test"), Context(ctx))
+
+	logger.Debug("ignored")
+	logger.Error("failed", zap.Error(errors.New("boom")), zap.Int("attempt", 2))
+	assert.Nil(t, logger.Sync())
+
+	if !assert.Len(t, storer.resources, 1) {
+		return
+	}
+	resource := storer.resources[0]
+	assert.True(t, resource.Valid())
+	report := logging.NewValidator(logging.ValidationReject).Validate(&resource)
+	assert.True(t, report.Valid(), "%v", report.Violations)
+	assert.Equal(t, logging.SeverityError, resource.Severity)
+	assert.Equal(t, "failed", resource.LogData.Message)
+	assert.Equal(t, "component", resource.Component)
+	assert.Equal(t, "trace", resource.TraceID)
+	assert.Equal(t, "span", resource.SpanID)
+	assert.Equal(t, 1, storer.flushed)
+
+	var custom map[string]interface{}
+	assert.Nil(t, json.Unmarshal(resource.Custom, &custom))
+	assert.Equal(t, "test", custom["app"])
+	assert.Equal(t, "boom", custom["error"])
+	assert.Equal(t, float64(2), custom["attempt"])
+	assert.Equal(t, "test", custom["logger"])
+	assert.NotContains(t, custom, contextKey)
+
+	_, err = NewCore(nil, logging.EventConfig{}, nil)
+	assert.Equal(t, logging.ErrMissingStorer, err)
+	_, err = NewCore(storer, logging.EventConfig{Component: "component"}, nil)
+	assert.True(t, errors.Is(err, logging.ErrInvalidEventConfig))
+}
+
+func TestSeverity(t *testing.T) {
+	assert.Equal(t, logging.SeverityDebug, Severity(zapcore.DebugLevel))
+	assert.Equal(t, logging.SeverityInfo, Severity(zapcore.InfoLevel))
+	assert.Equal(t, logging.SeverityWarning, Severity(zapcore.WarnLevel))
+	assert.Equal(t, logging.SeverityError, Severity(zapcore.ErrorLevel))
+	assert.Equal(t, logging.SeverityFatal, Severity(zapcore.FatalLevel))
+}
@@ -8,4 +8,19 @@ import (
 var (
 	ErrNotificationURLCannotBeEmpty = errors.New("base Notification URL cannot be empty")
 	ErrEmptyResult                  = errors.New("empty result")
+	ErrMissingEndpoint              = errors.New("missing subscription endpoint")
+	ErrInvalidSignature             = errors.New("invalid message signature")
+	ErrInvalidToken                 = errors.New("invalid delivery token")
+	ErrInvalidCertificateURL        = errors.New("invalid signing certificate URL")
+	ErrInvalidSubscribeURL          = errors.New("invalid subscribe URL")
+	ErrUnsupportedSignatureVersion  = errors.New("unsupported signature version")
+	ErrNoHandler                    = errors.New("no handler for topic")
+	ErrInvalidTopology              = errors.New("invalid topology")
+	ErrSubscriptionNotConfirmed     = errors.New("subscription not confirmed")
+	ErrMissingTopic                 = errors.New("missing topic ID")
+	ErrMissingDefaultMessage        = errors.New("missing default message")
+	ErrMessageTooLarge              = errors.New("message too large")
+	ErrSubjectTooLong               = errors.New("subject too long")
+	ErrSMSTooLong                   = errors.New("SMS message too long")
+	ErrInvalidPushPayload           = errors.New("invalid push payload")
 )
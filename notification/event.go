@@ -13,6 +13,7 @@ type Event struct {
 	Message          string    `json:"Message"`
 	Subject          string    `json:"Subject,omitempty"`
 	SubscribeURL     string    `json:"SubscribeURL,omitempty"`
+	UnsubscribeURL   string    `json:"UnsubscribeURL,omitempty"`
 	Timestamp        time.Time `json:"Timestamp"`
 	SignatureVersion string    `json:"SignatureVersion"`
 	Signature        string    `json:"Signature"`
@@ -0,0 +1,250 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"text/template"
+	"unicode/utf8"
+)
+
+// Limits checked before a message is published
+const (
+	MaxMessageSize   = 256 * 1024
+	MaxSubjectLength = 100
+	MaxSMSLength     = 1600
+)
+
+// Push platforms for Message.Push
+const (
+	PlatformAPNS        = "APNS"
+	PlatformAPNSSandbox = "APNS_SANDBOX"
+	PlatformGCM         = "GCM"
+)
+
+// MessageStructureJSON marks a message containing per-protocol overrides
+const MessageStructureJSON = "json"
+
+const defaultPublishConcurrency = 4
+
+// EmailMessage overrides the message for email subscriptions
+type EmailMessage struct {
+	Subject string
+	Body    string
+}
+
+// Message is a typed notification message. Default is delivered to every
+// protocol without an override
+type Message struct {
+	TopicID string
+	Default string
+	Email   *EmailMessage
+	SMS     string
+	// Push holds the JSON payload per push platform
+	Push map[string]json.RawMessage
+}
+
+// PublishRequest encodes the message, checking the size limits
+func (m Message) PublishRequest() (*PublishRequest, error) {
+	if m.TopicID == "" {
+		return nil, ErrMissingTopic
+	}
+	if m.Default == "" {
+		return nil, ErrMissingDefaultMessage
+	}
+	request := &PublishRequest{TopicID: m.TopicID, Message: m.Default}
+	if m.Email != nil && m.Email.Subject != "" {
+		if utf8.RuneCountInString(m.Email.Subject) > MaxSubjectLength {
+			return nil, fmt.Errorf("%w: %d characters", ErrSubjectTooLong, utf8.RuneCountInString(m.Email.Subject))
+		}
+		request.Subject = m.Email.Subject
+	}
+	if utf8.RuneCountInString(m.SMS) > MaxSMSLength {
+		return nil, fmt.Errorf("%w: %d characters", ErrSMSTooLong, utf8.RuneCountInString(m.SMS))
+	}
+
+	overrides := make(map[string]string)
+	if m.Email != nil && m.Email.Body != "" {
+		overrides["email"] = m.Email.Body
+	}
+	if m.SMS != "" {
+		overrides["sms"] = m.SMS
+	}
+	for platform, payload := range m.Push {
+		if !json.Valid(payload) {
+			return nil, fmt.Errorf("%w: %s payload is not valid JSON", ErrInvalidPushPayload, platform)
+		}
+		overrides[platform] = string(payload)
+	}
+	if len(overrides) > 0 {
+		overrides["default"] = m.Default
+		data, err := json.Marshal(overrides)
+		if err != nil {
+			return nil, err
+		}
+		request.Message = string(data)
+		request.MessageStructure = MessageStructureJSON
+	}
+	if size := len(request.Message) + len(request.Subject); size > MaxMessageSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrMessageTooLarge, size)
+	}
+	return request, nil
+}
+
+// MessageTemplate renders messages from text/template sources. Variables
+// missing from the data are an error
+type MessageTemplate struct {
+	source    Message
+	templates map[string]*template.Template
+}
+
+// ParseMessageTemplate parses the text fields of message as templates. The Push
+// payloads are templates too and must render to valid JSON
+func ParseMessageTemplate(message Message) (*MessageTemplate, error) {
+	t := &MessageTemplate{source: message, templates: make(map[string]*template.Template)}
+	fields := map[string]string{
+		"default": message.Default,
+		"sms":     message.SMS,
+	}
+	if message.Email != nil {
+		fields["email.subject"] = message.Email.Subject
+		fields["email.body"] = message.Email.Body
+	}
+	for platform, payload := range message.Push {
+		fields["push."+platform] = string(payload)
+	}
+	for name, text := range fields {
+		if text == "" {
+			continue
+		}
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", name, err)
+		}
+		t.templates[name] = tmpl
+	}
+	return t, nil
+}
+
+// Render returns the message for topicID with the templates executed against data
+func (t *MessageTemplate) Render(topicID string, data interface{}) (Message, error) {
+	var renderErr error
+	render := func(name string) string {
+		tmpl, ok := t.templates[name]
+		if !ok || renderErr != nil {
+			return ""
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			renderErr = fmt.Errorf("template %s: %w", name, err)
+		}
+		return b.String()
+	}
+	message := Message{
+		TopicID: topicID,
+		Default: render("default"),
+		SMS:     render("sms"),
+	}
+	if t.source.Email != nil {
+		message.Email = &EmailMessage{
+			Subject: render("email.subject"),
+			Body:    render("email.body"),
+		}
+	}
+	if len(t.source.Push) > 0 {
+		message.Push = make(map[string]json.RawMessage)
+		for platform := range t.source.Push {
+			message.Push[platform] = json.RawMessage(render("push." + platform))
+		}
+	}
+	return message, renderErr
+}
+
+// PublishMessage publishes a typed message
+func (c *Client) PublishMessage(message Message) (*PublishResponse, *Response, error) {
+	request, err := message.PublishRequest()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.Publish(*request)
+}
+
+// PublishOptions control PublishMessages
+type PublishOptions struct {
+	// Concurrency is the maximum number of messages in flight. Defaults to 4
+	Concurrency int
+}
+
+// PublishResult is the outcome of publishing the message at Index
+type PublishResult struct {
+	Index    int
+	Message  Message
+	Response *PublishResponse
+	Err      error
+}
+
+// PublishMessages publishes the messages with bounded concurrency. Every message
+// gets a result, in input order, so a failing message does not affect the others.
+// Messages not yet sent when ctx is done fail with the context error
+func (c *Client) PublishMessages(ctx context.Context, messages []Message, options PublishOptions) []PublishResult {
+	results := make([]PublishResult, len(messages))
+	for i, m := range messages {
+		results[i] = PublishResult{Index: i, Message: m}
+	}
+	c.publishAll(ctx, results, options)
+	return results
+}
+
+// PublishTemplate renders tmpl for each data item and publishes the messages to
+// topicID. Rendering failures are reported in the result of the item
+func (c *Client) PublishTemplate(ctx context.Context, tmpl *MessageTemplate, topicID string, data []interface{}, options PublishOptions) []PublishResult {
+	results := make([]PublishResult, len(data))
+	for i, d := range data {
+		message, err := tmpl.Render(topicID, d)
+		results[i] = PublishResult{Index: i, Message: message, Err: err}
+	}
+	c.publishAll(ctx, results, options)
+	return results
+}
+
+func (c *Client) publishAll(ctx context.Context, results []PublishResult, options PublishOptions) {
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultPublishConcurrency
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(results); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i].Response, _, results[i].Err = c.PublishMessage(results[i].Message)
+			}
+		}()
+	}
+	for i := range results {
+		if results[i].Err == nil {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// FailedResults returns the results with an error
+func FailedResults(results []PublishResult) []PublishResult {
+	var failed []PublishResult
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
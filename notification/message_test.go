@@ -0,0 +1,155 @@
+package notification_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/philips-software/go-hsdp-api/notification"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessagePublishRequest(t *testing.T) {
+	request, err := notification.Message{TopicID: "topic-1", Default: "hello"}.PublishRequest()
+	if assert.Nil(t, err) {
+		assert.Equal(t, notification.PublishRequest{TopicID: "topic-1", Message: "hello"}, *request)
+	}
+
+	request, err = notification.Message{
+		TopicID: "topic-1",
+		Default: "Device dev-1 is offline",
+		Email:   &notification.EmailMessage{Subject: "Device offline", Body: "Device dev-1 went offline at 10:00"},
+		SMS:     "dev-1 offline",
+		Push:    map[string]json.RawMessage{notification.PlatformGCM: json.RawMessage(`{"data":{"device":"dev-1"}}`)},
+	}.PublishRequest()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "Device offline", request.Subject)
+	assert.Equal(t, notification.MessageStructureJSON, request.MessageStructure)
+	var structured map[string]string
+	assert.Nil(t, json.Unmarshal([]byte(request.Message), &structured))
+	assert.Equal(t, map[string]string{
+		"default": "Device dev-1 is offline",
+		"email":   "Device dev-1 went offline at 10:00",
+		"sms":     "dev-1 offline",
+		"GCM":     `{"data":{"device":"dev-1"}}`,
+	}, structured)
+
+	for _, tc := range []struct {
+		message notification.Message
+		err     error
+	}{
+		{notification.Message{Default: "hello"}, notification.ErrMissingTopic},
+		{notification.Message{TopicID: "t"}, notification.ErrMissingDefaultMessage},
+		{notification.Message{TopicID: "t", Default: strings.Repeat("x", notification.MaxMessageSize+1)}, notification.ErrMessageTooLarge},
+		{notification.Message{TopicID: "t", Default: "x", SMS: strings.Repeat("x", notification.MaxSMSLength+1)}, notification.ErrSMSTooLong},
+		{notification.Message{TopicID: "t", Default: "x", Email: &notification.EmailMessage{Subject: strings.Repeat("x", 101)}}, notification.ErrSubjectTooLong},
+		{notification.Message{TopicID: "t", Default: "x", Push: map[string]json.RawMessage{notification.PlatformAPNS: json.RawMessage(`{`)}}, notification.ErrInvalidPushPayload},
+	} {
+		_, err := tc.message.PublishRequest()
+		assert.True(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+	}
+}
+
+func TestMessageTemplate(t *testing.T) {
+	_, err := notification.ParseMessageTemplate(notification.Message{Default: "{{ .Device "})
+	assert.NotNil(t, err)
+
+	tmpl, err := notification.ParseMessageTemplate(notification.Message{
+		Default: "Device {{ .Device }} is {{ .State }}",
+		Email:   &notification.EmailMessage{Subject: "Device {{ .State }}", Body: "Device {{ .Device }} changed to {{ .State }}"},
+		Push:    map[string]json.RawMessage{notification.PlatformGCM: json.RawMessage(`{"device":"{{ .Device }}"}`)},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	message, err := tmpl.Render("topic-1", map[string]string{"Device": "dev-1", "State": "offline"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "topic-1", message.TopicID)
+	assert.Equal(t, "Device dev-1 is offline", message.Default)
+	assert.Equal(t, "Device offline", message.Email.Subject)
+	assert.Equal(t, "Device dev-1 changed to offline", message.Email.Body)
+	assert.Equal(t, `{"device":"dev-1"}`, string(message.Push[notification.PlatformGCM]))
+
+	_, err = tmpl.Render("topic-1", map[string]string{"Device": "dev-1"})
+	assert.NotNil(t, err)
+}
+
+func TestPublishMessages(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	var inFlight, maxInFlight int32
+	var mu sync.Mutex
+	var published []notification.PublishRequest
+	muxNotification.HandleFunc("/core/notification/Publish", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		var request notification.PublishRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "application/json")
+		if request.TopicID == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"resourceType":"OperationOutcome","issue":[{"severity":"error","code":"not-found"}]}`))
+			return
+		}
+		mu.Lock()
+		published = append(published, request)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(notification.PublishResponse{ID: "msg", TopicID: request.TopicID})
+	})
+
+	messages := []notification.Message{
+		{TopicID: "topic-1", Default: "one"},
+		{TopicID: "topic-1"},
+		{TopicID: "missing", Default: "three"},
+	}
+	for i := 0; i < 7; i++ {
+		messages = append(messages, notification.Message{TopicID: "topic-2", Default: "more"})
+	}
+	results := notificationClient.PublishMessages(context.Background(), messages, notification.PublishOptions{Concurrency: 2})
+	if !assert.Len(t, results, 10) {
+		return
+	}
+	assert.LessOrEqual(t, maxInFlight, int32(2))
+	assert.Len(t, published, 8)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, "topic-1", results[0].Response.TopicID)
+	failed := notification.FailedResults(results)
+	if assert.Len(t, failed, 2) {
+		assert.Equal(t, 1, failed[0].Index)
+		assert.Equal(t, notification.ErrMissingDefaultMessage, failed[0].Err)
+		assert.Equal(t, 2, failed[1].Index)
+	}
+
+	tmpl, _ := notification.ParseMessageTemplate(notification.Message{Default: "Hello {{ .Name }}"})
+	results = notificationClient.PublishTemplate(context.Background(), tmpl, "topic-3", []interface{}{
+		map[string]string{"Name": "Jane"},
+		map[string]string{},
+	}, notification.PublishOptions{})
+	assert.Nil(t, results[0].Err)
+	assert.NotNil(t, results[1].Err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = notificationClient.PublishMessages(ctx, messages[:1], notification.PublishOptions{})
+	assert.Equal(t, context.Canceled, results[0].Err)
+}
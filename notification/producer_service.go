@@ -38,6 +38,8 @@ type GetOptions struct {
 	Scope                 *string `url:"scope,omitempty"`
 	Name                  *string `url:"name,omitempty"`
 	ProducerID            *string `url:"producerId,omitempty"`
+	TopicID               *string `url:"topicId,omitempty"`
+	SubscriberID          *string `url:"subscriberId,omitempty"`
 }
 
 func (p *ProducerService) CreateProducer(producer Producer) (*Producer, *Response, error) {
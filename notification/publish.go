@@ -6,8 +6,10 @@ import (
 )
 
 type PublishRequest struct {
-	TopicID string `json:"topicId"`
-	Message string `json:"message"`
+	TopicID          string `json:"topicId"`
+	Message          string `json:"message"`
+	Subject          string `json:"subject,omitempty"`
+	MessageStructure string `json:"messageStructure,omitempty"`
 }
 
 type PublishResponse struct {
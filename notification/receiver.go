@@ -0,0 +1,467 @@
+package notification
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message types delivered to subscriber endpoints
+const (
+	MessageTypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	MessageTypeNotification             = "Notification"
+	MessageTypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+const (
+	// TokenHeader carries the delivery token when it is not passed as token query parameter
+	TokenHeader = "X-Notification-Token"
+
+	messageTypeHeader      = "X-Amz-Sns-Message-Type"
+	maxDeliverySize        = 256 * 1024
+	defaultDedupeTTL       = 24 * time.Hour
+	defaultDedupeMaxSize   = 100000
+	defaultReceiverTimeout = 10 * time.Second
+)
+
+var snsHostRegexp = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// Handler handles notification events delivered to a Receiver
+type Handler interface {
+	HandleEvent(ctx context.Context, event Event) error
+}
+
+// HandlerFunc adapts a function to a Handler
+type HandlerFunc func(ctx context.Context, event Event) error
+
+// HandleEvent calls f
+func (f HandlerFunc) HandleEvent(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// JSONHandler returns a handler which decodes the event message as JSON into T before calling fn
+func JSONHandler[T any](fn func(ctx context.Context, event Event, payload T) error) HandlerFunc {
+	return func(ctx context.Context, event Event) error {
+		var payload T
+		if err := json.Unmarshal([]byte(event.Message), &payload); err != nil {
+			return fmt.Errorf("JSONHandler: %w", err)
+		}
+		return fn(ctx, event, payload)
+	}
+}
+
+// DedupeStore tracks processed message IDs so redelivered messages are handled once
+type DedupeStore interface {
+	// Claim reserves the message ID. It returns false when the message was
+	// processed before or is being processed
+	Claim(messageID string) bool
+	// Complete marks a claimed message as processed, or releases the claim
+	// when processing failed so a redelivery is handled again
+	Complete(messageID string, processed bool)
+}
+
+// MemoryDedupeStore is a DedupeStore which remembers message IDs in memory
+type MemoryDedupeStore struct {
+	TTL     time.Duration
+	MaxSize int
+
+	entries map[string]dedupeEntry
+	sync.Mutex
+}
+
+type dedupeEntry struct {
+	processed bool
+	expires   time.Time
+}
+
+var _ DedupeStore = &MemoryDedupeStore{}
+
+// NewMemoryDedupeStore returns an in-memory store remembering message IDs for ttl
+func NewMemoryDedupeStore(ttl time.Duration) *MemoryDedupeStore {
+	if ttl <= 0 {
+		ttl = defaultDedupeTTL
+	}
+	return &MemoryDedupeStore{
+		TTL:     ttl,
+		MaxSize: defaultDedupeMaxSize,
+		entries: make(map[string]dedupeEntry),
+	}
+}
+
+// Claim reserves the message ID
+func (m *MemoryDedupeStore) Claim(messageID string) bool {
+	m.Lock()
+	defer m.Unlock()
+	now := time.Now()
+	if entry, ok := m.entries[messageID]; ok && now.Before(entry.expires) {
+		return false
+	}
+	if m.MaxSize > 0 && len(m.entries) >= m.MaxSize {
+		for id, entry := range m.entries {
+			if !now.Before(entry.expires) {
+				delete(m.entries, id)
+			}
+		}
+	}
+	m.entries[messageID] = dedupeEntry{expires: now.Add(m.TTL)}
+	return true
+}
+
+// Complete marks the message as processed or releases the claim
+func (m *MemoryDedupeStore) Complete(messageID string, processed bool) {
+	m.Lock()
+	defer m.Unlock()
+	if !processed {
+		delete(m.entries, messageID)
+		return
+	}
+	m.entries[messageID] = dedupeEntry{processed: true, expires: time.Now().Add(m.TTL)}
+}
+
+// ReceiverConfig configures a Receiver
+type ReceiverConfig struct {
+	// Client, when set, confirms subscriptions through the HSDP Notification API.
+	// Otherwise the SubscribeURL of the confirmation message is visited
+	Client *Client
+	// Endpoint is the subscription endpoint registered with HSDP. Required with Client
+	Endpoint string
+	// Token, when set, must be passed as token query parameter or in the TokenHeader
+	Token string
+	// SkipSignatureVerification disables the message signature check
+	SkipSignatureVerification bool
+	// CertificateFetcher retrieves signing certificates. The default only accepts
+	// HTTPS URLs on Amazon SNS hosts
+	CertificateFetcher func(certURL string) (*x509.Certificate, error)
+	// Dedupe tracks processed messages. Defaults to a MemoryDedupeStore
+	Dedupe DedupeStore
+	// HTTPClient is used for fetching certificates and visiting subscribe URLs
+	HTTPClient *http.Client
+	// OnError is called for deliveries which could not be handled
+	OnError func(event *Event, err error)
+}
+
+// Receiver is an http.Handler for HTTP(S) subscriber endpoints. It confirms
+// subscriptions, verifies deliveries and dispatches events to handlers by topic.
+// Handler errors result in a 500 response so the message is redelivered
+type Receiver struct {
+	config ReceiverConfig
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	fallback Handler
+	certs    map[string]*x509.Certificate
+}
+
+var _ http.Handler = &Receiver{}
+
+type deliveredEvent struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicARN         string `json:"TopicArn"`
+	Message          string `json:"Message"`
+	Subject          string `json:"Subject"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	UnsubscribeURL   string `json:"UnsubscribeURL"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+// NewReceiver returns a Receiver without handlers
+func NewReceiver(config ReceiverConfig) (*Receiver, error) {
+	if config.Client != nil && config.Endpoint == "" {
+		return nil, ErrMissingEndpoint
+	}
+	if config.Dedupe == nil {
+		config.Dedupe = NewMemoryDedupeStore(defaultDedupeTTL)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: defaultReceiverTimeout}
+	}
+	r := &Receiver{
+		config:   config,
+		handlers: make(map[string]Handler),
+		certs:    make(map[string]*x509.Certificate),
+	}
+	if r.config.CertificateFetcher == nil {
+		r.config.CertificateFetcher = r.fetchCertificate
+	}
+	return r, nil
+}
+
+// Handle registers handler for a topic. The topic is matched against the full
+// topic ARN or the topic name, its last segment. Use "*" for events of all other topics
+func (r *Receiver) Handle(topic string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if topic == "*" {
+		r.fallback = handler
+		return
+	}
+	r.handlers[topic] = handler
+}
+
+// HandleFunc registers fn for a topic, see Handle
+func (r *Receiver) HandleFunc(topic string, fn func(ctx context.Context, event Event) error) {
+	r.Handle(topic, HandlerFunc(fn))
+}
+
+func (r *Receiver) handler(topicARN string) Handler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if h, ok := r.handlers[topicARN]; ok {
+		return h
+	}
+	if h, ok := r.handlers[topicARN[strings.LastIndex(topicARN, ":")+1:]]; ok {
+		return h
+	}
+	return r.fallback
+}
+
+// ServeHTTP handles a delivery
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.config.Token != "" {
+		token := req.URL.Query().Get("token")
+		if token == "" {
+			token = req.Header.Get(TokenHeader)
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(r.config.Token)) != 1 {
+			r.reportError(nil, ErrInvalidToken)
+			http.Error(w, ErrInvalidToken.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxDeliverySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var delivered deliveredEvent
+	if err := json.Unmarshal(body, &delivered); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if delivered.Type == "" {
+		delivered.Type = req.Header.Get(messageTypeHeader)
+	}
+	event := delivered.event()
+	if !r.config.SkipSignatureVerification {
+		if err := r.verify(delivered); err != nil {
+			r.reportError(&event, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	switch delivered.Type {
+	case MessageTypeSubscriptionConfirmation:
+		if err := r.confirm(event); err != nil {
+			r.reportError(&event, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	case MessageTypeNotification:
+		if status, err := r.dispatch(req.Context(), event); err != nil {
+			r.reportError(&event, err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+	case MessageTypeUnsubscribeConfirmation:
+	default:
+		http.Error(w, "unsupported message type", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *Receiver) dispatch(ctx context.Context, event Event) (int, error) {
+	handler := r.handler(event.TopicARN)
+	if handler == nil {
+		// Acknowledge so the message is not redelivered over and over
+		r.reportError(&event, fmt.Errorf("%w: %s", ErrNoHandler, event.TopicARN))
+		return http.StatusOK, nil
+	}
+	if event.MessageID != "" && !r.config.Dedupe.Claim(event.MessageID) {
+		return http.StatusOK, nil
+	}
+	err := handler.HandleEvent(ctx, event)
+	if event.MessageID != "" {
+		r.config.Dedupe.Complete(event.MessageID, err == nil)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func (r *Receiver) confirm(event Event) error {
+	if r.config.Client != nil {
+		_, _, err := r.config.Client.Subscription.ConfirmSubscription(ConfirmRequest{
+			Token:    event.Token,
+			TopicARN: event.TopicARN,
+			Endpoint: r.config.Endpoint,
+		})
+		return err
+	}
+	u, err := url.Parse(event.SubscribeURL)
+	if err != nil || u.Scheme != "https" || !snsHostRegexp.MatchString(u.Hostname()) {
+		return ErrInvalidSubscribeURL
+	}
+	resp, err := r.config.HTTPClient.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("confirm subscription: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (r *Receiver) reportError(event *Event, err error) {
+	if r.config.OnError != nil {
+		r.config.OnError(event, err)
+	}
+}
+
+// verify checks the message signature against the signing certificate
+func (r *Receiver) verify(delivered deliveredEvent) error {
+	var hash crypto.Hash
+	var digest []byte
+	data := []byte(delivered.stringToSign())
+	switch delivered.SignatureVersion {
+	case "1":
+		sum := sha1.Sum(data)
+		hash, digest = crypto.SHA1, sum[:]
+	case "2":
+		sum := sha256.Sum256(data)
+		hash, digest = crypto.SHA256, sum[:]
+	default:
+		return ErrUnsupportedSignatureVersion
+	}
+	signature, err := base64.StdEncoding.DecodeString(delivered.Signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	cert, err := r.certificate(delivered.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return ErrInvalidSignature
+	}
+	if err := rsa.VerifyPKCS1v15(publicKey, hash, digest, signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (r *Receiver) certificate(certURL string) (*x509.Certificate, error) {
+	r.mu.RLock()
+	cert, ok := r.certs[certURL]
+	r.mu.RUnlock()
+	if ok {
+		return cert, nil
+	}
+	cert, err := r.config.CertificateFetcher(certURL)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.certs[certURL] = cert
+	r.mu.Unlock()
+	return cert, nil
+}
+
+func (r *Receiver) fetchCertificate(certURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(certURL)
+	if err != nil || u.Scheme != "https" || !snsHostRegexp.MatchString(u.Hostname()) {
+		return nil, ErrInvalidCertificateURL
+	}
+	resp, err := r.config.HTTPClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch certificate: HTTP %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDeliverySize))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidCertificateURL
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// stringToSign builds the canonical message used for the signature
+func (d deliveredEvent) stringToSign() string {
+	var b strings.Builder
+	add := func(key, value string) {
+		b.WriteString(key)
+		b.WriteString("\n")
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+	add("Message", d.Message)
+	add("MessageId", d.MessageID)
+	if d.Type == MessageTypeNotification {
+		if d.Subject != "" {
+			add("Subject", d.Subject)
+		}
+	} else {
+		add("SubscribeURL", d.SubscribeURL)
+	}
+	add("Timestamp", d.Timestamp)
+	if d.Type != MessageTypeNotification {
+		add("Token", d.Token)
+	}
+	add("TopicArn", d.TopicARN)
+	add("Type", d.Type)
+	return b.String()
+}
+
+func (d deliveredEvent) event() Event {
+	event := Event{
+		Type:             d.Type,
+		MessageID:        d.MessageID,
+		Token:            d.Token,
+		TopicARN:         d.TopicARN,
+		Message:          d.Message,
+		Subject:          d.Subject,
+		SubscribeURL:     d.SubscribeURL,
+		UnsubscribeURL:   d.UnsubscribeURL,
+		SignatureVersion: d.SignatureVersion,
+		Signature:        d.Signature,
+		SigningCertURL:   d.SigningCertURL,
+	}
+	event.Timestamp, _ = time.Parse(time.RFC3339Nano, d.Timestamp)
+	return event
+}
@@ -0,0 +1,260 @@
+package notification_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/philips-software/go-hsdp-api/notification"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTopicARN = "arn:aws:sns:eu-west-1:123456789012:device-alerts"
+	testCertURL  = "https://sns.eu-west-1.amazonaws.com/cert.pem"
+)
+
+type testSigner struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{key: key, cert: cert}
+}
+
+func (s *testSigner) fetch(certURL string) (*x509.Certificate, error) {
+	return s.cert, nil
+}
+
+// delivery returns a signed SNS style message body
+func (s *testSigner) delivery(t *testing.T, fields map[string]string) []byte {
+	fields["SignatureVersion"] = "2"
+	fields["SigningCertURL"] = testCertURL
+	if fields["Timestamp"] == "" {
+		fields["Timestamp"] = "2021-07-01T10:00:00.000Z"
+	}
+	keys := []string{"Message", "MessageId", "Subject", "Timestamp", "TopicArn", "Type"}
+	if fields["Type"] != notification.MessageTypeNotification {
+		keys = []string{"Message", "MessageId", "SubscribeURL", "Timestamp", "Token", "TopicArn", "Type"}
+	}
+	var toSign bytes.Buffer
+	for _, key := range keys {
+		if key == "Subject" && fields[key] == "" {
+			continue
+		}
+		toSign.WriteString(key + "\n" + fields[key] + "\n")
+	}
+	digest := sha256.Sum256(toSign.Bytes())
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields["Signature"] = base64.StdEncoding.EncodeToString(signature)
+	body, _ := json.Marshal(fields)
+	return body
+}
+
+func deliver(receiver http.Handler, target string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	receiver.ServeHTTP(w, req)
+	return w
+}
+
+type alert struct {
+	DeviceID string `json:"deviceId"`
+	Level    int    `json:"level"`
+}
+
+func TestReceiverDispatch(t *testing.T) {
+	signer := newTestSigner(t)
+	receiver, err := notification.NewReceiver(notification.ReceiverConfig{
+		CertificateFetcher: signer.fetch,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	var received []alert
+	var failures int32
+	receiver.Handle("device-alerts", notification.JSONHandler(func(ctx context.Context, event notification.Event, payload alert) error {
+		if payload.Level > 5 && atomic.AddInt32(&failures, 1) == 1 {
+			return errors.New("try again")
+		}
+		received = append(received, payload)
+		return nil
+	}))
+	var fallback int
+	receiver.HandleFunc("*", func(ctx context.Context, event notification.Event) error {
+		fallback++
+		return nil
+	})
+
+	body := signer.delivery(t, map[string]string{
+		"Type":      notification.MessageTypeNotification,
+		"MessageId": "msg-1",
+		"TopicArn":  testTopicARN,
+		"Subject":   "alert",
+		"Message":   `{"deviceId":"dev-1","level":3}`,
+	})
+	assert.Equal(t, http.StatusOK, deliver(receiver, "/", body).Code)
+	// Redelivery is acknowledged without calling the handler again
+	assert.Equal(t, http.StatusOK, deliver(receiver, "/", body).Code)
+	assert.Equal(t, []alert{{DeviceID: "dev-1", Level: 3}}, received)
+
+	// A failing handler causes a redelivery which is then processed
+	body = signer.delivery(t, map[string]string{
+		"Type":      notification.MessageTypeNotification,
+		"MessageId": "msg-2",
+		"TopicArn":  testTopicARN,
+		"Message":   `{"deviceId":"dev-2","level":9}`,
+	})
+	assert.Equal(t, http.StatusInternalServerError, deliver(receiver, "/", body).Code)
+	assert.Equal(t, http.StatusOK, deliver(receiver, "/", body).Code)
+	assert.Len(t, received, 2)
+
+	body = signer.delivery(t, map[string]string{
+		"Type":      notification.MessageTypeNotification,
+		"MessageId": "msg-3",
+		"TopicArn":  "arn:aws:sns:eu-west-1:123456789012:other",
+		"Message":   "hello",
+	})
+	assert.Equal(t, http.StatusOK, deliver(receiver, "/", body).Code)
+	assert.Equal(t, 1, fallback)
+}
+
+func TestReceiverVerification(t *testing.T) {
+	signer := newTestSigner(t)
+	var reported []error
+	receiver, err := notification.NewReceiver(notification.ReceiverConfig{
+		Token:              "secret",
+		CertificateFetcher: signer.fetch,
+		OnError: func(event *notification.Event, err error) {
+			reported = append(reported, err)
+		},
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	body := signer.delivery(t, map[string]string{
+		"Type":      notification.MessageTypeNotification,
+		"MessageId": "msg-1",
+		"TopicArn":  testTopicARN,
+		"Message":   "hello",
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, deliver(receiver, "/?token=wrong", body).Code)
+	// No handler registered: acknowledged and reported
+	assert.Equal(t, http.StatusOK, deliver(receiver, "/?token=secret", body).Code)
+
+	tampered := bytes.Replace(body, []byte("hello"), []byte("bye"), 1)
+	assert.Equal(t, http.StatusForbidden, deliver(receiver, "/?token=secret", tampered).Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	receiver.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	if assert.Len(t, reported, 3) {
+		assert.Equal(t, notification.ErrInvalidToken, reported[0])
+		assert.True(t, errors.Is(reported[1], notification.ErrNoHandler))
+		assert.Equal(t, notification.ErrInvalidSignature, reported[2])
+	}
+
+	// The default fetcher only accepts SNS hosts
+	receiver, _ = notification.NewReceiver(notification.ReceiverConfig{})
+	fields := map[string]string{
+		"Type":      notification.MessageTypeNotification,
+		"MessageId": "msg-1",
+		"TopicArn":  testTopicARN,
+		"Message":   "hello",
+	}
+	body = signer.delivery(t, fields)
+	body = bytes.Replace(body, []byte("sns.eu-west-1.amazonaws.com"), []byte("evil.example.com"), 1)
+	assert.Equal(t, http.StatusForbidden, deliver(receiver, "/", body).Code)
+}
+
+func TestReceiverConfirmSubscription(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+
+	var confirmed notification.ConfirmRequest
+	muxNotification.HandleFunc("/core/notification/Subscription/_confirm", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&confirmed)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{"_id":"sub-1","resourceType":"Subscription"}`)
+	})
+
+	_, err := notification.NewReceiver(notification.ReceiverConfig{Client: notificationClient})
+	assert.Equal(t, notification.ErrMissingEndpoint, err)
+
+	signer := newTestSigner(t)
+	receiver, err := notification.NewReceiver(notification.ReceiverConfig{
+		Client:             notificationClient,
+		Endpoint:           "https://example.com/hooks",
+		CertificateFetcher: signer.fetch,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	body := signer.delivery(t, map[string]string{
+		"Type":         notification.MessageTypeSubscriptionConfirmation,
+		"MessageId":    "msg-1",
+		"Token":        "confirm-token",
+		"TopicArn":     testTopicARN,
+		"Message":      "You have chosen to subscribe",
+		"SubscribeURL": "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription",
+	})
+	assert.Equal(t, http.StatusOK, deliver(receiver, "/", body).Code)
+	assert.Equal(t, notification.ConfirmRequest{
+		Token:    "confirm-token",
+		TopicARN: testTopicARN,
+		Endpoint: "https://example.com/hooks",
+	}, confirmed)
+}
+
+func TestMemoryDedupeStore(t *testing.T) {
+	store := notification.NewMemoryDedupeStore(50 * time.Millisecond)
+	assert.True(t, store.Claim("a"))
+	assert.False(t, store.Claim("a"))
+	store.Complete("a", false)
+	assert.True(t, store.Claim("a"))
+	store.Complete("a", true)
+	assert.False(t, store.Claim("a"))
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, store.Claim("a"))
+}
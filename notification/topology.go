@@ -0,0 +1,570 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// Topology actions
+const (
+	TopologyCreate  = "create"
+	TopologyUpdate  = "update"
+	TopologyReplace = "replace"
+	TopologyDelete  = "delete"
+)
+
+// Topology object kinds
+const (
+	KindProducer     = "Producer"
+	KindTopic        = "Topic"
+	KindSubscriber   = "Subscriber"
+	KindSubscription = "Subscription"
+)
+
+const (
+	defaultConfirmTimeout = 5 * time.Minute
+	defaultPollInterval   = 5 * time.Second
+)
+
+// Topology declares a complete notification flow: a producer, its topics and
+// the subscribers receiving messages from them
+type Topology struct {
+	Producer Producer
+	// Topics of the producer. ProducerID is filled in by ApplyTopology
+	Topics []Topic
+	// Subscribers are identified by organization, product and service name
+	Subscribers []Subscriber
+	// Subscriptions connect topics to subscribers
+	Subscriptions []TopologySubscription
+}
+
+// TopologySubscription subscribes a subscriber to a topic by name. Endpoint is the
+// delivery endpoint, e.g. an https:// URL, mailto: address, sms: number or queue ARN
+type TopologySubscription struct {
+	Topic      string
+	Subscriber string
+	Endpoint   string
+}
+
+// TopologyChange describes a single change made or planned by ApplyTopology
+type TopologyChange struct {
+	Action string
+	Kind   string
+	Name   string
+	ID     string
+}
+
+func (c TopologyChange) String() string {
+	if c.ID == "" {
+		return fmt.Sprintf("%s %s %s", c.Action, c.Kind, c.Name)
+	}
+	return fmt.Sprintf("%s %s %s (%s)", c.Action, c.Kind, c.Name, c.ID)
+}
+
+// ApplyOptions control ApplyTopology
+type ApplyOptions struct {
+	// DryRun only computes the changes
+	DryRun bool
+	// Prune deletes topics of the producer, subscribers of the declared products
+	// and subscriptions of the declared topics which are not in the topology
+	Prune bool
+	// WaitForConfirmation waits until new subscriptions are confirmed by their endpoints
+	WaitForConfirmation bool
+	// ConfirmTimeout bounds the wait for confirmations. Defaults to 5 minutes
+	ConfirmTimeout time.Duration
+	// PollInterval is the initial interval between confirmation checks. Defaults to 5 seconds
+	PollInterval time.Duration
+}
+
+// TopologyResult is the state after ApplyTopology. Topics and Subscribers are keyed
+// by topic name and subscriber service name
+type TopologyResult struct {
+	Producer      *Producer
+	Topics        map[string]Topic
+	Subscribers   map[string]Subscriber
+	Subscriptions []Subscription
+	Changes       []TopologyChange
+}
+
+type topologyState struct {
+	producer      *Producer
+	topics        []Topic
+	subscribers   []Subscriber
+	subscriptions []Subscription
+}
+
+// ApplyTopology creates, updates or deletes producer, topics, subscribers and
+// subscriptions until the HSDP state matches the topology. Objects are compared
+// by name. Subscribers cannot be updated so a changed subscriber is replaced.
+// The producer is create-only: the service has no producer update and replacing
+// it would delete its topics, so changes to its other fields are not applied
+func (c *Client) ApplyTopology(topology Topology, options ApplyOptions) (*TopologyResult, error) {
+	if err := validateTopology(topology); err != nil {
+		return nil, err
+	}
+	current, err := c.topologyState(topology)
+	if err != nil {
+		return nil, err
+	}
+	result := &TopologyResult{
+		Producer:    current.producer,
+		Topics:      make(map[string]Topic),
+		Subscribers: make(map[string]Subscriber),
+	}
+	record := func(action, kind, name, id string) {
+		result.Changes = append(result.Changes, TopologyChange{Action: action, Kind: kind, Name: name, ID: id})
+	}
+
+	// Producer
+	if current.producer == nil {
+		record(TopologyCreate, KindProducer, topology.Producer.ProducerServiceName, "")
+		if !options.DryRun {
+			created, _, err := c.Producer.CreateProducer(topology.Producer)
+			if err != nil {
+				return result, fmt.Errorf("create producer: %w", err)
+			}
+			result.Producer = created
+			result.Changes[len(result.Changes)-1].ID = created.ID
+		}
+	}
+	producerID := ""
+	if result.Producer != nil {
+		producerID = result.Producer.ID
+	}
+
+	// Topics
+	existingTopics := make(map[string]Topic)
+	for _, t := range current.topics {
+		existingTopics[t.Name] = t
+	}
+	for _, desired := range topology.Topics {
+		desired.ProducerID = producerID
+		existing, ok := existingTopics[desired.Name]
+		delete(existingTopics, desired.Name)
+		switch {
+		case !ok:
+			record(TopologyCreate, KindTopic, desired.Name, "")
+			if options.DryRun {
+				continue
+			}
+			created, _, err := c.Topic.CreateTopic(desired)
+			if err != nil {
+				return result, fmt.Errorf("create topic %s: %w", desired.Name, err)
+			}
+			result.Changes[len(result.Changes)-1].ID = created.ID
+			result.Topics[desired.Name] = *created
+		case !topicEqual(existing, desired):
+			record(TopologyUpdate, KindTopic, desired.Name, existing.ID)
+			if options.DryRun {
+				result.Topics[desired.Name] = existing
+				continue
+			}
+			desired.ID = existing.ID
+			updated, _, err := c.Topic.UpdateTopic(desired)
+			if err != nil {
+				return result, fmt.Errorf("update topic %s: %w", desired.Name, err)
+			}
+			result.Topics[desired.Name] = *updated
+		default:
+			result.Topics[desired.Name] = existing
+		}
+	}
+
+	// Subscribers
+	existingSubscribers := make(map[string]Subscriber)
+	for _, s := range current.subscribers {
+		existingSubscribers[subscriberKey(s)] = s
+	}
+	replaced := make(map[string]bool)
+	var createSubscribers []Subscriber
+	for _, desired := range topology.Subscribers {
+		key := subscriberKey(desired)
+		existing, ok := existingSubscribers[key]
+		delete(existingSubscribers, key)
+		switch {
+		case !ok:
+			record(TopologyCreate, KindSubscriber, desired.SubscriberServicename, "")
+			createSubscribers = append(createSubscribers, desired)
+		case !subscriberEqual(existing, desired):
+			replaced[existing.ID] = true
+			createSubscribers = append(createSubscribers, desired)
+		default:
+			result.Subscribers[desired.SubscriberServicename] = existing
+		}
+	}
+
+	// Subscriptions to delete: those of replaced subscribers and, when pruning,
+	// undeclared ones. They go before their subscribers and topics
+	wanted := make(map[string]TopologySubscription)
+	for _, s := range topology.Subscriptions {
+		wanted[s.Topic+"|"+s.Subscriber+"|"+s.Endpoint] = s
+	}
+	topicNames := make(map[string]string)
+	for name, t := range result.Topics {
+		topicNames[t.ID] = name
+	}
+	for _, t := range existingTopics {
+		topicNames[t.ID] = t.Name
+	}
+	subscriberNames := make(map[string]string)
+	for _, s := range current.subscribers {
+		subscriberNames[s.ID] = s.SubscriberServicename
+	}
+	for _, s := range current.subscriptions {
+		key := topicNames[s.TopicID] + "|" + subscriberNames[s.SubscriberID] + "|" + s.SubscriptionEndpoint
+		if _, ok := wanted[key]; ok && !replaced[s.SubscriberID] {
+			delete(wanted, key)
+			result.Subscriptions = append(result.Subscriptions, s)
+			continue
+		}
+		if !replaced[s.SubscriberID] && !options.Prune {
+			continue
+		}
+		record(TopologyDelete, KindSubscription, s.SubscriptionEndpoint, s.ID)
+		if options.DryRun {
+			continue
+		}
+		if _, _, err := c.Subscription.DeleteSubscription(s); err != nil {
+			return result, fmt.Errorf("delete subscription %s: %w", s.ID, err)
+		}
+	}
+
+	for _, s := range current.subscribers {
+		if !replaced[s.ID] {
+			continue
+		}
+		record(TopologyReplace, KindSubscriber, s.SubscriberServicename, s.ID)
+		if options.DryRun {
+			continue
+		}
+		if _, _, err := c.Subscriber.DeleteSubscriber(s); err != nil {
+			return result, fmt.Errorf("replace subscriber %s: %w", s.SubscriberServicename, err)
+		}
+	}
+	for _, desired := range createSubscribers {
+		if options.DryRun {
+			continue
+		}
+		created, _, err := c.Subscriber.CreateSubscriber(desired)
+		if err != nil {
+			return result, fmt.Errorf("create subscriber %s: %w", desired.SubscriberServicename, err)
+		}
+		result.Subscribers[desired.SubscriberServicename] = *created
+	}
+
+	// Subscriptions to create, in declaration order
+	var pending []Subscription
+	for _, s := range topology.Subscriptions {
+		if _, ok := wanted[s.Topic+"|"+s.Subscriber+"|"+s.Endpoint]; !ok {
+			continue
+		}
+		record(TopologyCreate, KindSubscription, s.Endpoint, "")
+		if options.DryRun {
+			continue
+		}
+		created, _, err := c.Subscription.CreateSubscription(Subscription{
+			TopicID:              result.Topics[s.Topic].ID,
+			SubscriberID:         result.Subscribers[s.Subscriber].ID,
+			SubscriptionEndpoint: s.Endpoint,
+		})
+		if err != nil {
+			return result, fmt.Errorf("create subscription %s: %w", s.Endpoint, err)
+		}
+		result.Changes[len(result.Changes)-1].ID = created.ID
+		result.Subscriptions = append(result.Subscriptions, *created)
+		pending = append(pending, *created)
+	}
+
+	// Pruned subscribers and topics
+	if options.Prune {
+		for _, s := range sortedSubscribers(existingSubscribers) {
+			record(TopologyDelete, KindSubscriber, s.SubscriberServicename, s.ID)
+			if options.DryRun {
+				continue
+			}
+			if _, _, err := c.Subscriber.DeleteSubscriber(s); err != nil {
+				return result, fmt.Errorf("delete subscriber %s: %w", s.SubscriberServicename, err)
+			}
+		}
+		for _, t := range sortedTopics(existingTopics) {
+			record(TopologyDelete, KindTopic, t.Name, t.ID)
+			if options.DryRun {
+				continue
+			}
+			if _, _, err := c.Topic.DeleteTopic(t); err != nil {
+				return result, fmt.Errorf("delete topic %s: %w", t.Name, err)
+			}
+		}
+	}
+
+	if options.WaitForConfirmation && !options.DryRun && len(pending) > 0 {
+		confirmed, err := c.waitForConfirmation(pending, options)
+		for i, s := range result.Subscriptions {
+			if update, ok := confirmed[s.ID]; ok {
+				result.Subscriptions[i] = update
+			}
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// DeleteTopology deletes the subscriptions, subscribers, topics and producer of the
+// topology in reverse dependency order. Objects which do not exist are skipped.
+// Subscriptions are only deleted when their topic or subscriber is declared, and
+// the producer is kept while it has undeclared topics
+func (c *Client) DeleteTopology(topology Topology) ([]TopologyChange, error) {
+	current, err := c.topologyState(topology)
+	if err != nil {
+		return nil, err
+	}
+	var changes []TopologyChange
+	record := func(kind, name, id string) {
+		changes = append(changes, TopologyChange{Action: TopologyDelete, Kind: kind, Name: name, ID: id})
+	}
+	declaredTopics := make(map[string]bool)
+	for _, t := range topology.Topics {
+		declaredTopics[t.Name] = true
+	}
+	declaredSubscribers := make(map[string]bool)
+	for _, s := range topology.Subscribers {
+		declaredSubscribers[subscriberKey(s)] = true
+	}
+	ownedIDs := make(map[string]bool)
+	for _, t := range current.topics {
+		if declaredTopics[t.Name] {
+			ownedIDs[t.ID] = true
+		}
+	}
+	for _, s := range current.subscribers {
+		if declaredSubscribers[subscriberKey(s)] {
+			ownedIDs[s.ID] = true
+		}
+	}
+	for _, s := range current.subscriptions {
+		if !ownedIDs[s.TopicID] && !ownedIDs[s.SubscriberID] {
+			continue
+		}
+		if _, _, err := c.Subscription.DeleteSubscription(s); err != nil {
+			return changes, fmt.Errorf("delete subscription %s: %w", s.ID, err)
+		}
+		record(KindSubscription, s.SubscriptionEndpoint, s.ID)
+	}
+	for _, s := range current.subscribers {
+		if !declaredSubscribers[subscriberKey(s)] {
+			continue
+		}
+		if _, _, err := c.Subscriber.DeleteSubscriber(s); err != nil {
+			return changes, fmt.Errorf("delete subscriber %s: %w", s.SubscriberServicename, err)
+		}
+		record(KindSubscriber, s.SubscriberServicename, s.ID)
+	}
+	remainingTopics := 0
+	for _, t := range current.topics {
+		if !declaredTopics[t.Name] {
+			remainingTopics++
+			continue
+		}
+		if _, _, err := c.Topic.DeleteTopic(t); err != nil {
+			return changes, fmt.Errorf("delete topic %s: %w", t.Name, err)
+		}
+		record(KindTopic, t.Name, t.ID)
+	}
+	if current.producer != nil && remainingTopics == 0 {
+		if _, _, err := c.Producer.DeleteProducer(*current.producer); err != nil {
+			return changes, fmt.Errorf("delete producer: %w", err)
+		}
+		record(KindProducer, current.producer.ProducerServiceName, current.producer.ID)
+	}
+	return changes, nil
+}
+
+// topologyState retrieves the existing objects in scope of the topology
+func (c *Client) topologyState(topology Topology) (*topologyState, error) {
+	state := &topologyState{}
+
+	p := topology.Producer
+	producers, _, err := c.Producer.GetProducers(&GetOptions{
+		ManagedOrganizationID: &p.ManagingOrganizationID,
+		ProducerProductName:   &p.ProducerProductName,
+		ProducerServiceName:   &p.ProducerServiceName,
+	})
+	if err != nil && !errors.Is(err, ErrEmptyResult) {
+		return nil, fmt.Errorf("get producers: %w", err)
+	}
+	for i := range producers {
+		if producers[i].ProducerServiceInstanceName == p.ProducerServiceInstanceName {
+			state.producer = &producers[i]
+			break
+		}
+	}
+
+	if state.producer != nil {
+		state.topics, _, err = c.Topic.GetTopics(&GetOptions{ProducerID: &state.producer.ID})
+		if err != nil && !errors.Is(err, ErrEmptyResult) {
+			return nil, fmt.Errorf("get topics: %w", err)
+		}
+	}
+
+	products := make(map[string]bool)
+	for _, s := range topology.Subscribers {
+		products[s.ManagingOrganizationID+"|"+s.SubscriberProductName] = true
+	}
+	organizations := make(map[string]bool)
+	for _, s := range topology.Subscribers {
+		if organizations[s.ManagingOrganizationID] {
+			continue
+		}
+		organizations[s.ManagingOrganizationID] = true
+		orgID := s.ManagingOrganizationID
+		subscribers, _, err := c.Subscriber.GetSubscribers(&GetOptions{ManagedOrganizationID: &orgID})
+		if err != nil && !errors.Is(err, ErrEmptyResult) {
+			return nil, fmt.Errorf("get subscribers: %w", err)
+		}
+		for _, found := range subscribers {
+			if products[found.ManagingOrganizationID+"|"+found.SubscriberProductName] {
+				state.subscribers = append(state.subscribers, found)
+			}
+		}
+	}
+
+	for _, t := range state.topics {
+		topicID := t.ID
+		subscriptions, _, err := c.Subscription.GetSubscriptions(&GetOptions{TopicID: &topicID})
+		if err != nil && !errors.Is(err, ErrEmptyResult) {
+			return nil, fmt.Errorf("get subscriptions: %w", err)
+		}
+		state.subscriptions = append(state.subscriptions, subscriptions...)
+	}
+	return state, nil
+}
+
+// waitForConfirmation polls the subscriptions until all are confirmed
+func (c *Client) waitForConfirmation(subscriptions []Subscription, options ApplyOptions) (map[string]Subscription, error) {
+	confirmed := make(map[string]Subscription)
+	timeout := options.ConfirmTimeout
+	if timeout <= 0 {
+		timeout = defaultConfirmTimeout
+	}
+	interval := options.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	policy := backoff.NewExponentialBackOff()
+	policy.InitialInterval = interval
+	policy.MaxElapsedTime = timeout
+
+	operation := func() error {
+		var unconfirmed []string
+		for _, s := range subscriptions {
+			if _, ok := confirmed[s.ID]; ok {
+				continue
+			}
+			current, _, err := c.Subscription.GetSubscription(s.ID)
+			if err != nil {
+				return err
+			}
+			if !SubscriptionConfirmed(*current) {
+				unconfirmed = append(unconfirmed, s.SubscriptionEndpoint)
+				continue
+			}
+			confirmed[s.ID] = *current
+		}
+		if len(unconfirmed) > 0 {
+			return fmt.Errorf("%w: %s", ErrSubscriptionNotConfirmed, strings.Join(unconfirmed, ", "))
+		}
+		return nil
+	}
+	err := backoff.Retry(operation, policy)
+	return confirmed, err
+}
+
+// SubscriptionConfirmed returns true once the endpoint confirmed the subscription
+// and it received its ARN
+func SubscriptionConfirmed(subscription Subscription) bool {
+	return strings.HasPrefix(subscription.SubscriptionARN, "arn:")
+}
+
+func validateTopology(topology Topology) error {
+	topics := make(map[string]bool)
+	for _, t := range topology.Topics {
+		if topics[t.Name] {
+			return fmt.Errorf("%w: duplicate topic %s", ErrInvalidTopology, t.Name)
+		}
+		topics[t.Name] = true
+	}
+	subscribers := make(map[string]bool)
+	for _, s := range topology.Subscribers {
+		if subscribers[s.SubscriberServicename] {
+			return fmt.Errorf("%w: duplicate subscriber %s", ErrInvalidTopology, s.SubscriberServicename)
+		}
+		subscribers[s.SubscriberServicename] = true
+	}
+	for _, s := range topology.Subscriptions {
+		if !topics[s.Topic] {
+			return fmt.Errorf("%w: subscription to unknown topic %s", ErrInvalidTopology, s.Topic)
+		}
+		if !subscribers[s.Subscriber] {
+			return fmt.Errorf("%w: subscription of unknown subscriber %s", ErrInvalidTopology, s.Subscriber)
+		}
+		if s.Endpoint == "" {
+			return fmt.Errorf("%w: subscription of %s to %s without endpoint", ErrInvalidTopology, s.Subscriber, s.Topic)
+		}
+	}
+	return nil
+}
+
+func subscriberKey(s Subscriber) string {
+	return s.ManagingOrganizationID + "|" + s.SubscriberProductName + "|" + s.SubscriberServicename
+}
+
+func subscriberEqual(existing, desired Subscriber) bool {
+	return existing.SubscriberServiceinstanceName == desired.SubscriberServiceinstanceName &&
+		existing.SubscriberServiceBaseURL == desired.SubscriberServiceBaseURL &&
+		existing.SubscriberServicePathURL == desired.SubscriberServicePathURL &&
+		existing.Description == desired.Description
+}
+
+func topicEqual(existing, desired Topic) bool {
+	if existing.Scope != desired.Scope || existing.IsAuditable != desired.IsAuditable || existing.Description != desired.Description {
+		return false
+	}
+	if len(existing.AllowedScopes) != len(desired.AllowedScopes) {
+		return false
+	}
+	a := append([]string(nil), existing.AllowedScopes...)
+	b := append([]string(nil), desired.AllowedScopes...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedSubscribers(subscribers map[string]Subscriber) []Subscriber {
+	list := make([]Subscriber, 0, len(subscribers))
+	for _, s := range subscribers {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return subscriberKey(list[i]) < subscriberKey(list[j]) })
+	return list
+}
+
+func sortedTopics(topics map[string]Topic) []Topic {
+	list := make([]Topic, 0, len(topics))
+	for _, t := range topics {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
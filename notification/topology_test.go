@@ -0,0 +1,329 @@
+package notification_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/philips-software/go-hsdp-api/notification"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNotification is an in-memory implementation of the Notification resources
+type fakeNotification struct {
+	sync.Mutex
+	objects map[string]map[string]map[string]interface{}
+	nextID  int
+	// confirmAfter is the number of reads after which a subscription is confirmed
+	confirmAfter int
+	reads        map[string]int
+}
+
+var fakeQueryFields = map[string]string{
+	"_id":                   "_id",
+	"producerId":            "producerId",
+	"managedOrganizationId": "managingOrganizationId",
+	"producerProductName":   "producerProductName",
+	"producerServiceName":   "producerServiceName",
+	"topicId":               "topicId",
+	"subscriberId":          "subscriberId",
+}
+
+func newFakeNotification(t *testing.T) *fakeNotification {
+	f := &fakeNotification{objects: make(map[string]map[string]map[string]interface{}), reads: make(map[string]int)}
+	for _, kind := range []string{"Producer", "Topic", "Subscriber", "Subscription"} {
+		kind := kind
+		f.objects[kind] = make(map[string]map[string]interface{})
+		handler := func(w http.ResponseWriter, r *http.Request) { f.serve(t, kind, w, r) }
+		muxNotification.HandleFunc("/core/notification/"+kind, handler)
+		muxNotification.HandleFunc("/core/notification/"+kind+"/", handler)
+	}
+	return f
+}
+
+func (f *fakeNotification) serve(t *testing.T, kind string, w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	store := f.objects[kind]
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/core/notification/"+kind), "/")
+
+	switch r.Method {
+	case http.MethodGet:
+		entries := make([]map[string]interface{}, 0)
+		for _, object := range store {
+			match := true
+			for param, values := range r.URL.Query() {
+				if field, ok := fakeQueryFields[param]; ok && object[field] != values[0] {
+					match = false
+				}
+			}
+			if !match {
+				continue
+			}
+			if kind == "Subscription" {
+				objectID := object["_id"].(string)
+				f.reads[objectID]++
+				if f.reads[objectID] > f.confirmAfter {
+					object["subscriptionArn"] = "arn:aws:sns:eu-west-1:123456789012:" + objectID
+				}
+			}
+			entries = append(entries, object)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"resourceType": "bundle",
+			"type":         "searchset",
+			"total":        len(entries),
+			"entry":        entries,
+		})
+	case http.MethodPost:
+		var object map[string]interface{}
+		if !assert.Nil(t, json.NewDecoder(r.Body).Decode(&object)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.nextID++
+		object["_id"] = fmt.Sprintf("%s-%d", strings.ToLower(kind), f.nextID)
+		object["resourceType"] = kind
+		if kind == "Subscription" {
+			object["subscriptionArn"] = "PendingConfirmation"
+		}
+		store[object["_id"].(string)] = object
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(object)
+	case http.MethodPut:
+		var object map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&object)
+		if _, ok := store[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		object["_id"] = id
+		object["resourceType"] = kind
+		store[id] = object
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if _, ok := store[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(store, id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeNotification) count(kind string) int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.objects[kind])
+}
+
+func testTopology() notification.Topology {
+	subscriber := func(name string) notification.Subscriber {
+		return notification.Subscriber{
+			ManagingOrganizationID:   notificationOrgID,
+			SubscriberProductName:    "alerts",
+			SubscriberServicename:    name,
+			SubscriberServiceBaseURL: "https://" + name + ".example.com",
+			SubscriberServicePathURL: "/hooks",
+		}
+	}
+	return notification.Topology{
+		Producer: notification.Producer{
+			ManagingOrganizationID:      notificationOrgID,
+			ProducerProductName:         "devices",
+			ProducerServiceName:         "monitor",
+			ProducerServiceInstanceName: "monitor-1",
+			ProducerServiceBaseURL:      "https://monitor.example.com",
+			ProducerServicePathURL:      "/events",
+		},
+		Topics: []notification.Topic{
+			{Name: "device-alerts", Scope: "private"},
+			{Name: "device-status", Scope: "private"},
+		},
+		Subscribers: []notification.Subscriber{subscriber("pager"), subscriber("dashboard")},
+		Subscriptions: []notification.TopologySubscription{
+			{Topic: "device-alerts", Subscriber: "pager", Endpoint: "https://pager.example.com/hooks"},
+			{Topic: "device-status", Subscriber: "dashboard", Endpoint: "https://dashboard.example.com/hooks"},
+		},
+	}
+}
+
+func changeList(changes []notification.TopologyChange) []string {
+	var list []string
+	for _, c := range changes {
+		list = append(list, c.Action+" "+c.Kind+" "+c.Name)
+	}
+	return list
+}
+
+func TestApplyTopology(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+	fake := newFakeNotification(t)
+	fake.confirmAfter = 1
+
+	topology := testTopology()
+	result, err := notificationClient.ApplyTopology(topology, notification.ApplyOptions{DryRun: true})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Len(t, result.Changes, 7)
+	assert.Equal(t, 0, fake.count("Producer"))
+
+	result, err = notificationClient.ApplyTopology(topology, notification.ApplyOptions{
+		WaitForConfirmation: true,
+		PollInterval:        time.Millisecond,
+		ConfirmTimeout:      time.Second,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{
+		"create Producer monitor",
+		"create Topic device-alerts",
+		"create Topic device-status",
+		"create Subscriber pager",
+		"create Subscriber dashboard",
+		"create Subscription https://pager.example.com/hooks",
+		"create Subscription https://dashboard.example.com/hooks",
+	}, changeList(result.Changes))
+	assert.Equal(t, result.Producer.ID, result.Topics["device-alerts"].ProducerID)
+	if assert.Len(t, result.Subscriptions, 2) {
+		for _, s := range result.Subscriptions {
+			assert.True(t, notification.SubscriptionConfirmed(s))
+		}
+	}
+
+	// Applying again is a no-op
+	result, err = notificationClient.ApplyTopology(topology, notification.ApplyOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, result.Changes)
+
+	// Change a topic and a subscriber, drop a topic and its subscription
+	topology.Topics = topology.Topics[:1]
+	topology.Topics[0].Description = "Alerts raised by devices"
+	topology.Subscribers[1].SubscriberServicePathURL = "/v2/hooks"
+	topology.Subscriptions = []notification.TopologySubscription{
+		topology.Subscriptions[0],
+		{Topic: "device-alerts", Subscriber: "dashboard", Endpoint: "https://dashboard.example.com/v2/hooks"},
+	}
+	result, err = notificationClient.ApplyTopology(topology, notification.ApplyOptions{Prune: true})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []string{
+		"update Topic device-alerts",
+		"delete Subscription https://dashboard.example.com/hooks",
+		"replace Subscriber dashboard",
+		"create Subscription https://dashboard.example.com/v2/hooks",
+		"delete Topic device-status",
+	}, changeList(result.Changes))
+	assert.Equal(t, "Alerts raised by devices", result.Topics["device-alerts"].Description)
+	assert.Equal(t, 1, fake.count("Topic"))
+	assert.Equal(t, 2, fake.count("Subscriber"))
+	assert.Equal(t, 2, fake.count("Subscription"))
+
+	changes, err := notificationClient.DeleteTopology(topology)
+	assert.Nil(t, err)
+	assert.Len(t, changes, 6)
+	for _, kind := range []string{"Producer", "Topic", "Subscriber", "Subscription"} {
+		assert.Equal(t, 0, fake.count(kind), kind)
+	}
+}
+
+func TestApplyTopologyErrors(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+	fake := newFakeNotification(t)
+	fake.confirmAfter = 1000
+
+	topology := testTopology()
+	topology.Subscriptions = append(topology.Subscriptions, notification.TopologySubscription{
+		Topic: "unknown", Subscriber: "pager", Endpoint: "mailto:ops@example.com",
+	})
+	_, err := notificationClient.ApplyTopology(topology, notification.ApplyOptions{})
+	assert.True(t, errors.Is(err, notification.ErrInvalidTopology))
+
+	topology = testTopology()
+	result, err := notificationClient.ApplyTopology(topology, notification.ApplyOptions{
+		WaitForConfirmation: true,
+		PollInterval:        time.Millisecond,
+		ConfirmTimeout:      20 * time.Millisecond,
+	})
+	assert.True(t, errors.Is(err, notification.ErrSubscriptionNotConfirmed))
+	if assert.NotNil(t, result) {
+		assert.Len(t, result.Subscriptions, 2)
+	}
+}
+
+func TestDeleteTopologyKeepsUndeclared(t *testing.T) {
+	teardown := setup(t)
+	defer teardown()
+	fake := newFakeNotification(t)
+
+	topology := testTopology()
+	result, err := notificationClient.ApplyTopology(topology, notification.ApplyOptions{})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// A topic of the same producer with a subscriber owned by another team
+	legacy, _, err := notificationClient.Topic.CreateTopic(notification.Topic{
+		Name: "legacy", Scope: "private", ProducerID: result.Producer.ID,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	other, _, err := notificationClient.Subscriber.CreateSubscriber(notification.Subscriber{
+		ManagingOrganizationID:   notificationOrgID,
+		SubscriberProductName:    "audit",
+		SubscriberServicename:    "archive",
+		SubscriberServiceBaseURL: "https://archive.example.com",
+		SubscriberServicePathURL: "/hooks",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	kept, _, err := notificationClient.Subscription.CreateSubscription(notification.Subscription{
+		TopicID: legacy.ID, SubscriberID: other.ID, SubscriptionEndpoint: "https://archive.example.com/hooks",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	// A declared subscriber on the undeclared topic goes with its subscriber
+	_, _, err = notificationClient.Subscription.CreateSubscription(notification.Subscription{
+		TopicID: legacy.ID, SubscriberID: result.Subscribers["pager"].ID, SubscriptionEndpoint: "mailto:pager@example.com",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	changes, err := notificationClient.DeleteTopology(topology)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.ElementsMatch(t, []string{
+		"delete Subscription https://pager.example.com/hooks",
+		"delete Subscription https://dashboard.example.com/hooks",
+		"delete Subscription mailto:pager@example.com",
+		"delete Subscriber pager",
+		"delete Subscriber dashboard",
+		"delete Topic device-alerts",
+		"delete Topic device-status",
+	}, changeList(changes))
+	assert.Equal(t, 1, fake.count("Producer"))
+	assert.Equal(t, 1, fake.count("Topic"))
+	assert.Equal(t, 1, fake.count("Subscriber"))
+	if assert.Equal(t, 1, fake.count("Subscription")) {
+		subscription, _, err := notificationClient.Subscription.GetSubscription(kept.ID)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "https://archive.example.com/hooks", subscription.SubscriptionEndpoint)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package profile
+
+import (
+	"errors"
+)
+
+var (
+	ErrProfileNotFound    = errors.New("profile not found")
+	ErrMissingCredentials = errors.New("profile has no IAM login credentials")
+	ErrMissingURL         = errors.New("no URL for service")
+	ErrMissingIronConfig  = errors.New("profile has no Iron config file")
+)
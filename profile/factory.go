@@ -0,0 +1,286 @@
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/philips-software/go-hsdp-api/blr"
+	"github.com/philips-software/go-hsdp-api/cartel"
+	"github.com/philips-software/go-hsdp-api/cdr"
+	"github.com/philips-software/go-hsdp-api/config"
+	"github.com/philips-software/go-hsdp-api/connect/mdm"
+	"github.com/philips-software/go-hsdp-api/discovery"
+	"github.com/philips-software/go-hsdp-api/iam"
+	"github.com/philips-software/go-hsdp-api/iron"
+	"github.com/philips-software/go-hsdp-api/logging"
+	"github.com/philips-software/go-hsdp-api/notification"
+)
+
+// registryNames lists the registry names of a service in order of preference
+var registryNames = map[string][]string{
+	"cdr": {"cdr", "cdr-r4", "cdr-stu3"},
+	"mdm": {"mdm", "connect-mdm"},
+}
+
+// Factory builds service clients from a profile. All clients share one
+// logged in IAM client and one HTTP client. URLs come from the profile or
+// else from the config registry for the profile region and environment
+type Factory struct {
+	Profile    Profile
+	HTTPClient *http.Client
+
+	registry  *config.Config
+	iamClient *iam.Client
+	mu        sync.Mutex
+}
+
+// NewFactory returns a factory for the profile. When httpClient is nil a
+// client honouring the proxy environment is used
+func NewFactory(profile Profile, httpClient *http.Client) (*Factory, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+			},
+		}
+	}
+	registry, err := config.New(
+		config.WithRegion(profile.Region),
+		config.WithEnv(profile.Environment))
+	if err != nil {
+		return nil, err
+	}
+	return &Factory{Profile: profile, HTTPClient: httpClient, registry: registry}, nil
+}
+
+// FromProfile returns a factory for the named profile of the default profiles file
+func FromProfile(name string) (*Factory, error) {
+	profile, err := LoadProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewFactory(*profile, nil)
+}
+
+// URL returns the URL of a service. Profile URLs take precedence over the registry
+func (f *Factory) URL(service string) string {
+	names := registryNames[service]
+	if len(names) == 0 {
+		names = []string{service}
+	}
+	for _, name := range names {
+		if u := f.Profile.URLs[name]; u != "" {
+			return u
+		}
+	}
+	for _, name := range names {
+		if u := f.registry.Service(name).URL; u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+func (f *Factory) requireURL(service string) (string, error) {
+	u := f.URL(service)
+	if u == "" {
+		return "", fmt.Errorf("%w: %s in %s/%s", ErrMissingURL, service, f.Profile.Region, f.Profile.Environment)
+	}
+	return u, nil
+}
+
+// IAM returns the shared IAM client, logging in on first use. A service identity
+// is preferred over a user account, which is preferred over client credentials
+func (f *Factory) IAM() (*iam.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.iamClient != nil {
+		return f.iamClient, nil
+	}
+	p := f.Profile
+	client, err := iam.NewClient(f.HTTPClient, &iam.Config{
+		Region:         p.Region,
+		Environment:    p.Environment,
+		OAuth2ClientID: p.OAuth2ClientID,
+		OAuth2Secret:   p.OAuth2Secret,
+		SharedKey:      p.SharedKey,
+		SecretKey:      p.SecretKey,
+		IAMURL:         f.URL("iam"),
+		IDMURL:         f.URL("idm"),
+		Scopes:         p.Scopes,
+		RootOrgID:      p.OrganizationID,
+		DebugLog:       p.DebugLog,
+	})
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.ServiceID != "" && p.ServicePrivateKey != "":
+		err = client.ServiceLogin(iam.Service{ServiceID: p.ServiceID, PrivateKey: p.ServicePrivateKey})
+	case p.Username != "" && p.Password != "":
+		err = client.Login(p.Username, p.Password)
+	case p.OAuth2ClientID != "" && p.OAuth2Secret != "":
+		err = client.ClientCredentialsLogin()
+	default:
+		err = ErrMissingCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+	f.iamClient = client
+	return client, nil
+}
+
+// CDR returns a CDR client for the FHIR store of the profile organization
+func (f *Factory) CDR() (*cdr.Client, error) {
+	u, err := f.requireURL("cdr")
+	if err != nil {
+		return nil, err
+	}
+	iamClient, err := f.IAM()
+	if err != nil {
+		return nil, err
+	}
+	return cdr.NewClient(iamClient, &cdr.Config{
+		Region:      f.Profile.Region,
+		Environment: f.Profile.Environment,
+		RootOrgID:   f.Profile.OrganizationID,
+		CDRURL:      u,
+		DebugLog:    f.Profile.DebugLog,
+	})
+}
+
+// BLR returns a Blob Repository client
+func (f *Factory) BLR() (*blr.Client, error) {
+	u, err := f.requireURL("blr")
+	if err != nil {
+		return nil, err
+	}
+	iamClient, err := f.IAM()
+	if err != nil {
+		return nil, err
+	}
+	return blr.NewClient(iamClient, &blr.Config{
+		Region:      f.Profile.Region,
+		Environment: f.Profile.Environment,
+		BaseURL:     u,
+		DebugLog:    f.Profile.DebugLog,
+	})
+}
+
+// MDM returns a Connect MDM client
+func (f *Factory) MDM() (*mdm.Client, error) {
+	u, err := f.requireURL("mdm")
+	if err != nil {
+		return nil, err
+	}
+	iamClient, err := f.IAM()
+	if err != nil {
+		return nil, err
+	}
+	return mdm.NewClient(iamClient, &mdm.Config{
+		Region:      f.Profile.Region,
+		Environment: f.Profile.Environment,
+		BaseURL:     u,
+		DebugLog:    f.Profile.DebugLog,
+	})
+}
+
+// Discovery returns a Discovery client
+func (f *Factory) Discovery() (*discovery.Client, error) {
+	u, err := f.requireURL("discovery")
+	if err != nil {
+		return nil, err
+	}
+	iamClient, err := f.IAM()
+	if err != nil {
+		return nil, err
+	}
+	return discovery.NewClient(iamClient, &discovery.Config{
+		Region:      f.Profile.Region,
+		Environment: f.Profile.Environment,
+		BaseURL:     u,
+		DebugLog:    f.Profile.DebugLog,
+	})
+}
+
+// Notification returns a Notification client for the profile organization
+func (f *Factory) Notification() (*notification.Client, error) {
+	u, err := f.requireURL("notification")
+	if err != nil {
+		return nil, err
+	}
+	iamClient, err := f.IAM()
+	if err != nil {
+		return nil, err
+	}
+	return notification.NewClient(iamClient, &notification.Config{
+		Region:          f.Profile.Region,
+		Environment:     f.Profile.Environment,
+		OrganizationID:  f.Profile.OrganizationID,
+		NotificationURL: u,
+		DebugLog:        f.Profile.DebugLog,
+	})
+}
+
+// Logging returns a logging client. It signs requests with the logging shared
+// credentials of the profile, or else uses the IAM client
+func (f *Factory) Logging() (*logging.Client, error) {
+	u, err := f.requireURL("logging")
+	if err != nil {
+		return nil, err
+	}
+	config := &logging.Config{
+		Region:       f.Profile.Region,
+		Environment:  f.Profile.Environment,
+		SharedKey:    f.Profile.LoggingSharedKey,
+		SharedSecret: f.Profile.LoggingSharedSecret,
+		ProductKey:   f.Profile.LoggingProductKey,
+		BaseURL:      u,
+		DebugLog:     f.Profile.DebugLog,
+	}
+	if config.SharedKey == "" {
+		if config.IAMClient, err = f.IAM(); err != nil {
+			return nil, err
+		}
+	}
+	return logging.NewClient(f.HTTPClient, config)
+}
+
+// Cartel returns a Cartel client
+func (f *Factory) Cartel() (*cartel.Client, error) {
+	host := f.Profile.CartelHost
+	if host == "" {
+		host = f.registry.Service("cartel").Host
+	}
+	return cartel.NewClient(f.HTTPClient, &cartel.Config{
+		Region:   f.Profile.Region,
+		Token:    f.Profile.CartelToken,
+		Secret:   f.Profile.CartelSecret,
+		Host:     host,
+		DebugLog: f.Profile.DebugLog,
+	})
+}
+
+// Iron returns an Iron client configured from the Iron config file of the profile.
+// Iron manages its own HTTP client
+func (f *Factory) Iron() (*iron.Client, error) {
+	if f.Profile.IronConfigFile == "" {
+		return nil, ErrMissingIronConfig
+	}
+	data, err := os.ReadFile(f.Profile.IronConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	var config iron.Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Profile.IronConfigFile, err)
+	}
+	if config.DebugLog == "" {
+		config.DebugLog = f.Profile.DebugLog
+	}
+	return iron.NewClient(&config)
+}
@@ -0,0 +1,140 @@
+// Package profile provides named HSDP profiles and a factory building service clients from them
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	// DefaultProfile is used when no profile name is given and HSDP_PROFILE is not set
+	DefaultProfile = "default"
+	// ProfileEnv selects the profile
+	ProfileEnv = "HSDP_PROFILE"
+	// ConfigFileEnv overrides the location of the profiles file
+	ConfigFileEnv = "HSDP_CONFIG_FILE"
+)
+
+// Profile holds the location and credentials of an HSDP environment
+type Profile struct {
+	Name           string `toml:"-"`
+	Region         string `toml:"region"`
+	Environment    string `toml:"environment"`
+	OrganizationID string `toml:"organization_id"`
+
+	// IAM OAuth2 client
+	OAuth2ClientID string   `toml:"oauth2_client_id"`
+	OAuth2Secret   string   `toml:"oauth2_secret"`
+	Scopes         []string `toml:"scopes"`
+	// IAM API signing credentials
+	SharedKey string `toml:"shared_key"`
+	SecretKey string `toml:"secret_key"`
+	// Login with a user account
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// Login with a service identity. The private key can be given inline or as file
+	ServiceID             string `toml:"service_id"`
+	ServicePrivateKey     string `toml:"service_private_key"`
+	ServicePrivateKeyFile string `toml:"service_private_key_file"`
+
+	// Logging
+	LoggingSharedKey    string `toml:"logging_shared_key"`
+	LoggingSharedSecret string `toml:"logging_shared_secret"`
+	LoggingProductKey   string `toml:"logging_product_key"`
+
+	// Cartel
+	CartelToken  string `toml:"cartel_token"`
+	CartelSecret string `toml:"cartel_secret"`
+	CartelHost   string `toml:"cartel_host"`
+
+	// IronConfigFile is the path to an Iron JSON config file
+	IronConfigFile string `toml:"iron_config_file"`
+
+	// URLs override the registry URL of a service, keyed by registry service name,
+	// e.g. "iam", "idm", "notification" or "cdr"
+	URLs map[string]string `toml:"urls"`
+
+	DebugLog string `toml:"debug_log"`
+}
+
+// Profiles holds the profiles of a profiles file by name
+type Profiles map[string]Profile
+
+// DefaultPath returns the profiles file location: $HSDP_CONFIG_FILE or ~/.hsdp/config.toml
+func DefaultPath() (string, error) {
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".hsdp", "config.toml"), nil
+}
+
+// Load reads a TOML profiles file. Every top-level table is a profile
+func Load(path string) (Profiles, error) {
+	var profiles Profiles
+	if _, err := toml.DecodeFile(path, &profiles); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, p := range profiles {
+		p.Name = name
+		if p.ServicePrivateKey == "" && p.ServicePrivateKeyFile != "" {
+			keyFile := p.ServicePrivateKeyFile
+			if !filepath.IsAbs(keyFile) {
+				keyFile = filepath.Join(filepath.Dir(path), keyFile)
+			}
+			data, err := os.ReadFile(keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("profile %s: %w", name, err)
+			}
+			p.ServicePrivateKey = string(data)
+		}
+		profiles[name] = p
+	}
+	return profiles, nil
+}
+
+// LoadProfile reads the named profile from the default profiles file. An empty
+// name selects $HSDP_PROFILE or DefaultProfile
+func LoadProfile(name string) (*Profile, error) {
+	path, err := DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return profiles.Get(name)
+}
+
+// Get returns the named profile. An empty name selects $HSDP_PROFILE or DefaultProfile
+func (p Profiles) Get(name string) (*Profile, error) {
+	if name == "" {
+		name = os.Getenv(ProfileEnv)
+	}
+	if name == "" {
+		name = DefaultProfile
+	}
+	profile, ok := p[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	return &profile, nil
+}
+
+// Names returns the sorted profile names
+func (p Profiles) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}